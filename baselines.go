@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const baselineNotFoundMsg = "Baseline not found"
+
+// baselineColor fills the ghost bars that show where a task was baselined
+const baselineColor = "#95a5a6"
+
+// Ghost bar heights: pixels for SVG and PNG, millimetres for PDF
+const (
+	baselineBarHeight    = 4
+	pdfBaselineBarHeight = 1.2
+)
+
+// BaselineSlip reports how far the current plan has moved from a baseline
+type BaselineSlip struct {
+	ChartID      string     `json:"chartId"`
+	BaselineID   string     `json:"baselineId"`
+	BaselineName string     `json:"baselineName"`
+	FrozenAt     time.Time  `json:"frozenAt"`
+	Tasks        []TaskSlip `json:"tasks"`
+	// Added lists tasks created since the baseline was frozen
+	Added []string `json:"added,omitempty"`
+	// Removed lists baselined tasks that no longer exist
+	Removed []string `json:"removed,omitempty"`
+}
+
+// baselineRequest is the body of a request to freeze a baseline
+type baselineRequest struct {
+	Name string `json:"name"`
+}
+
+// TaskSlip compares a task's dates with its baselined dates. Dates are
+// YYYY-MM-DD and inclusive; positive slips mean the task moved later.
+type TaskSlip struct {
+	TaskID            string `json:"taskId"`
+	Title             string `json:"title"`
+	BaselineStart     string `json:"baselineStart"`
+	BaselineEnd       string `json:"baselineEnd"`
+	Start             string `json:"start"`
+	End               string `json:"end"`
+	StartSlipDays     int    `json:"startSlipDays"`
+	EndSlipDays       int    `json:"endSlipDays"`
+	StartSlipQuarters int    `json:"startSlipQuarters"`
+	EndSlipQuarters   int    `json:"endSlipQuarters"`
+}
+
+// task returns the baselined dates as a Task, for the timeline helpers
+func (b BaselineTask) task() Task {
+	return Task{ID: b.TaskID, StartYear: b.StartYear, StartQ: b.StartQ, EndYear: b.EndYear, EndQ: b.EndQ,
+		StartDate: b.StartDate, EndDate: b.EndDate}
+}
+
+// freezeBaseline snapshots the dates of every task in the chart
+func freezeBaseline(chart *Chart, name string) Baseline {
+	b := Baseline{ID: uuid.New().String(), Name: name, CreatedAt: time.Now(), Tasks: []BaselineTask{}}
+	for _, cat := range chart.Categories {
+		for _, task := range allTasks(cat.Tasks) {
+			b.Tasks = append(b.Tasks, BaselineTask{task.ID, task.StartYear, task.StartQ, task.EndYear, task.EndQ,
+				task.StartDate, task.EndDate})
+		}
+	}
+	return b
+}
+
+// validateBaselines checks that baselines have unique names and that
+// their task dates are well formed
+func validateBaselines(chart *Chart) error {
+	var errs []error
+	names := make(map[string]bool, len(chart.Baselines))
+	cal := chartCalendar(chart)
+	for i, b := range chart.Baselines {
+		path := fmt.Sprintf("/baselines/%d", i)
+		switch {
+		case strings.TrimSpace(b.Name) == "":
+			errs = append(errs, fieldErrorf(path+"/name", "baseline name is required"))
+		case names[b.Name]:
+			errs = append(errs, fieldErrorf(path+"/name", "baseline name %q is used more than once", b.Name))
+		}
+		names[b.Name] = true
+		for j, t := range b.Tasks {
+			if _, _, ok := taskDates(t.task(), cal); !ok {
+				errs = append(errs, fieldErrorf(fmt.Sprintf("%s/tasks/%d", path, j), "baseline %q has invalid dates for task %q", b.Name, t.TaskID))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// findBaseline looks a baseline up by ID or, failing that, by name
+func findBaseline(chart *Chart, ref string) int {
+	for i, b := range chart.Baselines {
+		if b.ID == ref {
+			return i
+		}
+	}
+	for i, b := range chart.Baselines {
+		if b.Name == ref {
+			return i
+		}
+	}
+	return -1
+}
+
+// baselineTasks indexes a baseline's task dates by task ID, for rendering
+// ghost bars
+func baselineTasks(b Baseline) map[string]Task {
+	tasks := make(map[string]Task, len(b.Tasks))
+	for _, t := range b.Tasks {
+		tasks[t.TaskID] = t.task()
+	}
+	return tasks
+}
+
+// computeSlip compares every task of the chart with a baseline, in chart
+// order
+func computeSlip(chart *Chart, b Baseline) *BaselineSlip {
+	slip := &BaselineSlip{ChartID: chart.ID, BaselineID: b.ID, BaselineName: b.Name, FrozenAt: b.CreatedAt, Tasks: []TaskSlip{}}
+	baselined := baselineTasks(b)
+	cal := chartCalendar(chart)
+	days := func(from, to time.Time) int {
+		return int(math.Round(to.Sub(from).Hours() / 24))
+	}
+
+	seen := make(map[string]bool)
+	for _, cat := range chart.Categories {
+		for _, task := range allTasks(cat.Tasks) {
+			seen[task.ID] = true
+			old, ok := baselined[task.ID]
+			if !ok {
+				slip.Added = append(slip.Added, task.ID)
+				continue
+			}
+			start, end, ok := taskDates(task, cal)
+			oldStart, oldEnd, oldOK := taskDates(old, cal)
+			if !ok || !oldOK {
+				continue
+			}
+			slip.Tasks = append(slip.Tasks, TaskSlip{
+				TaskID:            task.ID,
+				Title:             task.Title,
+				BaselineStart:     oldStart.Format(dateLayout),
+				BaselineEnd:       oldEnd.AddDate(0, 0, -1).Format(dateLayout),
+				Start:             start.Format(dateLayout),
+				End:               end.AddDate(0, 0, -1).Format(dateLayout),
+				StartSlipDays:     days(oldStart, start),
+				EndSlipDays:       days(oldEnd, end),
+				StartSlipQuarters: quarterIndex(task.StartYear, task.StartQ) - quarterIndex(old.StartYear, old.StartQ),
+				EndSlipQuarters:   quarterIndex(task.EndYear, task.EndQ) - quarterIndex(old.EndYear, old.EndQ),
+			})
+		}
+	}
+	for _, t := range b.Tasks {
+		if !seen[t.TaskID] {
+			slip.Removed = append(slip.Removed, t.TaskID)
+		}
+	}
+	return slip
+}
+
+// baselineSummary returns a baseline without its task dates
+func baselineSummary(b Baseline) Baseline {
+	b.Tasks = nil
+	return b
+}
+
+func listBaselinesHandler(w http.ResponseWriter, r *http.Request) {
+	storeMux.RLock()
+	chart := store.Get(mux.Vars(r)["id"])
+	storeMux.RUnlock()
+
+	if chart == nil {
+		http.Error(w, chartNotFoundMsg, http.StatusNotFound)
+		return
+	}
+
+	baselines := make([]Baseline, 0, len(chart.Baselines))
+	for _, b := range chart.Baselines {
+		baselines = append(baselines, baselineSummary(b))
+	}
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	w.Header().Set(etagHeader, chartETag(chart))
+	json.NewEncoder(w).Encode(baselines)
+}
+
+// chartBaseline looks up the chart and baseline named in the request,
+// writing a 404 if either is missing
+func chartBaseline(w http.ResponseWriter, r *http.Request) (*Chart, *Baseline) {
+	vars := mux.Vars(r)
+
+	storeMux.RLock()
+	chart := store.Get(vars["id"])
+	storeMux.RUnlock()
+
+	if chart == nil {
+		http.Error(w, chartNotFoundMsg, http.StatusNotFound)
+		return nil, nil
+	}
+	i := findBaseline(chart, vars["baselineId"])
+	if i < 0 {
+		http.Error(w, baselineNotFoundMsg, http.StatusNotFound)
+		return nil, nil
+	}
+	return chart, &chart.Baselines[i]
+}
+
+func getBaselineHandler(w http.ResponseWriter, r *http.Request) {
+	chart, b := chartBaseline(w, r)
+	if b == nil {
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	w.Header().Set(etagHeader, chartETag(chart))
+	json.NewEncoder(w).Encode(b)
+}
+
+// createBaselineHandler freezes the chart's current task dates under the
+// name given in the request body
+func createBaselineHandler(w http.ResponseWriter, r *http.Request) {
+	var req baselineRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		http.Error(w, "baseline name is required", http.StatusUnprocessableEntity)
+		return
+	}
+
+	var b Baseline
+	_, ok := modifyChart(w, r, fmt.Sprintf("Froze baseline %q", req.Name), func(chart *Chart) error {
+		if findBaseline(chart, req.Name) >= 0 {
+			return &httpError{http.StatusConflict, fmt.Sprintf("baseline %q already exists", req.Name)}
+		}
+		b = freezeBaseline(chart, req.Name)
+		chart.Baselines = append(chart.Baselines, b)
+		return nil
+	})
+	if !ok {
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(b)
+}
+
+func deleteBaselineHandler(w http.ResponseWriter, r *http.Request) {
+	ref := mux.Vars(r)["baselineId"]
+
+	_, ok := modifyChart(w, r, "Deleted baseline "+ref, func(chart *Chart) error {
+		i := findBaseline(chart, ref)
+		if i < 0 {
+			return &httpError{http.StatusNotFound, baselineNotFoundMsg}
+		}
+		chart.Baselines = append(chart.Baselines[:i], chart.Baselines[i+1:]...)
+		return nil
+	})
+	if !ok {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// baselineSlipHandler reports each task's slip against a baseline
+func baselineSlipHandler(w http.ResponseWriter, r *http.Request) {
+	chart, b := chartBaseline(w, r)
+	if b == nil {
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	w.Header().Set(etagHeader, chartETag(chart))
+	json.NewEncoder(w).Encode(computeSlip(chart, *b))
+}
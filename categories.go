@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const categoryNotFoundMsg = "Category not found"
+
+// categoryIndex returns the index of the category with the given ID, or -1
+func categoryIndex(chart *Chart, id string) int {
+	for i, cat := range chart.Categories {
+		if cat.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// chartCategory looks up the chart and category named in the request,
+// writing a 404 if either is missing
+func chartCategory(w http.ResponseWriter, r *http.Request) (*Chart, *Category) {
+	vars := mux.Vars(r)
+
+	storeMux.RLock()
+	chart := store.Get(vars["id"])
+	storeMux.RUnlock()
+
+	if chart == nil {
+		http.Error(w, chartNotFoundMsg, http.StatusNotFound)
+		return nil, nil
+	}
+	i := categoryIndex(chart, vars["catId"])
+	if i < 0 {
+		http.Error(w, categoryNotFoundMsg, http.StatusNotFound)
+		return nil, nil
+	}
+	return chart, &chart.Categories[i]
+}
+
+func listCategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	storeMux.RLock()
+	chart := store.Get(mux.Vars(r)["id"])
+	storeMux.RUnlock()
+
+	if chart == nil {
+		http.Error(w, chartNotFoundMsg, http.StatusNotFound)
+		return
+	}
+
+	categories := chart.Categories
+	if categories == nil {
+		categories = []Category{}
+	}
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	w.Header().Set(etagHeader, chartETag(chart))
+	json.NewEncoder(w).Encode(categories)
+}
+
+func getCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	chart, cat := chartCategory(w, r)
+	if cat == nil {
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	w.Header().Set(etagHeader, chartETag(chart))
+	json.NewEncoder(w).Encode(cat)
+}
+
+// createCategoryHandler appends a category, with any tasks it holds, to
+// the chart
+func createCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	var cat Category
+	if err := json.NewDecoder(r.Body).Decode(&cat); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	chart, ok := modifyChart(w, r, fmt.Sprintf("Added category %q", cat.Name), func(chart *Chart) error {
+		if cat.ID == "" {
+			cat.ID = uuid.New().String()
+		} else if categoryIndex(chart, cat.ID) >= 0 {
+			return &httpError{http.StatusConflict, fmt.Sprintf("category %q already exists", cat.ID)}
+		}
+		if cat.Tasks == nil {
+			cat.Tasks = []Task{}
+		}
+		chart.Categories = append(chart.Categories, cat)
+		return nil
+	})
+	if !ok {
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(chart.Categories[categoryIndex(chart, cat.ID)])
+}
+
+// updateCategoryHandler patches a category like a chart: members of a
+// merge patch replace the category's, including its whole task list if
+// given
+func updateCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	apply, ok := readPatch(w, r)
+	if !ok {
+		return
+	}
+	id := mux.Vars(r)["catId"]
+
+	chart, ok := modifyChart(w, r, "Updated category "+id, func(chart *Chart) error {
+		i := categoryIndex(chart, id)
+		if i < 0 {
+			return &httpError{http.StatusNotFound, categoryNotFoundMsg}
+		}
+		var cat Category
+		if err := patchItem(chart.Categories[i], apply, &cat); err != nil {
+			return err
+		}
+		cat.ID = id
+		if cat.Tasks == nil {
+			cat.Tasks = []Task{}
+		}
+		chart.Categories[i] = cat
+		return nil
+	})
+	if !ok {
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	json.NewEncoder(w).Encode(chart.Categories[categoryIndex(chart, id)])
+}
+
+// deleteCategoryHandler removes a category and its tasks. Its milestones
+// move to the chart level and dependencies on its tasks are dropped.
+func deleteCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["catId"]
+
+	_, ok := modifyChart(w, r, "Deleted category "+id, func(chart *Chart) error {
+		i := categoryIndex(chart, id)
+		if i < 0 {
+			return &httpError{http.StatusNotFound, categoryNotFoundMsg}
+		}
+		removeDependencies(chart, allTasks(chart.Categories[i].Tasks))
+		chart.Categories = append(chart.Categories[:i], chart.Categories[i+1:]...)
+		for j := range chart.Milestones {
+			if chart.Milestones[j].CategoryID == id {
+				chart.Milestones[j].CategoryID = ""
+			}
+		}
+		return nil
+	})
+	if !ok {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
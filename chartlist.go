@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Chart list sort keys; a leading "-" sorts descending
+const (
+	sortTitle     = "title"
+	sortCreatedAt = "createdAt"
+	sortUpdatedAt = "updatedAt"
+)
+
+// maxChartPageSize caps the limit parameter of the chart list
+const maxChartPageSize = 500
+
+const totalCountHeader = "X-Total-Count"
+
+// ChartSummary is a chart without its categories, milestones, baselines
+// and field definitions, as listed with ?fields=summary
+type ChartSummary struct {
+	ID            string          `json:"id"`
+	Title         string          `json:"title"`
+	StartYear     int             `json:"startYear"`
+	StartQ        int             `json:"startQuarter"`
+	EndYear       int             `json:"endYear"`
+	EndQ          int             `json:"endQuarter"`
+	Granularity   string          `json:"granularity,omitempty"`
+	Fiscal        *FiscalCalendar `json:"fiscal,omitempty"`
+	Owner         string          `json:"owner,omitempty"`
+	CategoryCount int             `json:"categoryCount"`
+	TaskCount     int             `json:"taskCount"` // including subtasks
+	Version       int             `json:"version"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	UpdatedAt     time.Time       `json:"updatedAt"`
+}
+
+func summarizeChart(chart *Chart) ChartSummary {
+	return ChartSummary{
+		ID:            chart.ID,
+		Title:         chart.Title,
+		StartYear:     chart.StartYear,
+		StartQ:        chart.StartQ,
+		EndYear:       chart.EndYear,
+		EndQ:          chart.EndQ,
+		Granularity:   chart.Granularity,
+		Fiscal:        chart.Fiscal,
+		Owner:         chart.Owner,
+		CategoryCount: len(chart.Categories),
+		TaskCount:     len(chartTasks(chart)),
+		Version:       chart.Version,
+		CreatedAt:     chart.CreatedAt,
+		UpdatedAt:     chart.UpdatedAt,
+	}
+}
+
+// chartQuery is a parsed chart list request
+type chartQuery struct {
+	param   string   // the sort parameter as given, which cursors belong to
+	sort    string   // sortTitle, sortCreatedAt or sortUpdatedAt
+	desc    bool     // sort descending
+	words   []string // lower-cased search words, all of which must match
+	summary bool     // list ChartSummary values
+	limit   int      // page size, or 0 for every match
+	after   *chartCursor
+}
+
+// chartCursor marks the last chart of a page. It is handed to clients as
+// an opaque token and only valid for the sort it was made with.
+type chartCursor struct {
+	Sort  string    `json:"s"`
+	Title string    `json:"t,omitempty"`
+	Time  time.Time `json:"a,omitempty"`
+	ID    string    `json:"i"`
+}
+
+func (c chartCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*chartCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	var c chartCursor
+	if err == nil {
+		err = json.Unmarshal(data, &c)
+	}
+	if err != nil || c.ID == "" {
+		return nil, errors.New("invalid cursor")
+	}
+	return &c, nil
+}
+
+// parseChartQuery reads the list parameters: sort, q, fields, limit and
+// cursor
+func parseChartQuery(values url.Values) (chartQuery, error) {
+	q := chartQuery{param: values.Get("sort"), sort: sortTitle}
+	if s := q.param; s != "" {
+		q.sort = strings.TrimPrefix(s, "-")
+		q.desc = q.sort != s
+		switch q.sort {
+		case sortTitle, sortCreatedAt, sortUpdatedAt:
+		default:
+			return q, fmt.Errorf("unknown sort %q (want title, createdAt or updatedAt, optionally prefixed with -)", s)
+		}
+	}
+	q.words = strings.Fields(strings.ToLower(values.Get("q")))
+
+	switch fields := values.Get("fields"); fields {
+	case "", "full":
+	case "summary":
+		q.summary = true
+	default:
+		return q, fmt.Errorf("unknown fields %q (want summary or full)", fields)
+	}
+
+	if s := values.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxChartPageSize {
+			return q, fmt.Errorf("limit %q is out of range (want 1-%d)", s, maxChartPageSize)
+		}
+		q.limit = limit
+	}
+	if s := values.Get("cursor"); s != "" {
+		c, err := decodeCursor(s)
+		if err != nil {
+			return q, err
+		}
+		if c.Sort != q.param {
+			return q, errors.New("cursor was made for a different sort")
+		}
+		q.after = c
+	}
+	return q, nil
+}
+
+// cursor returns the cursor positioned at chart
+func (q chartQuery) cursor(chart *Chart) chartCursor {
+	c := chartCursor{Sort: q.param, ID: chart.ID}
+	switch q.sort {
+	case sortTitle:
+		c.Title = chart.Title
+	case sortCreatedAt:
+		c.Time = chart.CreatedAt
+	case sortUpdatedAt:
+		c.Time = chart.UpdatedAt
+	}
+	return c
+}
+
+// compare orders two cursor positions by the query's sort, breaking ties
+// by ID so that every chart has a fixed place
+func (q chartQuery) compare(a, b chartCursor) int {
+	var c int
+	switch q.sort {
+	case sortTitle:
+		c = strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+		if c == 0 {
+			c = strings.Compare(a.Title, b.Title)
+		}
+	default:
+		c = a.Time.Compare(b.Time)
+	}
+	if q.desc {
+		c = -c
+	}
+	if c == 0 {
+		c = strings.Compare(a.ID, b.ID)
+	}
+	return c
+}
+
+// matches reports whether every search word appears in the chart's title
+// or in the name, title or description of one of its categories, tasks or
+// milestones
+func (q chartQuery) matches(chart *Chart) bool {
+	if len(q.words) == 0 {
+		return true
+	}
+	texts := []string{chart.Title}
+	for _, cat := range chart.Categories {
+		texts = append(texts, cat.Name)
+		for _, task := range allTasks(cat.Tasks) {
+			texts = append(texts, task.Title, task.Description)
+		}
+	}
+	for _, m := range chart.Milestones {
+		texts = append(texts, m.Title, m.Description)
+	}
+	text := strings.ToLower(strings.Join(texts, "\n"))
+	for _, word := range q.words {
+		if !strings.Contains(text, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// page sorts and filters the charts and cuts out the requested page. It
+// also returns the number of matching charts and whether more follow.
+func (q chartQuery) page(charts []*Chart) ([]*Chart, int, bool) {
+	matched := make([]*Chart, 0, len(charts))
+	for _, chart := range charts {
+		if q.matches(chart) {
+			matched = append(matched, chart)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return q.compare(q.cursor(matched[i]), q.cursor(matched[j])) < 0
+	})
+
+	page := matched
+	if q.after != nil {
+		start := sort.Search(len(page), func(i int) bool {
+			return q.compare(q.cursor(page[i]), *q.after) > 0
+		})
+		page = page[start:]
+	}
+	if q.limit > 0 && len(page) > q.limit {
+		return page[:q.limit], len(matched), true
+	}
+	return page, len(matched), false
+}
+
+func getChartsHandler(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	q, err := parseChartQuery(values)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	storeMux.RLock()
+	all := store.GetAll()
+	storeMux.RUnlock()
+
+	charts, total, more := q.page(all)
+	if more {
+		values.Set("cursor", q.cursor(charts[len(charts)-1]).encode())
+		next := url.URL{Path: r.URL.Path, RawQuery: values.Encode()}
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.String()))
+	}
+	w.Header().Set(totalCountHeader, strconv.Itoa(total))
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	if !q.summary {
+		json.NewEncoder(w).Encode(charts)
+		return
+	}
+	summaries := make([]ChartSummary, len(charts))
+	for i, chart := range charts {
+		summaries[i] = summarizeChart(chart)
+	}
+	json.NewEncoder(w).Encode(summaries)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Config holds the server settings. Values are resolved in order of
+// increasing precedence: defaults, config file, environment, flags.
+type Config struct {
+	ListenAddr       string `json:"listenAddr"`
+	DataDir          string `json:"dataDir"`
+	StaticDir        string `json:"staticDir"`
+	Storage          string `json:"storage"`
+	LogLevel         string `json:"logLevel"`
+	ExportMaxColumns int    `json:"exportMaxColumns"`
+	ExportMaxTasks   int    `json:"exportMaxTasks"`
+	WorkloadCapacity int    `json:"workloadCapacity"`
+	ImportJSON       string `json:"-"`
+	PrintConfig      bool   `json:"-"`
+}
+
+const (
+	storageJSON   = "json"
+	storageSQLite = "sqlite"
+)
+
+var logLevels = []string{"debug", "info", "warn", "error"}
+
+func defaultConfig() Config {
+	listenAddr := ":8080"
+	if port := os.Getenv("PORT"); port != "" {
+		listenAddr = ":" + port
+	}
+	return Config{
+		ListenAddr:       listenAddr,
+		DataDir:          ".",
+		StaticDir:        "./static",
+		Storage:          storageJSON,
+		LogLevel:         "info",
+		ExportMaxColumns: 120,
+		ExportMaxTasks:   2000,
+		WorkloadCapacity: 3,
+	}
+}
+
+// configEnv maps environment variables to the settings they override
+var configEnv = []struct {
+	name  string
+	apply func(c *Config, v string) error
+}{
+	{"GANTT_LISTEN_ADDR", func(c *Config, v string) error { c.ListenAddr = v; return nil }},
+	{"GANTT_DATA_DIR", func(c *Config, v string) error { c.DataDir = v; return nil }},
+	{"GANTT_STATIC_DIR", func(c *Config, v string) error { c.StaticDir = v; return nil }},
+	{"GANTT_STORAGE", func(c *Config, v string) error { c.Storage = v; return nil }},
+	{"GANTT_LOG_LEVEL", func(c *Config, v string) error { c.LogLevel = v; return nil }},
+	{"GANTT_EXPORT_MAX_COLUMNS", func(c *Config, v string) error { return parseIntSetting(&c.ExportMaxColumns, v) }},
+	{"GANTT_EXPORT_MAX_TASKS", func(c *Config, v string) error { return parseIntSetting(&c.ExportMaxTasks, v) }},
+	{"GANTT_WORKLOAD_CAPACITY", func(c *Config, v string) error { return parseIntSetting(&c.WorkloadCapacity, v) }},
+}
+
+// loadConfig resolves the configuration from a config file, the
+// environment and the given command line arguments
+func loadConfig(args []string) (*Config, error) {
+	defaults := defaultConfig()
+
+	fs := flag.NewFlagSet("go-ghant", flag.ContinueOnError)
+	var flags Config
+	configFile := fs.String("config", os.Getenv("GANTT_CONFIG"), "path to a JSON config file (env GANTT_CONFIG)")
+	fs.StringVar(&flags.ListenAddr, "listen", defaults.ListenAddr, "address to listen on (env GANTT_LISTEN_ADDR)")
+	fs.StringVar(&flags.DataDir, "data-dir", defaults.DataDir, "directory holding charts.json or charts.db (env GANTT_DATA_DIR)")
+	fs.StringVar(&flags.StaticDir, "static-dir", defaults.StaticDir, "directory of the web UI files (env GANTT_STATIC_DIR)")
+	fs.StringVar(&flags.Storage, "storage", defaults.Storage, "storage backend: json or sqlite (env GANTT_STORAGE)")
+	fs.StringVar(&flags.LogLevel, "log-level", defaults.LogLevel, "log level: debug, info, warn or error (env GANTT_LOG_LEVEL)")
+	fs.IntVar(&flags.ExportMaxColumns, "export-max-columns", defaults.ExportMaxColumns, "maximum timeline columns in an export, counted in quarters and scaled up for finer granularities (env GANTT_EXPORT_MAX_COLUMNS)")
+	fs.IntVar(&flags.ExportMaxTasks, "export-max-tasks", defaults.ExportMaxTasks, "maximum tasks in an export (env GANTT_EXPORT_MAX_TASKS)")
+	fs.IntVar(&flags.WorkloadCapacity, "workload-capacity", defaults.WorkloadCapacity, "parallel tasks per person per quarter before the workload report flags over-allocation (env GANTT_WORKLOAD_CAPACITY)")
+	fs.StringVar(&flags.ImportJSON, "import-json", "", "import charts from a charts.json file into the SQLite store and exit")
+	fs.BoolVar(&flags.PrintConfig, "print-config", false, "print the resolved configuration and exit")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := defaults
+	if *configFile != "" {
+		f, err := os.Open(*configFile)
+		if err != nil {
+			return nil, err
+		}
+		dec := json.NewDecoder(f)
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&cfg)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("config file %s: %w", *configFile, err)
+		}
+	}
+
+	for _, env := range configEnv {
+		if v, ok := os.LookupEnv(env.name); ok {
+			if err := env.apply(&cfg, v); err != nil {
+				return nil, fmt.Errorf("%s: %w", env.name, err)
+			}
+		}
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "listen":
+			cfg.ListenAddr = flags.ListenAddr
+		case "data-dir":
+			cfg.DataDir = flags.DataDir
+		case "static-dir":
+			cfg.StaticDir = flags.StaticDir
+		case "storage":
+			cfg.Storage = flags.Storage
+		case "log-level":
+			cfg.LogLevel = flags.LogLevel
+		case "export-max-columns":
+			cfg.ExportMaxColumns = flags.ExportMaxColumns
+		case "export-max-tasks":
+			cfg.ExportMaxTasks = flags.ExportMaxTasks
+		case "workload-capacity":
+			cfg.WorkloadCapacity = flags.WorkloadCapacity
+		}
+	})
+	cfg.ImportJSON = flags.ImportJSON
+	cfg.PrintConfig = flags.PrintConfig
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Validate reports every invalid setting at once
+func (c *Config) Validate() error {
+	var errs []error
+
+	if _, _, err := net.SplitHostPort(c.ListenAddr); err != nil {
+		errs = append(errs, fmt.Errorf("listen address %q: %w", c.ListenAddr, err))
+	}
+	if c.DataDir == "" {
+		errs = append(errs, errors.New("data directory must not be empty"))
+	}
+	if info, err := os.Stat(c.StaticDir); err != nil || !info.IsDir() {
+		errs = append(errs, fmt.Errorf("static directory %q does not exist", c.StaticDir))
+	}
+	if c.Storage != storageJSON && c.Storage != storageSQLite {
+		errs = append(errs, fmt.Errorf("unknown storage backend %q (want %s or %s)", c.Storage, storageJSON, storageSQLite))
+	}
+	if !isLogLevel(c.LogLevel) {
+		errs = append(errs, fmt.Errorf("unknown log level %q (want one of %s)", c.LogLevel, strings.Join(logLevels, ", ")))
+	}
+	if c.ExportMaxColumns <= 0 {
+		errs = append(errs, fmt.Errorf("export max columns must be positive, got %d", c.ExportMaxColumns))
+	}
+	if c.ExportMaxTasks <= 0 {
+		errs = append(errs, fmt.Errorf("export max tasks must be positive, got %d", c.ExportMaxTasks))
+	}
+	if c.WorkloadCapacity <= 0 {
+		errs = append(errs, fmt.Errorf("workload capacity must be positive, got %d", c.WorkloadCapacity))
+	}
+	if c.ImportJSON != "" && c.Storage != storageSQLite {
+		errs = append(errs, errors.New("-import-json requires the sqlite storage backend"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// DataFile returns the path of the file used by the configured backend
+func (c *Config) DataFile() string {
+	if c.Storage == storageSQLite {
+		return filepath.Join(c.DataDir, "charts.db")
+	}
+	return filepath.Join(c.DataDir, "charts.json")
+}
+
+func isLogLevel(level string) bool {
+	for _, l := range logLevels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+func parseIntSetting(dst *int, v string) error {
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return err
+	}
+	*dst = n
+	return nil
+}
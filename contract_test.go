@@ -0,0 +1,501 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// contractStep is one request of the contract session and the status it
+// should get
+type contractStep struct {
+	method, path string
+	body         string
+	contentType  string // defaults to JSON when there is a body
+	headers      map[string]string
+	want         int
+}
+
+// contractChart is the chart the contract session creates. It uses most
+// of the chart model so that the generated schemas are checked against
+// real responses.
+const contractChart = `{
+	"id": "contract", "title": "Contract", "owner": "ada",
+	"startYear": 2025, "startQuarter": 1, "endYear": 2025, "endQuarter": 4,
+	"granularity": "month",
+	"fields": [{"key": "cost", "name": "Cost", "type": "number"}, {"key": "tier", "name": "Tier", "type": "enum", "options": ["a", "b"]}],
+	"categories": [{
+		"id": "eng", "name": "Engineering", "color": "#3498db", "owner": "ada", "tags": ["core"],
+		"tasks": [
+			{"id": "design", "title": "Design", "description": "Specs",
+			 "startYear": 2025, "startQuarter": 1, "endYear": 2025, "endQuarter": 1,
+			 "status": "done", "progress": 100, "assignees": ["ada"], "fields": {"cost": 10, "tier": "a"}},
+			{"id": "build", "title": "Build", "tags": ["api"],
+			 "subtasks": [
+				{"id": "api", "title": "API", "startDate": "2025-04-01", "endDate": "2025-06-30", "status": "in-progress", "progress": 40,
+				 "dependencies": [{"taskId": "design", "type": "FS"}]},
+				{"id": "ui", "title": "UI", "startYear": 2025, "startQuarter": 3, "endYear": 2025, "endQuarter": 3, "color": "#e67e22",
+				 "dependencies": [{"taskId": "api", "type": "SS"}]}
+			 ]}
+		]
+	}],
+	"milestones": [{"id": "launch", "title": "Launch", "date": "2025-10-01", "categoryId": "eng", "color": "#c0392b"}]
+}`
+
+// contractSession exercises every operation, successes and the failures
+// the handlers document
+var contractSession = []contractStep{
+	{method: "GET", path: "/api/openapi.json", want: http.StatusOK},
+
+	{method: "POST", path: "/api/people", body: `{"id": "ada", "name": "Ada Lovelace", "email": "ada@example.com", "team": "Core"}`, want: http.StatusCreated},
+	{method: "POST", path: "/api/people", body: `{"id": "ada", "name": "Ada Lovelace"}`, want: http.StatusConflict},
+	{method: "POST", path: "/api/people", body: `{"name": " "}`, want: http.StatusUnprocessableEntity},
+	{method: "POST", path: "/api/people", body: `{"id": "bob", "name": "Bob"}`, want: http.StatusCreated},
+	{method: "GET", path: "/api/people", want: http.StatusOK},
+	{method: "GET", path: "/api/people/ada", want: http.StatusOK},
+	{method: "GET", path: "/api/people/nobody", want: http.StatusNotFound},
+	{method: "PUT", path: "/api/people/ada", body: `{"name": "Ada King", "team": "Core"}`, want: http.StatusOK},
+	{method: "PUT", path: "/api/people/ada", body: `[]`, want: http.StatusBadRequest},
+
+	{method: "POST", path: "/api/charts", body: contractChart, want: http.StatusCreated},
+	{method: "POST", path: "/api/charts", body: contractChart, want: http.StatusConflict},
+	{method: "POST", path: "/api/charts", body: `{"title": "Bad", "startYear": 2025, "startQuarter": 5, "endYear": 2024, "endQuarter": 1}`, want: http.StatusUnprocessableEntity},
+	{method: "POST", path: "/api/charts", body: `{"title": `, want: http.StatusBadRequest},
+	{method: "POST", path: "/api/charts", body: `{"id": "empty", "title": "Empty", "startYear": 2025, "startQuarter": 1, "endYear": 2025, "endQuarter": 1}`, want: http.StatusCreated},
+	{method: "GET", path: "/api/charts", want: http.StatusOK},
+	{method: "GET", path: "/api/charts?fields=summary&sort=-title&limit=1&q=contract", want: http.StatusOK},
+	{method: "GET", path: "/api/charts?sort=size", want: http.StatusBadRequest},
+	{method: "GET", path: "/api/charts/contract", want: http.StatusOK},
+	{method: "GET", path: "/api/charts/missing", want: http.StatusNotFound},
+	{method: "PUT", path: "/api/charts/empty", body: `{"title": "Still empty", "startYear": 2025, "startQuarter": 1, "endYear": 2025, "endQuarter": 2}`,
+		headers: map[string]string{ifMatchHeader: `"1"`, authorHeader: "ada"}, want: http.StatusOK},
+	{method: "PUT", path: "/api/charts/empty", body: `{"title": "Stale", "startYear": 2025, "startQuarter": 1, "endYear": 2025, "endQuarter": 2}`,
+		headers: map[string]string{ifMatchHeader: `"1"`}, want: http.StatusPreconditionFailed},
+	{method: "PATCH", path: "/api/charts/contract", body: `{"title": "Contract chart"}`, contentType: mergePatchContentType, want: http.StatusOK},
+	{method: "PATCH", path: "/api/charts/contract", body: `[{"op": "add", "path": "/categories/0/tasks/0/tags", "value": ["spec"]}]`, contentType: jsonPatchContentType, want: http.StatusOK},
+	{method: "PATCH", path: "/api/charts/contract", body: `[{"op": "test", "path": "/title", "value": "Other"}]`, contentType: jsonPatchContentType, want: http.StatusConflict},
+	{method: "PATCH", path: "/api/charts/contract", body: `{"op": "add"}`, contentType: jsonPatchContentType, want: http.StatusBadRequest},
+	{method: "PATCH", path: "/api/charts/contract", body: `{"endQuarter": 9}`, contentType: mergePatchContentType, want: http.StatusUnprocessableEntity},
+	{method: "PATCH", path: "/api/charts/contract", body: `title=x`, contentType: "text/plain", want: http.StatusUnsupportedMediaType},
+	{method: "PATCH", path: "/api/charts/missing", body: `{}`, contentType: mergePatchContentType, want: http.StatusNotFound},
+
+	{method: "GET", path: "/api/charts/contract/revisions", want: http.StatusOK},
+	{method: "GET", path: "/api/charts/missing/revisions", want: http.StatusNotFound},
+	{method: "GET", path: "/api/charts/contract/revisions/1", want: http.StatusOK},
+	{method: "GET", path: "/api/charts/contract/revisions/99", want: http.StatusNotFound},
+	{method: "GET", path: "/api/charts/contract/revisions/diff?from=1&to=3", want: http.StatusOK},
+	{method: "GET", path: "/api/charts/contract/revisions/diff?from=first&to=3", want: http.StatusBadRequest},
+	{method: "POST", path: "/api/charts/contract/revisions/2/restore", headers: map[string]string{authorHeader: "ada"}, want: http.StatusOK},
+	{method: "POST", path: "/api/charts/contract/revisions/1/restore", headers: map[string]string{ifMatchHeader: `"1"`}, want: http.StatusPreconditionFailed},
+
+	{method: "GET", path: "/api/charts/contract/milestones", want: http.StatusOK},
+	{method: "POST", path: "/api/charts/contract/milestones", body: `{"id": "beta", "title": "Beta", "date": "2025-07-01"}`, want: http.StatusCreated},
+	{method: "POST", path: "/api/charts/contract/milestones", body: `{"id": "beta", "title": "Beta", "date": "2025-07-01"}`, want: http.StatusConflict},
+	{method: "GET", path: "/api/charts/contract/milestones/beta", want: http.StatusOK},
+	{method: "PUT", path: "/api/charts/contract/milestones/beta", body: `{"title": "Beta 2", "date": "2025-07-15"}`, want: http.StatusOK},
+	{method: "PUT", path: "/api/charts/contract/milestones/beta", body: `{"title": "Beta 2", "date": "someday"}`, want: http.StatusUnprocessableEntity},
+	{method: "DELETE", path: "/api/charts/contract/milestones/beta", want: http.StatusNoContent},
+	{method: "GET", path: "/api/charts/contract/milestones/beta", want: http.StatusNotFound},
+
+	{method: "GET", path: "/api/charts/contract/categories", want: http.StatusOK},
+	{method: "POST", path: "/api/charts/contract/categories", body: `{"id": "ops", "name": "Operations", "color": "#2ecc71"}`, want: http.StatusCreated},
+	{method: "POST", path: "/api/charts/contract/categories", body: `{"id": "ops", "name": "Operations"}`, want: http.StatusConflict},
+	{method: "GET", path: "/api/charts/contract/categories/ops", want: http.StatusOK},
+	{method: "PATCH", path: "/api/charts/contract/categories/ops", body: `{"name": "Ops"}`, contentType: mergePatchContentType, want: http.StatusOK},
+	{method: "PATCH", path: "/api/charts/contract/categories/ops", body: `{"color": "red"}`, contentType: mergePatchContentType, want: http.StatusUnprocessableEntity},
+	{method: "PATCH", path: "/api/charts/contract/categories/ops", body: `[1]`, contentType: mergePatchContentType, want: http.StatusBadRequest},
+	{method: "PATCH", path: "/api/charts/contract/categories/ops", body: `[{"op": "replace", "path": "/name", "value": "Ops team"}]`, contentType: jsonPatchContentType, want: http.StatusOK},
+	{method: "PATCH", path: "/api/charts/contract/categories/ops", body: `[{"op": "remove", "path": "/owner"}]`, contentType: jsonPatchContentType, want: http.StatusConflict},
+	{method: "PATCH", path: "/api/charts/contract/categories/ops", body: `{"name": "Ops"}`, contentType: jsonContentType, want: http.StatusUnsupportedMediaType},
+
+	{method: "GET", path: "/api/charts/contract/categories/eng/tasks", want: http.StatusOK},
+	{method: "POST", path: "/api/charts/contract/categories/ops/tasks", body: `{"id": "deploy", "title": "Deploy", "startYear": 2025, "startQuarter": 4, "endYear": 2025, "endQuarter": 4}`, want: http.StatusCreated},
+	{method: "POST", path: "/api/charts/contract/categories/ops/tasks?parent=deploy", body: `{"id": "smoke", "title": "Smoke test", "startDate": "2025-11-03", "endDate": "2025-11-07", "assignees": ["ada"]}`, want: http.StatusCreated},
+	{method: "POST", path: "/api/charts/contract/categories/ops/tasks", body: `{"id": "design", "title": "Again"}`, want: http.StatusConflict},
+	{method: "POST", path: "/api/charts/contract/categories/gone/tasks", body: `{"title": "Lost"}`, want: http.StatusNotFound},
+	{method: "GET", path: "/api/charts/contract/categories/ops/tasks/smoke", want: http.StatusOK},
+	{method: "PATCH", path: "/api/charts/contract/categories/ops/tasks/smoke", body: `{"progress": 50, "status": "in-progress"}`, contentType: mergePatchContentType, want: http.StatusOK},
+	{method: "PATCH", path: "/api/charts/contract/categories/ops/tasks/smoke", body: `{"progress": 150}`, contentType: mergePatchContentType, want: http.StatusUnprocessableEntity},
+	{method: "PATCH", path: "/api/charts/contract/categories/ops/tasks/smoke", body: `[{"op": "add", "path": "/tags", "value": ["qa"]}]`, contentType: jsonPatchContentType, want: http.StatusOK},
+	{method: "PATCH", path: "/api/charts/contract/categories/ops/tasks/smoke", body: `[{"op": "test", "path": "/title", "value": "Other"}]`, contentType: jsonPatchContentType, want: http.StatusConflict},
+	{method: "PATCH", path: "/api/charts/contract/categories/ops/tasks/smoke", body: `{"progress": 60}`, want: http.StatusUnsupportedMediaType},
+	{method: "POST", path: "/api/charts/contract/categories/ops/tasks/smoke/move", body: `{"categoryId": "eng", "position": 0}`, want: http.StatusOK},
+	{method: "POST", path: "/api/charts/contract/categories/eng/tasks/build/move", body: `{"parentId": "api"}`, want: http.StatusUnprocessableEntity},
+	{method: "DELETE", path: "/api/charts/contract/categories/ops/tasks/deploy", want: http.StatusNoContent},
+	{method: "GET", path: "/api/charts/contract/categories/ops/tasks/deploy", want: http.StatusNotFound},
+	{method: "DELETE", path: "/api/charts/contract/categories/ops", want: http.StatusNoContent},
+
+	{method: "GET", path: "/api/charts/contract/schedule", want: http.StatusOK},
+	{method: "GET", path: "/api/charts/missing/schedule", want: http.StatusNotFound},
+
+	{method: "POST", path: "/api/charts/contract/baselines", body: `{"name": "Plan"}`, want: http.StatusCreated},
+	{method: "POST", path: "/api/charts/contract/baselines", body: `{"name": "Plan"}`, want: http.StatusConflict},
+	{method: "POST", path: "/api/charts/contract/baselines", body: `{"name": ""}`, want: http.StatusUnprocessableEntity},
+	{method: "GET", path: "/api/charts/contract/baselines", want: http.StatusOK},
+	{method: "GET", path: "/api/charts/contract/baselines/Plan", want: http.StatusOK},
+	{method: "PATCH", path: "/api/charts/contract/categories/eng/tasks/ui", body: `{"endQuarter": 4}`, contentType: mergePatchContentType, want: http.StatusOK},
+	{method: "GET", path: "/api/charts/contract/baselines/Plan/slip", want: http.StatusOK},
+	{method: "GET", path: "/api/charts/contract/baselines/Later", want: http.StatusNotFound},
+
+	{method: "GET", path: "/api/charts/contract/export/svg?highlight=critical&labels=progress,assignees,field.cost", want: http.StatusOK},
+	{method: "GET", path: "/api/charts/contract/export/png?status=done,in-progress&tags=core", want: http.StatusOK},
+	{method: "GET", path: "/api/charts/contract/export/pdf?baseline=Plan&groupBy=field.tier", want: http.StatusOK},
+	{method: "GET", path: "/api/charts/contract/export/svg?status=bogus", want: http.StatusBadRequest},
+	{method: "GET", path: "/api/charts/missing/export/png", want: http.StatusNotFound},
+	{method: "DELETE", path: "/api/charts/contract/baselines/Plan", want: http.StatusNoContent},
+	{method: "DELETE", path: "/api/charts/contract/baselines/Plan", want: http.StatusNotFound},
+
+	{method: "GET", path: "/api/people/ada/tasks", want: http.StatusOK},
+	{method: "GET", path: "/api/people/nobody/tasks", want: http.StatusNotFound},
+	{method: "GET", path: "/api/workload?capacity=1&from=2025-Q1&to=2025-Q4", want: http.StatusOK},
+	{method: "GET", path: "/api/workload?capacity=0", want: http.StatusBadRequest},
+	{method: "GET", path: "/api/workload/svg", want: http.StatusOK},
+	{method: "GET", path: "/api/workload/png?from=2025-Q2", want: http.StatusOK},
+	{method: "GET", path: "/api/workload/png?from=Q2", want: http.StatusBadRequest},
+
+	{method: "DELETE", path: "/api/people/ada", want: http.StatusConflict},
+	{method: "DELETE", path: "/api/people/bob", want: http.StatusNoContent},
+	{method: "DELETE", path: "/api/people/bob", want: http.StatusNotFound},
+	{method: "DELETE", path: "/api/charts/contract", headers: map[string]string{ifMatchHeader: `"1"`}, want: http.StatusPreconditionFailed},
+	{method: "DELETE", path: "/api/charts/contract", want: http.StatusNoContent},
+	{method: "DELETE", path: "/api/charts/empty", want: http.StatusNoContent},
+}
+
+// TestContract runs contractSession against apiRouter with a scratch
+// store and checks the API against its OpenAPI document: the document and
+// the router must list the same routes, every operation must be exercised,
+// and every response must have the status the session expects, be
+// documented for its operation with its media type and headers, and have
+// a body that validates against the documented schema.
+func TestContract(t *testing.T) {
+	useTestStore(t)
+
+	router := apiRouter()
+	var doc map[string]interface{}
+	if err := json.Unmarshal(openAPIJSON(), &doc); err != nil {
+		t.Fatalf("OpenAPI document: %v", err)
+	}
+	paths, _ := doc["paths"].(map[string]interface{})
+
+	routes, err := routerOperations(router)
+	if err != nil {
+		t.Fatal(err)
+	}
+	documented := make(map[string]bool)
+	for path, item := range paths {
+		for method := range item.(map[string]interface{}) {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+	for _, route := range routes {
+		if !documented[route] {
+			t.Errorf("%s: route is not in the OpenAPI document", route)
+		}
+	}
+
+	covered := make(map[string]bool)
+	for _, step := range contractSession {
+		route, err := checkStep(router, doc, step)
+		if err != nil {
+			t.Errorf("%s %s: %v", step.method, step.path, err)
+		}
+		covered[route] = true
+	}
+
+	var missing []string
+	for op := range documented {
+		if !covered[op] {
+			missing = append(missing, op)
+		}
+	}
+	sort.Strings(missing)
+	for _, op := range missing {
+		if !containsString(routes, op) {
+			t.Errorf("%s: documented but not routed", op)
+		} else {
+			t.Errorf("%s: not exercised by the contract session", op)
+		}
+	}
+}
+
+var routeVariablePattern = regexp.MustCompile(`\{(\w+):[^}]*\}`)
+
+// openAPIPath turns a mux path template into an OpenAPI path by dropping
+// the variables' patterns
+func openAPIPath(template string) string {
+	return routeVariablePattern.ReplaceAllString(template, "{$1}")
+}
+
+// routerOperations lists the router's routes as "METHOD /path" in OpenAPI
+// form
+func routerOperations(router *mux.Router) ([]string, error) {
+	var ops []string
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil // a subrouter prefix
+		}
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		for _, method := range methods {
+			ops = append(ops, method+" "+openAPIPath(template))
+		}
+		return nil
+	})
+	return ops, err
+}
+
+// checkStep sends one request of the session and checks the response
+// against the document. It returns the operation the request was routed
+// to.
+func checkStep(router *mux.Router, doc map[string]interface{}, step contractStep) (string, error) {
+	req := httptest.NewRequest(step.method, step.path, strings.NewReader(step.body))
+	if step.body != "" {
+		contentType := step.contentType
+		if contentType == "" {
+			contentType = jsonContentType
+		}
+		req.Header.Set(contentTypeHeader, contentType)
+	}
+	for name, value := range step.headers {
+		req.Header.Set(name, value)
+	}
+
+	var match mux.RouteMatch
+	if !router.Match(req, &match) || match.Route == nil {
+		return "", errors.New("no route matches")
+	}
+	template, _ := match.Route.GetPathTemplate()
+	path := openAPIPath(template)
+	route := step.method + " " + path
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	resp := rec.Result()
+
+	if resp.StatusCode != step.want {
+		return route, fmt.Errorf("got status %d, want %d: %s", resp.StatusCode, step.want, strings.TrimSpace(rec.Body.String()))
+	}
+	op, _ := lookup(doc, "paths", path, strings.ToLower(step.method)).(map[string]interface{})
+	if op == nil {
+		return route, errors.New("operation is not documented")
+	}
+	documented, _ := lookup(op, "responses", fmt.Sprint(resp.StatusCode)).(map[string]interface{})
+	if documented == nil {
+		return route, fmt.Errorf("status %d is not documented", resp.StatusCode)
+	}
+
+	headers, _ := documented["headers"].(map[string]interface{})
+	for name, h := range headers {
+		if required, _ := h.(map[string]interface{})["required"].(bool); required && resp.Header.Get(name) == "" {
+			return route, fmt.Errorf("response lacks the %s header", name)
+		}
+	}
+
+	content, _ := documented["content"].(map[string]interface{})
+	if len(content) == 0 {
+		if rec.Body.Len() > 0 {
+			return route, errors.New("response has a body, but none is documented")
+		}
+		return route, nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get(contentTypeHeader))
+	media, _ := content[mediaType].(map[string]interface{})
+	if media == nil {
+		return route, fmt.Errorf("media type %q is not documented for status %d", mediaType, resp.StatusCode)
+	}
+	if rec.Body.Len() == 0 {
+		return route, errors.New("response body is empty")
+	}
+	if mediaType != jsonContentType && mediaType != problemContentType {
+		return route, nil
+	}
+
+	var body interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		return route, fmt.Errorf("response body is not JSON: %w", err)
+	}
+	schema, _ := media["schema"].(map[string]interface{})
+	if problems := validateSchema(doc, schema, body, ""); len(problems) > 0 {
+		return route, errors.New(strings.Join(problems, "; "))
+	}
+	return route, nil
+}
+
+// lookup follows a path of member names through generic JSON objects
+func lookup(v interface{}, names ...string) interface{} {
+	for _, name := range names {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = obj[name]
+	}
+	return v
+}
+
+// jsonType names the JSON Schema type of a generic JSON value
+func jsonType(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	}
+	return "object"
+}
+
+// validateSchema checks a generic JSON value against the subset of JSON
+// Schema the OpenAPI document uses, returning a message for each failure
+// prefixed with the JSON Pointer of the offending value
+func validateSchema(doc, schema map[string]interface{}, v interface{}, path string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		target, _ := lookup(doc, strings.Split(strings.TrimPrefix(ref, "#/"), "/")...).(map[string]interface{})
+		if target == nil {
+			return []string{fmt.Sprintf("%s: unresolved $ref %s", path, ref)}
+		}
+		return validateSchema(doc, target, v, path)
+	}
+	fail := func(format string, args ...interface{}) []string {
+		where := path
+		if where == "" {
+			where = "/"
+		}
+		return []string{where + ": " + fmt.Sprintf(format, args...)}
+	}
+
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok {
+		for _, s := range anyOf {
+			if len(validateSchema(doc, s.(map[string]interface{}), v, path)) == 0 {
+				return nil
+			}
+		}
+		return fail("matches none of the allowed schemas")
+	}
+
+	if typ, ok := schema["type"]; ok {
+		actual := jsonType(v)
+		allowed := false
+		for _, t := range toStrings(typ) {
+			if t == actual || (t == "number" && actual == "integer") {
+				allowed = true
+			}
+		}
+		if !allowed {
+			return fail("is %s, want %s", actual, strings.Join(toStrings(typ), " or "))
+		}
+	}
+	if enum, ok := schema["enum"]; ok && !containsString(toStrings(enum), fmt.Sprint(v)) {
+		return fail("%v is not one of %s", v, strings.Join(toStrings(enum), ", "))
+	}
+
+	switch v := v.(type) {
+	case float64:
+		if min, ok := schema["minimum"].(float64); ok && v < min {
+			return fail("%v is less than %v", v, min)
+		}
+		if max, ok := schema["maximum"].(float64); ok && v > max {
+			return fail("%v is more than %v", v, max)
+		}
+	case string:
+		if min, ok := schema["minLength"].(float64); ok && float64(len([]rune(v))) < min {
+			return fail("%q is shorter than %v", v, min)
+		}
+		if pattern, ok := schema["pattern"].(string); ok && !regexp.MustCompile(pattern).MatchString(v) {
+			return fail("%q does not match %s", v, pattern)
+		}
+		if !validFormat(schema["format"], v) {
+			return fail("%q is not a valid %v", v, schema["format"])
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			var problems []string
+			for i, item := range v {
+				problems = append(problems, validateSchema(doc, items, item, fmt.Sprintf("%s/%d", path, i))...)
+			}
+			return problems
+		}
+	case map[string]interface{}:
+		var problems []string
+		for _, name := range toStrings(schema["required"]) {
+			if _, ok := v[name]; !ok {
+				problems = append(problems, fail("lacks required member %q", name)...)
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			memberPath := path + "/" + pointerToken(name)
+			if s, ok := properties[name].(map[string]interface{}); ok {
+				problems = append(problems, validateSchema(doc, s, v[name], memberPath)...)
+				continue
+			}
+			switch extra := schema["additionalProperties"].(type) {
+			case bool:
+				if !extra {
+					problems = append(problems, fail("has undocumented member %q", name)...)
+				}
+			case map[string]interface{}:
+				problems = append(problems, validateSchema(doc, extra, v[name], memberPath)...)
+			}
+		}
+		return problems
+	}
+	return nil
+}
+
+// validFormat checks the string formats the document uses
+func validFormat(format interface{}, s string) bool {
+	var err error
+	switch format {
+	case "date-time":
+		_, err = time.Parse(time.RFC3339, s)
+	case "date":
+		_, err = time.Parse(dateLayout, s)
+	case "email":
+		_, err = mail.ParseAddress(s)
+	}
+	return err == nil
+}
+
+// toStrings reads a string or array of strings from a generic JSON value
+func toStrings(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		list := make([]string, len(v))
+		for i, item := range v {
+			list[i] = fmt.Sprint(item)
+		}
+		return list
+	}
+	return nil
+}
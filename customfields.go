@@ -0,0 +1,260 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Custom field types
+const (
+	fieldText   = "text"
+	fieldNumber = "number"
+	fieldEnum   = "enum"
+	fieldDate   = "date"
+	fieldURL    = "url"
+)
+
+// fieldParamPrefix marks a custom field in export query parameters, e.g.
+// ?field.risk=high or ?groupBy=field.risk
+const fieldParamPrefix = "field."
+
+// fieldKeyPattern keeps keys usable in query parameters
+var fieldKeyPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+
+// groupColors colours the categories of a chart grouped by a custom field
+var groupColors = []string{"#3498db", "#2ecc71", "#e67e22", "#9b59b6", "#1abc9c", "#e74c3c", "#f1c40f", "#34495e"}
+
+// ungroupedColor colours the category of tasks without a value for the
+// grouping field
+const ungroupedColor = "#95a5a6"
+
+func isFieldType(t string) bool {
+	switch t {
+	case fieldText, fieldNumber, fieldEnum, fieldDate, fieldURL:
+		return true
+	}
+	return false
+}
+
+// findField returns the chart's definition of a custom field, or nil
+func findField(chart *Chart, key string) *FieldDef {
+	for i := range chart.Fields {
+		if chart.Fields[i].Key == key {
+			return &chart.Fields[i]
+		}
+	}
+	return nil
+}
+
+// validateCustomFields checks the chart's field definitions and that every
+// task's values are defined and of the right type
+func validateCustomFields(chart *Chart) error {
+	var errs []error
+	keys := make(map[string]bool, len(chart.Fields))
+	for i, def := range chart.Fields {
+		path := fmt.Sprintf("/fields/%d", i)
+		switch {
+		case !fieldKeyPattern.MatchString(def.Key):
+			errs = append(errs, fieldErrorf(path+"/key", "custom field key %q must start with a letter and hold only letters, digits, '-' and '_'", def.Key))
+		case keys[def.Key]:
+			errs = append(errs, fieldErrorf(path+"/key", "custom field key %q is used more than once", def.Key))
+		}
+		keys[def.Key] = true
+		if strings.TrimSpace(def.Name) == "" {
+			errs = append(errs, fieldErrorf(path+"/name", "custom field %q needs a name", def.Key))
+		}
+		if !isFieldType(def.Type) {
+			errs = append(errs, fieldErrorf(path+"/type", "custom field %q has unknown type %q (want text, number, enum, date or url)", def.Key, def.Type))
+		}
+		if def.Type == fieldEnum && len(def.Options) == 0 {
+			errs = append(errs, fieldErrorf(path+"/options", "enum field %q needs options", def.Key))
+		}
+		if def.Type != fieldEnum && len(def.Options) > 0 {
+			errs = append(errs, fieldErrorf(path+"/options", "custom field %q has options but is not an enum", def.Key))
+		}
+	}
+
+	walkChartTasks(chart, func(task *Task, path string) {
+		set := make([]string, 0, len(task.Fields))
+		for key := range task.Fields {
+			set = append(set, key)
+		}
+		sort.Strings(set)
+		for _, key := range set {
+			value := task.Fields[key]
+			valuePath := path + "/fields/" + pointerToken(key)
+			def := findField(chart, key)
+			if def == nil {
+				errs = append(errs, fieldErrorf(valuePath, "task %q sets unknown custom field %q", task.Title, key))
+				continue
+			}
+			if err := checkFieldValue(*def, value); err != nil {
+				errs = append(errs, fieldErrorf(valuePath, "task %q field %q: %v", task.Title, key, err))
+			}
+		}
+		for _, def := range chart.Fields {
+			if _, ok := task.Fields[def.Key]; def.Required && !ok {
+				errs = append(errs, fieldErrorf(path+"/fields", "task %q is missing required field %q", task.Title, def.Key))
+			}
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// checkFieldValue checks a value decoded from JSON against its field's type
+func checkFieldValue(def FieldDef, value interface{}) error {
+	if def.Type == fieldNumber {
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("want a number, got %v", value)
+		}
+		return nil
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("want a string, got %v", value)
+	}
+	switch def.Type {
+	case fieldEnum:
+		if !containsString(def.Options, s) {
+			return fmt.Errorf("%q is not one of %s", s, strings.Join(def.Options, ", "))
+		}
+	case fieldDate:
+		if _, ok := parseDate(s); !ok {
+			return fmt.Errorf("invalid date %q (want YYYY-MM-DD)", s)
+		}
+	case fieldURL:
+		if u, err := url.Parse(s); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid URL %q (want an absolute http or https URL)", s)
+		}
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// formatFieldValue formats a custom field value for display and comparison
+func formatFieldValue(value interface{}) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return v
+	}
+	return fmt.Sprint(value)
+}
+
+// fieldParam reads the field key out of a query value such as field.risk
+func fieldParam(chart *Chart, s string) (*FieldDef, error) {
+	key, ok := strings.CutPrefix(s, fieldParamPrefix)
+	if !ok {
+		return nil, fmt.Errorf("%q does not name a custom field (want %s<key>)", s, fieldParamPrefix)
+	}
+	def := findField(chart, key)
+	if def == nil {
+		return nil, fmt.Errorf("unknown custom field %q", key)
+	}
+	return def, nil
+}
+
+// fieldLabels returns the values of the given fields for each task that
+// sets any of them, for printing next to the bars
+func fieldLabels(chart *Chart, defs []*FieldDef) map[string]string {
+	labels := make(map[string]string)
+	for _, cat := range chart.Categories {
+		for _, task := range allTasks(cat.Tasks) {
+			var parts []string
+			for _, def := range defs {
+				if v, ok := task.Fields[def.Key]; ok {
+					parts = append(parts, formatFieldValue(v))
+				}
+			}
+			if len(parts) > 0 {
+				labels[task.ID] = strings.Join(parts, ", ")
+			}
+		}
+	}
+	return labels
+}
+
+// groupByField returns a copy of the chart with its top-level tasks
+// regrouped into one category per value of a custom field, in option
+// order for enums and sorted otherwise. Tasks without a value come last.
+// Subtasks stay with their parent.
+func groupByField(chart *Chart, def FieldDef) *Chart {
+	grouped := cloneChart(chart)
+	byValue := make(map[string][]Task)
+	var values []string
+	var unset []Task
+	for _, cat := range grouped.Categories {
+		for _, task := range cat.Tasks {
+			v, ok := task.Fields[def.Key]
+			if !ok {
+				unset = append(unset, task)
+				continue
+			}
+			text := formatFieldValue(v)
+			if _, seen := byValue[text]; !seen {
+				values = append(values, text)
+			}
+			byValue[text] = append(byValue[text], task)
+		}
+	}
+
+	sort.SliceStable(values, func(i, j int) bool {
+		switch def.Type {
+		case fieldEnum:
+			return indexOf(def.Options, values[i]) < indexOf(def.Options, values[j])
+		case fieldNumber:
+			a, _ := strconv.ParseFloat(values[i], 64)
+			b, _ := strconv.ParseFloat(values[j], 64)
+			return a < b
+		}
+		return values[i] < values[j]
+	})
+
+	grouped.Categories = make([]Category, 0, len(values)+1)
+	for i, v := range values {
+		grouped.Categories = append(grouped.Categories, Category{
+			ID:    fmt.Sprintf("%s%s-%d", fieldParamPrefix, def.Key, i),
+			Name:  fmt.Sprintf("%s: %s", def.Name, v),
+			Color: groupColors[i%len(groupColors)],
+			Tasks: byValue[v],
+		})
+	}
+	if len(unset) > 0 {
+		grouped.Categories = append(grouped.Categories, Category{
+			ID:    fieldParamPrefix + def.Key + "-none",
+			Name:  "No " + def.Name,
+			Color: ungroupedColor,
+			Tasks: unset,
+		})
+	}
+	// The original categories are gone, so their milestones move to the
+	// chart-level row
+	for i := range grouped.Milestones {
+		grouped.Milestones[i].CategoryID = ""
+	}
+	return grouped
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return len(list)
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Dependency types
+const (
+	depFinishToStart  = "FS"
+	depStartToStart   = "SS"
+	depFinishToFinish = "FF"
+)
+
+// dependencyType returns the dependency's type, defaulting to finish-to-start
+func dependencyType(dep Dependency) string {
+	if dep.Type == "" {
+		return depFinishToStart
+	}
+	return dep.Type
+}
+
+// chartTasks indexes every task in the chart by ID
+func chartTasks(chart *Chart) map[string]*Task {
+	tasks := make(map[string]*Task)
+	for i := range chart.Categories {
+		walkTasks(chart.Categories[i].Tasks, func(task *Task, _ int) {
+			tasks[task.ID] = task
+		})
+	}
+	return tasks
+}
+
+// validateDependencies checks that every dependency names another task of
+// the chart with a known type, and that dependencies form no cycle.
+// Summary tasks take their dates from their subtasks, so they can neither
+// depend nor be depended on.
+func validateDependencies(chart *Chart) error {
+	tasks := chartTasks(chart)
+	var errs []error
+
+	walkChartTasks(chart, func(task *Task, path string) {
+		for i, dep := range task.Dependencies {
+			depPath := fmt.Sprintf("%s/dependencies/%d", path, i)
+			switch {
+			case dep.TaskID == task.ID:
+				errs = append(errs, fieldErrorf(depPath+"/taskId", "task %q depends on itself", task.ID))
+			case tasks[dep.TaskID] == nil:
+				errs = append(errs, fieldErrorf(depPath+"/taskId", "task %q depends on unknown task %q", task.ID, dep.TaskID))
+			case isSummary(*task):
+				errs = append(errs, fieldErrorf(depPath, "task %q has subtasks and cannot have dependencies; link its subtasks instead", task.ID))
+			case isSummary(*tasks[dep.TaskID]):
+				errs = append(errs, fieldErrorf(depPath+"/taskId", "task %q depends on task %q, which has subtasks; depend on its subtasks instead", task.ID, dep.TaskID))
+			}
+			switch dependencyType(dep) {
+			case depFinishToStart, depStartToStart, depFinishToFinish:
+			default:
+				errs = append(errs, fieldErrorf(depPath+"/type", "task %q has unknown dependency type %q (want FS, SS or FF)", task.ID, dep.Type))
+			}
+		}
+	})
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	if cycle := findDependencyCycle(chart, tasks); cycle != nil {
+		return fieldErrorf(taskPaths(chart)[cycle[0]]+"/dependencies", "dependency cycle: %s", strings.Join(cycle, " -> "))
+	}
+	return nil
+}
+
+// findDependencyCycle returns the task IDs along a dependency cycle, with
+// the first ID repeated at the end, or nil if the graph is acyclic
+func findDependencyCycle(chart *Chart, tasks map[string]*Task) []string {
+	const (
+		unvisited = iota
+		inProgress
+		done
+	)
+	state := make(map[string]int, len(tasks))
+	var stack []string
+
+	var visit func(id string) []string
+	visit = func(id string) []string {
+		state[id] = inProgress
+		stack = append(stack, id)
+		for _, dep := range tasks[id].Dependencies {
+			switch state[dep.TaskID] {
+			case inProgress:
+				for i, sid := range stack {
+					if sid == dep.TaskID {
+						return append(append([]string{}, stack[i:]...), dep.TaskID)
+					}
+				}
+			case unvisited:
+				if cycle := visit(dep.TaskID); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[id] = done
+		return nil
+	}
+
+	// Walk in chart order so the reported cycle is deterministic
+	for _, cat := range chart.Categories {
+		for _, task := range allTasks(cat.Tasks) {
+			if state[task.ID] == unvisited {
+				if cycle := visit(task.ID); cycle != nil {
+					return cycle
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// barRect is where a task bar was drawn, in the renderer's units
+type barRect struct {
+	x, y, w, h float64
+}
+
+type point struct {
+	x, y float64
+}
+
+// dependencyArrow is a connector routed between two bars
+type dependencyArrow struct {
+	points []point // axis-aligned polyline; the arrowhead is at the last point
+}
+
+// pointsRight reports whether the arrowhead faces right
+func (a dependencyArrow) pointsRight() bool {
+	n := len(a.points)
+	return a.points[n-1].x >= a.points[n-2].x
+}
+
+// dependencyArrows routes a connector for every dependency whose tasks
+// were both drawn. gap is the horizontal clearance kept around bars.
+func dependencyArrows(chart *Chart, bars map[string]barRect, gap float64) []dependencyArrow {
+	var arrows []dependencyArrow
+	for _, cat := range chart.Categories {
+		for _, task := range allTasks(cat.Tasks) {
+			succ, ok := bars[task.ID]
+			if !ok {
+				continue
+			}
+			for _, dep := range task.Dependencies {
+				pred, ok := bars[dep.TaskID]
+				if !ok {
+					continue
+				}
+				arrows = append(arrows, dependencyArrow{points: routeDependency(dependencyType(dep), pred, succ, gap)})
+			}
+		}
+	}
+	return arrows
+}
+
+func routeDependency(depType string, pred, succ barRect, gap float64) []point {
+	sy := pred.y + pred.h/2
+	ey := succ.y + succ.h/2
+
+	switch depType {
+	case depStartToStart:
+		sx, ex := pred.x, succ.x
+		left := min(sx, ex) - gap
+		return []point{{sx, sy}, {left, sy}, {left, ey}, {ex, ey}}
+	case depFinishToFinish:
+		sx, ex := pred.x+pred.w, succ.x+succ.w
+		right := max(sx, ex) + gap
+		return []point{{sx, sy}, {right, sy}, {right, ey}, {ex, ey}}
+	}
+
+	sx, ex := pred.x+pred.w, succ.x
+	if ex-sx >= gap {
+		mid := sx + min(gap, (ex-sx)/2)
+		return []point{{sx, sy}, {mid, sy}, {mid, ey}, {ex, ey}}
+	}
+
+	// The successor starts before the predecessor ends: loop around
+	// through the gap between the rows
+	turnY := succ.y - gap/2
+	if ey < sy {
+		turnY = succ.y + succ.h + gap/2
+	}
+	return []point{{sx, sy}, {sx + gap, sy}, {sx + gap, turnY}, {ex - gap, turnY}, {ex - gap, ey}, {ex, ey}}
+}
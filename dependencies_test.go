@@ -0,0 +1,75 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateDependencies(t *testing.T) {
+	dependsOn := func(ids ...string) []Dependency {
+		var deps []Dependency
+		for _, id := range ids {
+			deps = append(deps, Dependency{TaskID: id})
+		}
+		return deps
+	}
+
+	tests := []struct {
+		name       string
+		categories []Category
+		cycle      []string
+		paths      []string
+	}{
+		{
+			name: "self-dependency",
+			categories: []Category{{ID: "eng", Tasks: []Task{
+				{ID: "a", Dependencies: dependsOn("a")},
+			}}},
+			cycle: []string{"a", "a"},
+			paths: []string{"/categories/0/tasks/0/dependencies/0/taskId"},
+		},
+		{
+			name: "cycle across categories",
+			categories: []Category{
+				{ID: "eng", Tasks: []Task{{ID: "a", Dependencies: dependsOn("c")}}},
+				{ID: "ops", Tasks: []Task{{ID: "b", Dependencies: dependsOn("a")}}},
+				{ID: "qa", Tasks: []Task{{ID: "c", Dependencies: dependsOn("b")}}},
+			},
+			cycle: []string{"a", "c", "b", "a"},
+			paths: []string{"/categories/0/tasks/0/dependencies"},
+		},
+		{
+			name: "summary task on its subtask",
+			categories: []Category{{ID: "eng", Tasks: []Task{
+				{ID: "parent", Dependencies: dependsOn("child"), Subtasks: []Task{{ID: "child"}}},
+			}}},
+			paths: []string{"/categories/0/tasks/0/dependencies/0"},
+		},
+		{
+			name: "subtask on its summary task",
+			categories: []Category{{ID: "eng", Tasks: []Task{
+				{ID: "parent", Subtasks: []Task{{ID: "child", Dependencies: dependsOn("parent")}}},
+			}}},
+			paths: []string{"/categories/0/tasks/0/subtasks/0/dependencies/0/taskId"},
+		},
+	}
+	for _, test := range tests {
+		chart := &Chart{Categories: test.categories}
+		if cycle := findDependencyCycle(chart, chartTasks(chart)); !reflect.DeepEqual(cycle, test.cycle) {
+			t.Errorf("%s: cycle = %v, want %v", test.name, cycle, test.cycle)
+		}
+
+		err := validateDependencies(chart)
+		if err == nil {
+			t.Errorf("%s: validated, want an error", test.name)
+			continue
+		}
+		var paths []string
+		for _, fe := range fieldErrors(err) {
+			paths = append(paths, fe.Path)
+		}
+		if !reflect.DeepEqual(paths, test.paths) {
+			t.Errorf("%s: error paths = %v, want %v (%v)", test.name, paths, test.paths, err)
+		}
+	}
+}
@@ -2,62 +2,75 @@ package main
 
 import (
 	"bytes"
-	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
 	"image/png"
+	"math"
 	"strconv"
 	"strings"
 
 	"github.com/jung-kurt/gofpdf"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
 )
 
 type pngLayoutConfig struct {
 	headerHeight         int
+	groupRowHeight       int
 	rowHeight            int
-	quarterWidth         int
+	columnWidth          int
 	labelWidth           int
 	padding              int
 	categoryHeaderHeight int
 }
 
 type pngRenderContext struct {
-	img           *image.RGBA
-	quarters      []quarterInfo
-	totalQuarters int
-	config        pngLayoutConfig
+	img          *image.RGBA
+	timeline     *timeline
+	totalColumns int
+	config       pngLayoutConfig
+	opts         ExportOptions
+	bars         map[string]barRect
 }
 
 // GeneratePNG creates a PNG image of the Gantt chart
-func GeneratePNG(chart *Chart) ([]byte, error) {
-	quarters := calculateQuarters(chart.StartYear, chart.StartQ, chart.EndYear, chart.EndQ)
+func GeneratePNG(chart *Chart, opts ExportOptions) ([]byte, error) {
+	tl := buildTimeline(chart)
 
 	config := pngLayoutConfig{
 		headerHeight:         80,
+		groupRowHeight:       24,
 		rowHeight:            40,
-		quarterWidth:         120,
+		columnWidth:          svgColumnWidths[tl.granularity],
 		labelWidth:           200,
 		padding:              20,
 		categoryHeaderHeight: 35,
 	}
+	if tl.groups() != nil {
+		config.headerHeight += config.groupRowHeight
+	}
 
-	totalRows := countTotalRows(chart)
-	width := config.labelWidth + len(quarters)*config.quarterWidth + config.padding*2
+	totalRows := countTotalRows(chart) + countMilestoneRows(chart)
+	width := config.labelWidth + len(tl.columns)*config.columnWidth + config.padding*2
 	height := config.headerHeight + totalRows*config.rowHeight + config.padding*2
 
 	img := image.NewRGBA(image.Rect(0, 0, width, height))
 	draw.Draw(img, img.Bounds(), &image.Uniform{color.RGBA{250, 250, 250, 255}}, image.Point{}, draw.Src)
 
 	ctx := &pngRenderContext{
-		img:           img,
-		quarters:      quarters,
-		totalQuarters: len(quarters),
-		config:        config,
+		img:          img,
+		timeline:     tl,
+		totalColumns: len(tl.columns),
+		config:       config,
+		opts:         opts,
+		bars:         make(map[string]barRect),
 	}
 
-	ctx.drawQuarterHeaders(height)
+	ctx.drawTimelineHeaders(height)
 	ctx.drawCategoriesAndTasks(chart)
+	ctx.drawDependencies(chart)
 
 	var buf bytes.Buffer
 	if err := png.Encode(&buf, img); err != nil {
@@ -71,31 +84,53 @@ func countTotalRows(chart *Chart) int {
 	totalRows := 0
 	for _, cat := range chart.Categories {
 		totalRows++ // Category header
-		totalRows += len(cat.Tasks)
+		totalRows += len(visibleTasks(cat.Tasks))
 	}
 	return totalRows
 }
 
-func (ctx *pngRenderContext) drawQuarterHeaders(height int) {
-	for i := range ctx.quarters {
-		x := ctx.config.padding + ctx.config.labelWidth + i*ctx.config.quarterWidth
-		y := ctx.config.headerHeight
+func (ctx *pngRenderContext) drawTimelineHeaders(height int) {
+	y := ctx.config.headerHeight
+	text := color.RGBA{51, 51, 51, 255}
+	for i, g := range ctx.timeline.groups() {
+		x := ctx.config.padding + ctx.config.labelWidth + g.first*ctx.config.columnWidth
+		w := g.count * ctx.config.columnWidth
+
+		bgColor := color.RGBA{220, 220, 220, 255}
+		if i%2 == 1 {
+			bgColor = color.RGBA{235, 235, 235, 255}
+		}
+		drawRect(ctx.img, x, y-30-ctx.config.groupRowHeight, w, ctx.config.groupRowHeight, bgColor)
+		drawRectBorder(ctx.img, x, y-30-ctx.config.groupRowHeight, w, ctx.config.groupRowHeight, color.RGBA{204, 204, 204, 255})
+		drawText(ctx.img, x+(w-textWidth(g.label))/2, y-30-8, g.label, text)
+	}
+
+	for i, col := range ctx.timeline.columns {
+		x := ctx.config.padding + ctx.config.labelWidth + i*ctx.config.columnWidth
 
 		bgColor := color.RGBA{232, 232, 232, 255}
 		if i%2 == 1 {
 			bgColor = color.RGBA{245, 245, 245, 255}
 		}
-		drawRect(ctx.img, x, y-30, ctx.config.quarterWidth, 30, bgColor)
-		drawRectBorder(ctx.img, x, y-30, ctx.config.quarterWidth, 30, color.RGBA{204, 204, 204, 255})
+		drawRect(ctx.img, x, y-30, ctx.config.columnWidth, 30, bgColor)
+		drawRectBorder(ctx.img, x, y-30, ctx.config.columnWidth, 30, color.RGBA{204, 204, 204, 255})
+		drawText(ctx.img, x+(ctx.config.columnWidth-textWidth(col.label))/2, y-10, col.label, text)
 		drawVerticalLine(ctx.img, x, y, height-ctx.config.padding, color.RGBA{221, 221, 221, 255})
 	}
 }
 
 func (ctx *pngRenderContext) drawCategoriesAndTasks(chart *Chart) {
 	currentY := ctx.config.headerHeight
+	top, byCategory := milestoneRows(chart)
+	if len(top) > 0 {
+		currentY = ctx.drawMilestoneRow(top, "", currentY)
+	}
 	for _, cat := range chart.Categories {
 		catColor := parseColor(cat.Color)
 		currentY = ctx.drawCategory(cat, catColor, currentY)
+		if ms := byCategory[cat.ID]; len(ms) > 0 {
+			currentY = ctx.drawMilestoneRow(ms, cat.Color, currentY)
+		}
 	}
 }
 
@@ -104,16 +139,23 @@ func (ctx *pngRenderContext) drawCategory(cat Category, catColor color.RGBA, cur
 	drawRect(ctx.img, ctx.config.padding, currentY, ctx.config.labelWidth, ctx.config.categoryHeaderHeight, catColorAlpha)
 
 	catColorLight := color.RGBA{catColor.R, catColor.G, catColor.B, 13}
-	drawRect(ctx.img, ctx.config.padding+ctx.config.labelWidth, currentY, ctx.totalQuarters*ctx.config.quarterWidth, ctx.config.categoryHeaderHeight, catColorLight)
+	drawRect(ctx.img, ctx.config.padding+ctx.config.labelWidth, currentY, ctx.totalColumns*ctx.config.columnWidth, ctx.config.categoryHeaderHeight, catColorLight)
+
+	if progress, ok := categoryProgress(cat, ctx.timeline.cal); ok {
+		label := progressLabel(progress)
+		drawText(ctx.img, ctx.config.padding+ctx.config.labelWidth-10-textWidth(label), currentY+22, label, color.RGBA{102, 102, 102, 255})
+	}
 
 	currentY += ctx.config.categoryHeaderHeight
-	return ctx.drawTasks(cat.Tasks, catColor, currentY)
+	return ctx.drawTasks(visibleTasks(cat.Tasks), catColor, currentY)
 }
 
-func (ctx *pngRenderContext) drawTasks(tasks []Task, catColor color.RGBA, currentY int) int {
-	for _, task := range tasks {
+func (ctx *pngRenderContext) drawTasks(rows []taskRow, catColor color.RGBA, currentY int) int {
+	for _, row := range rows {
+		task := row.task
 		drawRect(ctx.img, ctx.config.padding, currentY, ctx.config.labelWidth, ctx.config.rowHeight, color.RGBA{255, 255, 255, 255})
 		drawRectBorder(ctx.img, ctx.config.padding, currentY, ctx.config.labelWidth, ctx.config.rowHeight, color.RGBA{221, 221, 221, 255})
+		ctx.drawBaselineBar(task, currentY+ctx.config.rowHeight-baselineBarHeight-2)
 		ctx.drawTaskBar(task, catColor, currentY)
 		currentY += ctx.config.rowHeight
 	}
@@ -121,15 +163,13 @@ func (ctx *pngRenderContext) drawTasks(tasks []Task, catColor color.RGBA, curren
 }
 
 func (ctx *pngRenderContext) drawTaskBar(task Task, catColor color.RGBA, currentY int) {
-	startIdx := findQuarterIndex(ctx.quarters, task.StartYear, task.StartQ)
-	endIdx := findQuarterIndex(ctx.quarters, task.EndYear, task.EndQ)
-
-	if startIdx < 0 || endIdx < 0 {
+	startPos, endPos, ok := ctx.timeline.taskSpan(task)
+	if !ok {
 		return
 	}
 
-	barX := ctx.config.padding + ctx.config.labelWidth + startIdx*ctx.config.quarterWidth
-	barWidth := (endIdx - startIdx + 1) * ctx.config.quarterWidth
+	barX := ctx.config.padding + ctx.config.labelWidth + int(math.Round(startPos*float64(ctx.config.columnWidth)))
+	barWidth := max(int(math.Round((endPos-startPos)*float64(ctx.config.columnWidth))), minBarWidth+4)
 	barY := currentY + 8
 	barHeight := ctx.config.rowHeight - 16
 
@@ -138,50 +178,185 @@ func (ctx *pngRenderContext) drawTaskBar(task Task, catColor color.RGBA, current
 		taskColor = catColor
 	}
 
+	if isSummary(task) {
+		ctx.drawSummaryBar(task, taskColor, barX, barY+(barHeight-summaryBarHeight)/2, barWidth)
+		ctx.bars[task.ID] = barRect{float64(barX + 2), float64(barY), float64(barWidth - 4), float64(barHeight)}
+		return
+	}
+
 	taskColorAlpha := color.RGBA{taskColor.R, taskColor.G, taskColor.B, 204}
 	drawRoundedRect(ctx.img, barX+2, barY, barWidth-4, barHeight, 4, taskColorAlpha)
+	if task.Progress > 0 {
+		done := shadeRGBA(taskColor, 0.65)
+		done.A = 230
+		drawRoundedRect(ctx.img, barX+2, barY, progressWidth(barWidth-4, task.Progress), barHeight, 4, done)
+	}
+	if task.Status == statusPlanned {
+		drawHatch(ctx.img, barX+2, barY, barWidth-4, barHeight, color.RGBA{255, 255, 255, 160})
+	}
+	if label := ctx.opts.barLabel(task); label != "" {
+		drawText(ctx.img, barX+barWidth+2, barY+barHeight/2+4, label, color.RGBA{102, 102, 102, 255})
+	}
+	if initials := ctx.opts.Initials[task.ID]; initials != "" {
+		drawText(ctx.img, barX+6, barY+barHeight/2+4, initials, color.RGBA{255, 255, 255, 255})
+	}
+	switch {
+	case task.Status == statusAtRisk:
+		atRisk := parseColor(atRiskColor)
+		drawRectBorder(ctx.img, barX+2, barY, barWidth-4, barHeight, atRisk)
+		drawRectBorder(ctx.img, barX+3, barY+1, barWidth-6, barHeight-2, atRisk)
+	case task.Status == statusBlocked:
+		drawDashedBorder(ctx.img, barX+2, barY, barWidth-4, barHeight, parseColor(blockedColor))
+	}
+	if ctx.opts.Critical[task.ID] {
+		critical := parseColor(criticalColor)
+		drawRectBorder(ctx.img, barX-2, barY-5, barWidth+4, barHeight+10, critical)
+		drawRectBorder(ctx.img, barX-1, barY-4, barWidth+2, barHeight+8, critical)
+	}
+	if task.Status == statusCancelled {
+		cancelled := parseColor(cancelledColor)
+		drawHorizontalLine(ctx.img, barX, barX+barWidth, barY+barHeight/2, cancelled)
+		drawHorizontalLine(ctx.img, barX, barX+barWidth, barY+barHeight/2+1, cancelled)
+	}
+
+	ctx.bars[task.ID] = barRect{float64(barX + 2), float64(barY), float64(barWidth - 4), float64(barHeight)}
+}
+
+// drawBaselineBar draws a task's baselined dates as a thin ghost bar
+// starting at y
+func (ctx *pngRenderContext) drawBaselineBar(task Task, y int) {
+	old, ok := ctx.opts.Baseline[task.ID]
+	if !ok {
+		return
+	}
+	startPos, endPos, ok := ctx.timeline.taskSpan(old)
+	if !ok {
+		return
+	}
+	x := ctx.config.padding + ctx.config.labelWidth + int(math.Round(startPos*float64(ctx.config.columnWidth)))
+	w := max(int(math.Round((endPos-startPos)*float64(ctx.config.columnWidth))), minBarWidth+4)
+	drawRect(ctx.img, x+2, y, w-4, baselineBarHeight, parseColor(baselineColor))
+}
+
+// drawSummaryBar draws a parent task as a thin dark bar with a downward
+// cap at each end
+func (ctx *pngRenderContext) drawSummaryBar(task Task, taskColor color.RGBA, barX, barY, barWidth int) {
+	x0, x1 := barX+2, barX+barWidth-2
+	dark := shadeRGBA(taskColor, summaryShade)
+	drawRect(ctx.img, x0, barY, x1-x0, summaryBarHeight, dark)
+	if task.Progress > 0 {
+		drawRect(ctx.img, x0, barY+2, progressWidth(x1-x0, task.Progress), summaryBarHeight-4, taskColor)
+	}
+	capY := barY + summaryBarHeight
+	for dy := 0; dy < summaryCapSize; dy++ {
+		drawHorizontalLine(ctx.img, x0, x0+summaryCapSize-dy, capY+dy, dark)
+		drawHorizontalLine(ctx.img, x1-summaryCapSize+dy, x1, capY+dy, dark)
+	}
+	if label := ctx.opts.barLabel(task); label != "" {
+		drawText(ctx.img, barX+barWidth+2, barY+summaryBarHeight/2+4, label, color.RGBA{102, 102, 102, 255})
+	}
+}
+
+func (ctx *pngRenderContext) drawMilestoneRow(milestones []Milestone, catColor string, currentY int) int {
+	h := ctx.config.rowHeight
+	drawRect(ctx.img, ctx.config.padding, currentY, ctx.config.labelWidth, h, color.RGBA{255, 255, 255, 255})
+	drawRectBorder(ctx.img, ctx.config.padding, currentY, ctx.config.labelWidth, h, color.RGBA{221, 221, 221, 255})
+
+	for _, m := range milestones {
+		pos, ok := ctx.timeline.milestonePosition(m)
+		if !ok {
+			continue
+		}
+		cx := ctx.config.padding + ctx.config.labelWidth + int(math.Round(pos*float64(ctx.config.columnWidth)))
+		cy := currentY + h/2
+		drawDiamond(ctx.img, cx, cy, milestoneSize, parseColor(milestoneColor(m, catColor)))
+		drawText(ctx.img, cx+milestoneSize+4, cy+4, m.Title, color.RGBA{51, 51, 51, 255})
+	}
+	return currentY + h
+}
+
+func (ctx *pngRenderContext) drawDependencies(chart *Chart) {
+	arrowColor := color.RGBA{85, 85, 85, 255}
+	for _, arrow := range dependencyArrows(chart, ctx.bars, 8) {
+		for i := 1; i < len(arrow.points); i++ {
+			from, to := arrow.points[i-1], arrow.points[i]
+			if from.y == to.y {
+				drawHorizontalLine(ctx.img, int(from.x), int(to.x), int(from.y), arrowColor)
+			} else {
+				drawVerticalLine(ctx.img, int(from.x), int(min(from.y, to.y)), int(max(from.y, to.y)), arrowColor)
+			}
+		}
+
+		// Filled triangle with its tip on the bar edge
+		tip := arrow.points[len(arrow.points)-1]
+		dir := 1
+		if !arrow.pointsRight() {
+			dir = -1
+		}
+		for i := 0; i < 6; i++ {
+			drawVerticalLine(ctx.img, int(tip.x)-dir*i, int(tip.y)-i, int(tip.y)+i, arrowColor)
+		}
+	}
 }
 
 type pdfLayoutConfig struct {
 	headerHeight         float64
+	groupRowHeight       float64
 	rowHeight            float64
-	quarterWidth         float64
+	columnWidth          float64
 	labelWidth           float64
 	padding              float64
 	categoryHeaderHeight float64
 }
 
+// pdfColumnWidths is the width in millimetres of one timeline column
+var pdfColumnWidths = map[string]float64{
+	granularityQuarter: 25.0,
+	granularityMonth:   10.0,
+	granularityWeek:    7.0,
+	granularityDay:     4.0,
+}
+
 type pdfRenderContext struct {
 	pdf      *gofpdf.Fpdf
-	quarters []quarterInfo
+	timeline *timeline
 	config   pdfLayoutConfig
+	opts     ExportOptions
+	bars     map[string]barRect
 }
 
 // GeneratePDF creates a PDF document of the Gantt chart
-func GeneratePDF(chart *Chart) ([]byte, error) {
+func GeneratePDF(chart *Chart, opts ExportOptions) ([]byte, error) {
 	pdf := gofpdf.New("L", "mm", "A4", "")
 	pdf.AddPage()
 
-	quarters := calculateQuarters(chart.StartYear, chart.StartQ, chart.EndYear, chart.EndQ)
+	tl := buildTimeline(chart)
 
 	config := pdfLayoutConfig{
 		headerHeight:         20.0,
+		groupRowHeight:       6.0,
 		rowHeight:            10.0,
-		quarterWidth:         25.0,
+		columnWidth:          pdfColumnWidths[tl.granularity],
 		labelWidth:           50.0,
 		padding:              10.0,
 		categoryHeaderHeight: 8.0,
 	}
+	if tl.groups() != nil {
+		config.headerHeight += config.groupRowHeight
+	}
 
 	ctx := &pdfRenderContext{
 		pdf:      pdf,
-		quarters: quarters,
+		timeline: tl,
 		config:   config,
+		opts:     opts,
+		bars:     make(map[string]barRect),
 	}
 
 	ctx.writeTitle(chart.Title)
-	ctx.writeQuarterHeaders()
+	ctx.writeTimelineHeaders()
 	ctx.writeCategoriesAndTasks(chart)
+	ctx.writeDependencies(chart)
 
 	var buf bytes.Buffer
 	if err := pdf.Output(&buf); err != nil {
@@ -197,21 +372,49 @@ func (ctx *pdfRenderContext) writeTitle(title string) {
 	ctx.pdf.Cell(0, 10, title)
 }
 
-func (ctx *pdfRenderContext) writeQuarterHeaders() {
-	ctx.pdf.SetFont("Arial", "B", 9)
-	for i, q := range ctx.quarters {
-		x := ctx.config.padding + ctx.config.labelWidth + float64(i)*ctx.config.quarterWidth
-		y := ctx.config.headerHeight
+func (ctx *pdfRenderContext) writeTimelineHeaders() {
+	y := ctx.config.headerHeight
+
+	ctx.pdf.SetFont("Arial", "B", 8)
+	for i, g := range ctx.timeline.groups() {
+		x := ctx.config.padding + ctx.config.labelWidth + float64(g.first)*ctx.config.columnWidth
+		w := float64(g.count) * ctx.config.columnWidth
+
+		if i%2 == 0 {
+			ctx.pdf.SetFillColor(220, 220, 220)
+		} else {
+			ctx.pdf.SetFillColor(235, 235, 235)
+		}
+		ctx.pdf.Rect(x, y-8-ctx.config.groupRowHeight, w, ctx.config.groupRowHeight, "F")
+
+		ctx.pdf.SetXY(x, y-8-ctx.config.groupRowHeight)
+		ctx.pdf.CellFormat(w, ctx.config.groupRowHeight, g.label, "", 0, "C", false, 0, "")
+	}
+
+	// Narrow columns need a smaller font to fit their labels
+	fontSize := 9.0
+	if ctx.config.columnWidth < 10 {
+		fontSize = 5.0
+	} else if ctx.config.columnWidth < 20 {
+		fontSize = 7.0
+	}
+	ctx.pdf.SetFont("Arial", "B", fontSize)
+	for i, col := range ctx.timeline.columns {
+		x := ctx.config.padding + ctx.config.labelWidth + float64(i)*ctx.config.columnWidth
 
 		if i%2 == 0 {
 			ctx.pdf.SetFillColor(232, 232, 232)
 		} else {
 			ctx.pdf.SetFillColor(245, 245, 245)
 		}
-		ctx.pdf.Rect(x, y-8, ctx.config.quarterWidth, 8, "F")
+		ctx.pdf.Rect(x, y-8, ctx.config.columnWidth, 8, "F")
 
 		ctx.pdf.SetXY(x, y-7)
-		ctx.pdf.Cell(ctx.config.quarterWidth, 6, fmt.Sprintf("Q%d %d", q.quarter, q.year))
+		if ctx.timeline.granularity == granularityQuarter {
+			ctx.pdf.Cell(ctx.config.columnWidth, 6, col.label)
+		} else {
+			ctx.pdf.CellFormat(ctx.config.columnWidth, 6, col.label, "", 0, "C", false, 0, "")
+		}
 	}
 }
 
@@ -219,8 +422,15 @@ func (ctx *pdfRenderContext) writeCategoriesAndTasks(chart *Chart) {
 	currentY := ctx.config.headerHeight
 	ctx.pdf.SetFont("Arial", "", 8)
 
+	top, byCategory := milestoneRows(chart)
+	if len(top) > 0 {
+		currentY = ctx.writeMilestoneRow(top, "", currentY)
+	}
 	for _, cat := range chart.Categories {
 		currentY = ctx.writeCategory(cat, currentY)
+		if ms := byCategory[cat.ID]; len(ms) > 0 {
+			currentY = ctx.writeMilestoneRow(ms, cat.Color, currentY)
+		}
 	}
 }
 
@@ -236,37 +446,51 @@ func (ctx *pdfRenderContext) writeCategory(cat Category, currentY float64) float
 	ctx.pdf.Cell(ctx.config.labelWidth-4, ctx.config.categoryHeaderHeight-4, cat.Name)
 	ctx.pdf.SetFont("Arial", "", 8)
 
+	if progress, ok := categoryProgress(cat, ctx.timeline.cal); ok {
+		ctx.pdf.SetXY(ctx.config.padding+2, currentY+2)
+		ctx.pdf.CellFormat(ctx.config.labelWidth-4, ctx.config.categoryHeaderHeight-4, progressLabel(progress), "", 0, "R", false, 0, "")
+	}
+
 	currentY += ctx.config.categoryHeaderHeight
 
-	for _, task := range cat.Tasks {
-		currentY = ctx.writeTask(task, cat.Color, currentY)
+	for _, row := range visibleTasks(cat.Tasks) {
+		currentY = ctx.writeTask(row.task, row.depth, cat.Color, currentY)
 	}
 
 	return currentY
 }
 
-func (ctx *pdfRenderContext) writeTask(task Task, catColor string, currentY float64) float64 {
+func (ctx *pdfRenderContext) writeTask(task Task, depth int, catColor string, currentY float64) float64 {
 	ctx.pdf.SetDrawColor(221, 221, 221)
 	ctx.pdf.Rect(ctx.config.padding, currentY, ctx.config.labelWidth, ctx.config.rowHeight, "D")
 
-	ctx.pdf.SetXY(ctx.config.padding+2, currentY+2)
-	ctx.pdf.Cell(ctx.config.labelWidth-4, 4, truncate(task.Title, 20))
+	style := ""
+	if isSummary(task) {
+		style += "B"
+	}
+	if task.Status == statusCancelled {
+		style += "S"
+	}
+	indent := float64(depth) * pdfIndentWidth
+	ctx.pdf.SetFont("Arial", style, 8)
+	ctx.pdf.SetXY(ctx.config.padding+2+indent, currentY+2)
+	ctx.pdf.Cell(ctx.config.labelWidth-4-indent, 4, truncate(task.Title, max(20-depth, 8)))
+	ctx.pdf.SetFont("Arial", "", 8)
 
+	ctx.writeBaselineBar(task, currentY+ctx.config.rowHeight-pdfBaselineBarHeight-0.4)
 	ctx.writeTaskBar(task, catColor, currentY)
 
 	return currentY + ctx.config.rowHeight
 }
 
 func (ctx *pdfRenderContext) writeTaskBar(task Task, catColor string, currentY float64) {
-	startIdx := findQuarterIndex(ctx.quarters, task.StartYear, task.StartQ)
-	endIdx := findQuarterIndex(ctx.quarters, task.EndYear, task.EndQ)
-
-	if startIdx < 0 || endIdx < 0 {
+	startPos, endPos, ok := ctx.timeline.taskSpan(task)
+	if !ok {
 		return
 	}
 
-	barX := ctx.config.padding + ctx.config.labelWidth + float64(startIdx)*ctx.config.quarterWidth
-	barWidth := float64(endIdx-startIdx+1) * ctx.config.quarterWidth
+	barX := ctx.config.padding + ctx.config.labelWidth + startPos*ctx.config.columnWidth
+	barWidth := math.Max((endPos-startPos)*ctx.config.columnWidth, 3)
 	barY := currentY + 2
 	barHeight := ctx.config.rowHeight - 4
 
@@ -275,11 +499,185 @@ func (ctx *pdfRenderContext) writeTaskBar(task Task, catColor string, currentY f
 		taskColor = catColor
 	}
 
+	if isSummary(task) {
+		ctx.writeSummaryBar(task, taskColor, barX, barY+barHeight/2-pdfSummaryBarHeight/2, barWidth)
+		ctx.bars[task.ID] = barRect{barX + 1, barY, barWidth - 2, barHeight}
+		return
+	}
+
 	taskR, taskG, taskB := parseColorRGB(taskColor)
 	ctx.pdf.SetFillColor(taskR, taskG, taskB)
 	ctx.pdf.SetAlpha(0.8, "Normal")
 	ctx.pdf.Rect(barX+1, barY, barWidth-2, barHeight, "F")
 	ctx.pdf.SetAlpha(1.0, "Normal")
+	if task.Progress > 0 {
+		doneR, doneG, doneB := parseColorRGB(shadeColor(taskColor, 0.65))
+		ctx.pdf.SetFillColor(doneR, doneG, doneB)
+		ctx.pdf.SetAlpha(0.9, "Normal")
+		ctx.pdf.Rect(barX+1, barY, (barWidth-2)*float64(task.Progress)/100, barHeight, "F")
+		ctx.pdf.SetAlpha(1.0, "Normal")
+	}
+	if label := ctx.opts.barLabel(task); label != "" {
+		ctx.pdf.SetFont("Arial", "", 6)
+		ctx.pdf.SetXY(barX+barWidth, barY)
+		ctx.pdf.Cell(ctx.pdf.GetStringWidth(label)+1, barHeight, label)
+		ctx.pdf.SetFont("Arial", "", 8)
+	}
+	if initials := ctx.opts.Initials[task.ID]; initials != "" {
+		ctx.pdf.SetFont("Arial", "B", 6)
+		ctx.pdf.SetTextColor(255, 255, 255)
+		ctx.pdf.SetXY(barX+1, barY)
+		ctx.pdf.Cell(barWidth-2, barHeight, initials)
+		ctx.pdf.SetTextColor(0, 0, 0)
+		ctx.pdf.SetFont("Arial", "", 8)
+	}
+	if task.Status == statusPlanned {
+		ctx.writeHatch(barX+1, barY, barWidth-2, barHeight)
+	}
+	switch {
+	case task.Status == statusAtRisk:
+		ctx.writeBarOutline(barX+1, barY, barWidth-2, barHeight, atRiskColor)
+	case task.Status == statusBlocked:
+		ctx.pdf.SetDashPattern([]float64{1, 0.5}, 0)
+		ctx.writeBarOutline(barX+1, barY, barWidth-2, barHeight, blockedColor)
+		ctx.pdf.SetDashPattern([]float64{}, 0)
+	}
+	if ctx.opts.Critical[task.ID] {
+		ctx.writeBarOutline(barX, barY-1, barWidth, barHeight+2, criticalColor)
+	}
+	if task.Status == statusCancelled {
+		r, g, b := parseColorRGB(cancelledColor)
+		ctx.pdf.SetDrawColor(r, g, b)
+		ctx.pdf.SetLineWidth(0.5)
+		ctx.pdf.Line(barX, barY+barHeight/2, barX+barWidth, barY+barHeight/2)
+		ctx.pdf.SetLineWidth(0.2)
+	}
+
+	ctx.bars[task.ID] = barRect{barX + 1, barY, barWidth - 2, barHeight}
+}
+
+// writeBaselineBar draws a task's baselined dates as a thin ghost bar
+// starting at y
+func (ctx *pdfRenderContext) writeBaselineBar(task Task, y float64) {
+	old, ok := ctx.opts.Baseline[task.ID]
+	if !ok {
+		return
+	}
+	startPos, endPos, ok := ctx.timeline.taskSpan(old)
+	if !ok {
+		return
+	}
+	x := ctx.config.padding + ctx.config.labelWidth + startPos*ctx.config.columnWidth
+	w := math.Max((endPos-startPos)*ctx.config.columnWidth, 3)
+	r, g, b := parseColorRGB(baselineColor)
+	ctx.pdf.SetFillColor(r, g, b)
+	ctx.pdf.Rect(x+1, y, w-2, pdfBaselineBarHeight, "F")
+}
+
+// writeSummaryBar draws a parent task as a thin dark bar with a downward
+// cap at each end
+func (ctx *pdfRenderContext) writeSummaryBar(task Task, taskColor string, barX, barY, barWidth float64) {
+	x0, x1 := barX+1, barX+barWidth-1
+	capY := barY + pdfSummaryBarHeight
+	r, g, b := parseColorRGB(shadeColor(taskColor, summaryShade))
+	ctx.pdf.SetFillColor(r, g, b)
+	ctx.pdf.Rect(x0, barY, x1-x0, pdfSummaryBarHeight, "F")
+	ctx.pdf.Polygon([]gofpdf.PointType{{X: x0, Y: capY}, {X: x0 + pdfSummaryBarHeight, Y: capY}, {X: x0, Y: capY + pdfSummaryBarHeight}}, "F")
+	ctx.pdf.Polygon([]gofpdf.PointType{{X: x1 - pdfSummaryBarHeight, Y: capY}, {X: x1, Y: capY}, {X: x1, Y: capY + pdfSummaryBarHeight}}, "F")
+	if task.Progress > 0 {
+		r, g, b = parseColorRGB(taskColor)
+		ctx.pdf.SetFillColor(r, g, b)
+		ctx.pdf.Rect(x0, barY+0.5, (x1-x0)*float64(task.Progress)/100, pdfSummaryBarHeight-1, "F")
+	}
+	if label := ctx.opts.barLabel(task); label != "" {
+		ctx.pdf.SetFont("Arial", "", 6)
+		ctx.pdf.SetXY(barX+barWidth, barY-1)
+		ctx.pdf.Cell(ctx.pdf.GetStringWidth(label)+1, pdfSummaryBarHeight+2, label)
+		ctx.pdf.SetFont("Arial", "", 8)
+	}
+}
+
+// writeBarOutline strokes a thick outline around a task bar
+func (ctx *pdfRenderContext) writeBarOutline(x, y, w, h float64, hex string) {
+	r, g, b := parseColorRGB(hex)
+	ctx.pdf.SetDrawColor(r, g, b)
+	ctx.pdf.SetLineWidth(0.6)
+	ctx.pdf.Rect(x, y, w, h, "D")
+	ctx.pdf.SetLineWidth(0.2)
+}
+
+// writeHatch strokes white diagonal lines across a task bar
+func (ctx *pdfRenderContext) writeHatch(x, y, w, h float64) {
+	ctx.pdf.ClipRect(x, y, w, h, false)
+	ctx.pdf.SetDrawColor(255, 255, 255)
+	ctx.pdf.SetAlpha(0.6, "Normal")
+	for off := -h; off < w; off += 1.5 {
+		ctx.pdf.Line(x+off, y+h, x+off+h, y)
+	}
+	ctx.pdf.SetAlpha(1.0, "Normal")
+	ctx.pdf.ClipEnd()
+}
+
+func (ctx *pdfRenderContext) writeMilestoneRow(milestones []Milestone, catColor string, currentY float64) float64 {
+	ctx.pdf.SetDrawColor(221, 221, 221)
+	ctx.pdf.Rect(ctx.config.padding, currentY, ctx.config.labelWidth, ctx.config.rowHeight, "D")
+
+	ctx.pdf.SetFont("Arial", "I", 8)
+	ctx.pdf.SetXY(ctx.config.padding+2, currentY+2)
+	ctx.pdf.Cell(ctx.config.labelWidth-4, 4, "Milestones")
+	ctx.pdf.SetFont("Arial", "", 8)
+
+	const size = 2.5
+	for _, m := range milestones {
+		pos, ok := ctx.timeline.milestonePosition(m)
+		if !ok {
+			continue
+		}
+		cx := ctx.config.padding + ctx.config.labelWidth + pos*ctx.config.columnWidth
+		cy := currentY + ctx.config.rowHeight/2
+
+		r, g, b := parseColorRGB(milestoneColor(m, catColor))
+		ctx.pdf.SetFillColor(r, g, b)
+		ctx.pdf.Polygon([]gofpdf.PointType{
+			{X: cx, Y: cy - size},
+			{X: cx + size, Y: cy},
+			{X: cx, Y: cy + size},
+			{X: cx - size, Y: cy},
+		}, "F")
+
+		ctx.pdf.SetXY(cx+size+1, cy-2)
+		ctx.pdf.Cell(ctx.pdf.GetStringWidth(m.Title)+1, 4, m.Title)
+	}
+	return currentY + ctx.config.rowHeight
+}
+
+func (ctx *pdfRenderContext) writeDependencies(chart *Chart) {
+	arrows := dependencyArrows(chart, ctx.bars, 2)
+	if len(arrows) == 0 {
+		return
+	}
+
+	ctx.pdf.SetDrawColor(85, 85, 85)
+	ctx.pdf.SetFillColor(85, 85, 85)
+	ctx.pdf.SetLineWidth(0.3)
+	for _, arrow := range arrows {
+		for i := 1; i < len(arrow.points); i++ {
+			from, to := arrow.points[i-1], arrow.points[i]
+			ctx.pdf.Line(from.x, from.y, to.x, to.y)
+		}
+
+		tip := arrow.points[len(arrow.points)-1]
+		back := -1.2
+		if !arrow.pointsRight() {
+			back = 1.2
+		}
+		ctx.pdf.Polygon([]gofpdf.PointType{
+			{X: tip.x, Y: tip.y},
+			{X: tip.x + back, Y: tip.y - 0.7},
+			{X: tip.x + back, Y: tip.y + 0.7},
+		}, "F")
+	}
+	ctx.pdf.SetLineWidth(0.2)
 }
 
 // Helper functions for image drawing
@@ -333,6 +731,63 @@ func drawRoundedRect(img *image.RGBA, x, y, width, height, radius int, col color
 	drawRect(img, x, y, width, height, col)
 }
 
+// drawHatch strokes diagonal lines across a rectangle
+func drawHatch(img *image.RGBA, x, y, width, height int, col color.RGBA) {
+	for i := x; i < x+width; i++ {
+		for j := y; j < y+height; j++ {
+			if (i+j)%6 < 2 && i >= 0 && i < img.Bounds().Dx() && j >= 0 && j < img.Bounds().Dy() {
+				img.Set(i, j, col)
+			}
+		}
+	}
+}
+
+// drawDashedBorder outlines a rectangle with 4 pixel dashes
+func drawDashedBorder(img *image.RGBA, x, y, width, height int, col color.RGBA) {
+	for i := x; i <= x+width; i += 6 {
+		drawHorizontalLine(img, i, min(i+3, x+width), y, col)
+		drawHorizontalLine(img, i, min(i+3, x+width), y+height, col)
+	}
+	for j := y; j <= y+height; j += 6 {
+		drawVerticalLine(img, x, j, min(j+3, y+height), col)
+		drawVerticalLine(img, x+width, j, min(j+3, y+height), col)
+	}
+}
+
+func drawDiamond(img *image.RGBA, cx, cy, size int, col color.RGBA) {
+	for dy := -size; dy <= size; dy++ {
+		half := size - int(math.Abs(float64(dy)))
+		drawHorizontalLine(img, cx-half, cx+half, cy+dy, col)
+	}
+}
+
+// textWidth returns the width in pixels of s as drawn by drawText
+func textWidth(s string) int {
+	return font.MeasureString(basicfont.Face7x13, s).Round()
+}
+
+// drawText writes s in a small fixed-width font with its baseline at y
+func drawText(img *image.RGBA, x, y int, s string, col color.RGBA) {
+	d := &font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(col),
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(s)
+}
+
+func drawHorizontalLine(img *image.RGBA, x1, x2, y int, col color.RGBA) {
+	if x1 > x2 {
+		x1, x2 = x2, x1
+	}
+	for i := x1; i <= x2; i++ {
+		if i >= 0 && i < img.Bounds().Dx() && y >= 0 && y < img.Bounds().Dy() {
+			img.Set(i, y, col)
+		}
+	}
+}
+
 func drawVerticalLine(img *image.RGBA, x, y1, y2 int, col color.RGBA) {
 	for j := y1; j <= y2; j++ {
 		if x >= 0 && x < img.Bounds().Dx() && j >= 0 && j < img.Bounds().Dy() {
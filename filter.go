@@ -0,0 +1,302 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// filterNone matches tasks without a status in a status filter, and
+// unassigned tasks in an assignee filter
+const filterNone = "none"
+
+// exportFilter selects which tasks an export includes. Tasks must pass
+// every filter given; a filter listing several values accepts any of them.
+// The zero value includes every task.
+type exportFilter struct {
+	statuses map[string]bool
+	// fields maps custom field keys to the accepted values, as formatted
+	// by formatFieldValue
+	fields map[string]map[string]bool
+	// tags and excludeTags match a task's own tags and those of its
+	// category and parent tasks
+	tags        map[string]bool
+	excludeTags map[string]bool
+	categories  map[string]bool // category IDs
+	assignees   map[string]bool // person IDs
+	// from and to bound the window tasks must overlap; to is exclusive
+	from, to time.Time
+	cal      calendar // the chart's, for task and window quarters
+}
+
+// parseExportFilter reads the task filter from an export query string.
+// Category and custom field filters are checked against the chart.
+func parseExportFilter(query url.Values, chart *Chart) (exportFilter, error) {
+	f := exportFilter{cal: chartCalendar(chart)}
+	if v := query.Get("status"); v != "" {
+		f.statuses = make(map[string]bool)
+		for _, s := range splitList(v) {
+			if s != filterNone && !isStatus(s) {
+				return f, fmt.Errorf("unknown status %q (want %s or %s)", s, statusNames(), filterNone)
+			}
+			f.statuses[s] = true
+		}
+	}
+	if v := query.Get("tags"); v != "" {
+		f.tags = listSet(v)
+	}
+	if v := query.Get("excludeTags"); v != "" {
+		f.excludeTags = listSet(v)
+	}
+
+	if v := query.Get("categories"); v != "" {
+		f.categories = make(map[string]bool)
+		for _, ref := range splitList(v) {
+			id := findCategory(chart, ref)
+			if id == "" {
+				return f, fmt.Errorf("unknown category %q", ref)
+			}
+			f.categories[id] = true
+		}
+	}
+
+	if v := query.Get("assignee"); v != "" {
+		f.assignees = make(map[string]bool)
+		storeMux.RLock()
+		defer storeMux.RUnlock()
+		for _, id := range splitList(v) {
+			if id != filterNone && store.Person(id) == nil {
+				return f, fmt.Errorf("unknown assignee %q (want a person ID or %s)", id, filterNone)
+			}
+			f.assignees[id] = true
+		}
+	}
+
+	for _, bound := range []struct {
+		name string
+		dst  *time.Time
+		end  bool
+	}{{"from", &f.from, false}, {"to", &f.to, true}} {
+		if v := query.Get(bound.name); v != "" {
+			t, err := parseWindowBound(v, bound.end, f.cal)
+			if err != nil {
+				return f, fmt.Errorf("%s: %w", bound.name, err)
+			}
+			*bound.dst = t
+		}
+	}
+	if !f.from.IsZero() && !f.to.IsZero() && !f.to.After(f.from) {
+		return f, errors.New("to must not be before from")
+	}
+
+	for param, values := range query {
+		if !strings.HasPrefix(param, fieldParamPrefix) {
+			continue
+		}
+		def, err := fieldParam(chart, param)
+		if err != nil {
+			return f, err
+		}
+		accepted := make(map[string]bool)
+		for _, v := range splitList(strings.Join(values, ",")) {
+			switch def.Type {
+			case fieldNumber:
+				n, err := strconv.ParseFloat(v, 64)
+				if err != nil {
+					return f, fmt.Errorf("field %q filter value %q is not a number", def.Key, v)
+				}
+				v = formatFieldValue(n)
+			case fieldEnum:
+				if !containsString(def.Options, v) {
+					return f, fmt.Errorf("field %q filter value %q is not one of %s", def.Key, v, strings.Join(def.Options, ", "))
+				}
+			}
+			accepted[v] = true
+		}
+		if f.fields == nil {
+			f.fields = make(map[string]map[string]bool)
+		}
+		f.fields[def.Key] = accepted
+	}
+	return f, nil
+}
+
+// splitList splits a comma separated query value, trimming each item
+func splitList(v string) []string {
+	items := strings.Split(v, ",")
+	for i := range items {
+		items[i] = strings.TrimSpace(items[i])
+	}
+	return items
+}
+
+// containsAny reports whether any item of list is in set
+func containsAny(list []string, set map[string]bool) bool {
+	for _, item := range list {
+		if set[item] {
+			return true
+		}
+	}
+	return false
+}
+
+func listSet(v string) map[string]bool {
+	set := make(map[string]bool)
+	for _, item := range splitList(v) {
+		set[item] = true
+	}
+	return set
+}
+
+// findCategory returns the ID of the category with the given ID or,
+// failing that, name, or "" if there is none
+func findCategory(chart *Chart, ref string) string {
+	for _, cat := range chart.Categories {
+		if cat.ID == ref {
+			return cat.ID
+		}
+	}
+	for _, cat := range chart.Categories {
+		if cat.Name == ref {
+			return cat.ID
+		}
+	}
+	return ""
+}
+
+// parseWindowBound reads a date window bound written as a quarter of the
+// given calendar, such as 2025-Q3, or a date. An end bound covers the
+// whole quarter or day, and is returned as the exclusive instant after it.
+func parseWindowBound(s string, end bool, cal calendar) (time.Time, error) {
+	if year, quarter, err := parseQuarter(s); err == nil {
+		t := cal.quarterStart(year, quarter)
+		if end {
+			t = t.AddDate(0, 3, 0)
+		}
+		return t, nil
+	}
+	if d, ok := parseDate(s); ok {
+		if end {
+			d = d.AddDate(0, 0, 1)
+		}
+		return d, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid bound %q (want e.g. 2025-Q3 or 2025-07-01)", s)
+}
+
+func (f exportFilter) empty() bool {
+	return f.statuses == nil && f.fields == nil && f.tags == nil && f.excludeTags == nil &&
+		f.categories == nil && f.assignees == nil && f.from.IsZero() && f.to.IsZero()
+}
+
+// matches reports whether a task passes the filter, given the tags it
+// carries including those inherited from its category and parents
+func (f exportFilter) matches(task Task, tags []string) bool {
+	if f.statuses != nil {
+		status := task.Status
+		if status == "" {
+			status = filterNone
+		}
+		if !f.statuses[status] {
+			return false
+		}
+	}
+	for key, accepted := range f.fields {
+		v, ok := task.Fields[key]
+		if !ok || !accepted[formatFieldValue(v)] {
+			return false
+		}
+	}
+	if f.tags != nil && !containsAny(tags, f.tags) {
+		return false
+	}
+	if f.assignees != nil {
+		if len(task.Assignees) == 0 {
+			if !f.assignees[filterNone] {
+				return false
+			}
+		} else if !containsAny(task.Assignees, f.assignees) {
+			return false
+		}
+	}
+	if !f.from.IsZero() || !f.to.IsZero() {
+		start, end, ok := taskDates(task, f.cal)
+		if !ok || (!f.from.IsZero() && !end.After(f.from)) || (!f.to.IsZero() && !start.Before(f.to)) {
+			return false
+		}
+	}
+	return true
+}
+
+// inWindow reports whether a milestone date falls within the date window
+func (f exportFilter) inWindow(date string) bool {
+	d, ok := parseDate(date)
+	if !ok {
+		return true
+	}
+	return (f.from.IsZero() || !d.Before(f.from)) && (f.to.IsZero() || d.Before(f.to))
+}
+
+// apply returns a copy of the chart holding only the matching tasks. A
+// parent task is kept while any of its subtasks match, and an excluded
+// tag drops a task or category with everything under it. Categories
+// left without tasks, whether the filter emptied them or they had none,
+// are dropped unless they hold milestones in the window or the category
+// filter names them; milestones go with their category or when outside
+// the date window.
+func (f exportFilter) apply(chart *Chart) *Chart {
+	if f.empty() {
+		return chart
+	}
+
+	hasMilestones := make(map[string]bool)
+	for _, m := range chart.Milestones {
+		if f.inWindow(m.Date) {
+			hasMilestones[m.CategoryID] = true
+		}
+	}
+
+	filtered := cloneChart(chart)
+	categories := filtered.Categories
+	filtered.Categories = make([]Category, 0, len(categories))
+	kept := make(map[string]bool)
+	for _, cat := range categories {
+		if f.categories != nil && !f.categories[cat.ID] || containsAny(cat.Tags, f.excludeTags) {
+			continue
+		}
+		tasks := f.filterTasks(cat.Tasks, cat.Tags)
+		if len(tasks) == 0 && !hasMilestones[cat.ID] && !f.categories[cat.ID] {
+			continue
+		}
+		cat.Tasks = tasks
+		filtered.Categories = append(filtered.Categories, cat)
+		kept[cat.ID] = true
+	}
+
+	milestones := filtered.Milestones
+	filtered.Milestones = nil
+	for _, m := range milestones {
+		if (m.CategoryID == "" || kept[m.CategoryID]) && f.inWindow(m.Date) {
+			filtered.Milestones = append(filtered.Milestones, m)
+		}
+	}
+	return filtered
+}
+
+func (f exportFilter) filterTasks(tasks []Task, inherited []string) []Task {
+	kept := tasks[:0]
+	for _, task := range tasks {
+		tags := append(inherited[:len(inherited):len(inherited)], task.Tags...)
+		if containsAny(tags, f.excludeTags) {
+			continue
+		}
+		task.Subtasks = f.filterTasks(task.Subtasks, tags)
+		if len(task.Subtasks) > 0 || f.matches(task, tags) {
+			kept = append(kept, task)
+		}
+	}
+	return kept
+}
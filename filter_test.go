@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestFilterKeepsCategories(t *testing.T) {
+	chart := &Chart{
+		StartYear: 2025, StartQ: 1, EndYear: 2025, EndQ: 4,
+		Categories: []Category{
+			{ID: "eng", Name: "Engineering", Tasks: []Task{
+				{ID: "api", Title: "API", Status: statusBlocked, StartYear: 2025, StartQ: 1, EndYear: 2025, EndQ: 2},
+				{ID: "ui", Title: "UI", Status: statusDone},
+			}},
+			{ID: "ops", Name: "Operations", Tasks: []Task{{ID: "deploy", Title: "Deploy", Status: statusDone}}},
+			{ID: "empty", Name: "Empty"},
+			{ID: "launch", Name: "Launch"},
+		},
+		Milestones: []Milestone{{ID: "ga", Title: "GA", Date: "2025-10-01", CategoryID: "launch"}},
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"status=blocked", []string{"eng", "launch"}},
+		{"status=blocked&categories=eng,empty", []string{"eng", "empty"}},
+		{"status=blocked&categories=ops", []string{"ops"}},
+		{"status=blocked&to=2025-Q3", []string{"eng"}},
+		{"excludeTags=legacy", []string{"eng", "ops", "launch"}},
+	}
+	for _, test := range tests {
+		query, _ := url.ParseQuery(test.query)
+		f, err := parseExportFilter(query, chart)
+		if err != nil {
+			t.Fatalf("%s: %v", test.query, err)
+		}
+		var got []string
+		for _, cat := range f.apply(chart).Categories {
+			got = append(got, cat.ID)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: kept categories %v, want %v", test.query, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fiscal year naming: by the calendar year the fiscal year ends in (the
+// default) or starts in
+const (
+	fiscalNamedByEnd   = "end"
+	fiscalNamedByStart = "start"
+)
+
+// defaultFiscalLabel is the quarter label of a fiscal calendar without one
+const defaultFiscalLabel = "FY{yy} Q{q}"
+
+// calendar places a chart's quarters in time and labels them. Charts
+// without a fiscal calendar use calendarYear.
+type calendar struct {
+	startMonth   int    // first month of the year, 1-12
+	label        string // quarter label with {yyyy}, {yy} and {q} placeholders
+	namedByStart bool   // a year starting mid-year is named after its first calendar year
+}
+
+var calendarYear = calendar{startMonth: 1, label: "Q{q} {yyyy}"}
+
+// chartCalendar returns the calendar a chart's year and quarter fields
+// are in
+func chartCalendar(chart *Chart) calendar {
+	if chart.Fiscal == nil {
+		return calendarYear
+	}
+	c := calendar{startMonth: chart.Fiscal.StartMonth, label: chart.Fiscal.Label, namedByStart: chart.Fiscal.NamedBy == fiscalNamedByStart}
+	if c.label == "" {
+		c.label = defaultFiscalLabel
+	}
+	return c
+}
+
+// quarterStart returns the first day of a quarter
+func (c calendar) quarterStart(year, quarter int) time.Time {
+	first := year
+	if c.startMonth > 1 && !c.namedByStart {
+		first--
+	}
+	// time.Date normalises months past December into the next year
+	return time.Date(first, time.Month(c.startMonth+(quarter-1)*3), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// quarterOf returns the year and quarter containing d
+func (c calendar) quarterOf(d time.Time) (int, int) {
+	month := int(d.Month())
+	year := d.Year()
+	if month < c.startMonth {
+		year--
+	}
+	if c.startMonth > 1 && !c.namedByStart {
+		year++
+	}
+	return year, (month-c.startMonth+12)%12/3 + 1
+}
+
+func (c calendar) quarterLabel(year, quarter int) string {
+	return strings.NewReplacer(
+		"{yyyy}", strconv.Itoa(year),
+		"{yy}", fmt.Sprintf("%02d", year%100),
+		"{q}", strconv.Itoa(quarter),
+	).Replace(c.label)
+}
+
+// validateFiscal checks the chart's fiscal calendar settings
+func validateFiscal(chart *Chart) error {
+	f := chart.Fiscal
+	if f == nil {
+		return nil
+	}
+	var errs []error
+	if f.StartMonth < 1 || f.StartMonth > 12 {
+		errs = append(errs, fieldErrorf("/fiscal/startMonth", "fiscal start month %d is out of range (want 1-12)", f.StartMonth))
+	}
+	if f.NamedBy != "" && f.NamedBy != fiscalNamedByEnd && f.NamedBy != fiscalNamedByStart {
+		errs = append(errs, fieldErrorf("/fiscal/namedBy", "fiscal namedBy %q is unknown (want %s or %s)", f.NamedBy, fiscalNamedByEnd, fiscalNamedByStart))
+	}
+	if f.Label != "" && !strings.Contains(f.Label, "{q}") {
+		errs = append(errs, fieldErrorf("/fiscal/label", "fiscal label must contain {q}"))
+	}
+	return errors.Join(errs...)
+}
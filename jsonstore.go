@@ -0,0 +1,392 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// JSONFileStore is a ChartStore that keeps every chart in memory and
+// writes the whole collection to a single JSON file on Save. The people
+// directory is kept in a companion file next to it, and each chart's
+// revisions in a file of their own so a save only rewrites the history
+// of the charts it changed.
+type JSONFileStore struct {
+	filename       string
+	charts         map[string]*Chart
+	saved          map[string]*Chart
+	revisions      map[string][]*Revision
+	savedRevisions map[string][]*Revision
+	dirtyRevisions map[string]bool
+	people         map[string]*Person
+	savedPeople    map[string]*Person
+}
+
+// NewJSONFileStore creates a store backed by the given file
+func NewJSONFileStore(filename string) *JSONFileStore {
+	return &JSONFileStore{
+		filename:       filename,
+		charts:         make(map[string]*Chart),
+		saved:          make(map[string]*Chart),
+		revisions:      make(map[string][]*Revision),
+		savedRevisions: make(map[string][]*Revision),
+		dirtyRevisions: make(map[string]bool),
+		people:         make(map[string]*Person),
+		savedPeople:    make(map[string]*Person),
+	}
+}
+
+// revisionsDir returns the directory holding the revision history, e.g.
+// charts.revisions for charts.json
+func (s *JSONFileStore) revisionsDir() string {
+	return strings.TrimSuffix(s.filename, filepath.Ext(s.filename)) + ".revisions"
+}
+
+// revisionsFile returns the path of a chart's revision history. The ID
+// is escaped so that it cannot name a file outside the directory.
+func (s *JSONFileStore) revisionsFile(chartID string) string {
+	return filepath.Join(s.revisionsDir(), url.PathEscape(chartID)+".json")
+}
+
+// legacyRevisionsFile returns the path of the single revision history
+// file used by earlier versions, e.g. charts.revisions.json
+func (s *JSONFileStore) legacyRevisionsFile() string {
+	return s.revisionsDir() + ".json"
+}
+
+// peopleFile returns the path of the people directory file, e.g.
+// charts.people.json for charts.json
+func (s *JSONFileStore) peopleFile() string {
+	return strings.TrimSuffix(s.filename, filepath.Ext(s.filename)) + ".people.json"
+}
+
+// Add adds a new chart to the store
+func (s *JSONFileStore) Add(chart *Chart) {
+	normalizeChart(chart)
+	now := time.Now()
+	chart.CreatedAt = now
+	chart.UpdatedAt = now
+	chart.Version = 1
+
+	s.charts[chart.ID] = chart
+}
+
+// Get retrieves a chart by ID
+func (s *JSONFileStore) Get(id string) *Chart {
+	return s.charts[id]
+}
+
+// GetAll returns all charts
+func (s *JSONFileStore) GetAll() []*Chart {
+	charts := make([]*Chart, 0, len(s.charts))
+	for _, chart := range s.charts {
+		charts = append(charts, chart)
+	}
+	return charts
+}
+
+// Update updates an existing chart
+func (s *JSONFileStore) Update(chart *Chart) {
+	chart.Version = 1
+	if existing := s.charts[chart.ID]; existing != nil {
+		chart.CreatedAt = existing.CreatedAt
+		chart.Version = existing.Version + 1
+	}
+	chart.UpdatedAt = time.Now()
+	normalizeChart(chart)
+
+	s.charts[chart.ID] = chart
+}
+
+// Delete removes a chart and its revisions
+func (s *JSONFileStore) Delete(id string) {
+	delete(s.charts, id)
+	delete(s.revisions, id)
+	s.dirtyRevisions[id] = true
+}
+
+// AddRevision records a snapshot of chart as its next revision
+func (s *JSONFileStore) AddRevision(chart *Chart, author, message string) *Revision {
+	revs := s.revisions[chart.ID]
+	rev := newRevision(chart, len(revs)+1, author, message)
+	// Copy rather than append so the saved history is never aliased
+	s.revisions[chart.ID] = append(revs[:len(revs):len(revs)], rev)
+	s.dirtyRevisions[chart.ID] = true
+	return rev
+}
+
+// Revisions lists the revisions of a chart, oldest first
+func (s *JSONFileStore) Revisions(chartID string) []*Revision {
+	return s.revisions[chartID]
+}
+
+// Revision returns a single revision of a chart
+func (s *JSONFileStore) Revision(chartID string, number int) *Revision {
+	revs := s.revisions[chartID]
+	if number < 1 || number > len(revs) {
+		return nil
+	}
+	return revs[number-1]
+}
+
+// People lists the people directory, sorted by name
+func (s *JSONFileStore) People() []*Person {
+	people := make([]*Person, 0, len(s.people))
+	for _, person := range s.people {
+		people = append(people, person)
+	}
+	return sortPeople(people)
+}
+
+// Person retrieves a person by ID
+func (s *JSONFileStore) Person(id string) *Person {
+	return s.people[id]
+}
+
+// PutPerson adds or replaces a person
+func (s *JSONFileStore) PutPerson(person *Person) {
+	normalizePerson(person)
+	s.people[person.ID] = person
+}
+
+// DeletePerson removes a person from the directory
+func (s *JSONFileStore) DeletePerson(id string) {
+	delete(s.people, id)
+}
+
+// Save persists the charts to the data file. The previous contents are
+// kept in a .bak file next to it.
+func (s *JSONFileStore) Save() error {
+	data, err := json.MarshalIndent(s.charts, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if previous, err := os.ReadFile(s.filename); err == nil {
+		if err := writeFileAtomic(s.filename+".bak", previous, 0644); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	peopleData, err := json.MarshalIndent(s.people, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// People and history go first so a chart is never persisted without
+	// its revision or the people it references
+	if err := writeFileAtomic(s.peopleFile(), peopleData, 0644); err != nil {
+		return err
+	}
+	for id := range s.dirtyRevisions {
+		if err := s.writeRevisions(id); err != nil {
+			return err
+		}
+	}
+	if err := writeFileAtomic(s.filename, data, 0644); err != nil {
+		return err
+	}
+
+	s.saved = cloneChartMap(s.charts)
+	s.savedRevisions = cloneRevisionMap(s.revisions)
+	s.dirtyRevisions = make(map[string]bool)
+	s.savedPeople = clonePersonMap(s.people)
+	return nil
+}
+
+// writeRevisions writes the revision history of one chart, removing the
+// file once the chart has none
+func (s *JSONFileStore) writeRevisions(chartID string) error {
+	revs := s.revisions[chartID]
+	if len(revs) == 0 {
+		if err := os.Remove(s.revisionsFile(chartID)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(revs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.revisionsDir(), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(s.revisionsFile(chartID), data, 0644)
+}
+
+// Rollback restores the charts as of the last successful Save or Load.
+// Charts whose history changed stay dirty, since a failed Save may already
+// have written their revision files, so the next Save rewrites them.
+func (s *JSONFileStore) Rollback() {
+	s.charts = cloneChartMap(s.saved)
+	s.revisions = cloneRevisionMap(s.savedRevisions)
+	s.people = clonePersonMap(s.savedPeople)
+}
+
+func cloneRevisionMap(revisions map[string][]*Revision) map[string][]*Revision {
+	clone := make(map[string][]*Revision, len(revisions))
+	for id, revs := range revisions {
+		clone[id] = revs[:len(revs):len(revs)]
+	}
+	return clone
+}
+
+// Load reads charts from the data file, falling back to the .bak copy
+// if the data file is unreadable
+func (s *JSONFileStore) Load() error {
+	people := make(map[string]*Person)
+	if data, err := os.ReadFile(s.peopleFile()); err == nil {
+		if err := json.Unmarshal(data, &people); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	s.people = people
+	s.savedPeople = clonePersonMap(people)
+
+	charts, err := readChartsFile(s.filename)
+	if err != nil {
+		backup, bakErr := readChartsFile(s.filename + ".bak")
+		if bakErr != nil || backup == nil {
+			return err
+		}
+		logWarnf("Could not load %s (%v), recovered from backup", s.filename, err)
+		charts = backup
+	}
+	if charts == nil {
+		return nil // File doesn't exist yet, not an error
+	}
+
+	revisions, err := s.readRevisions()
+	if err != nil {
+		return err
+	}
+
+	s.charts = charts
+	s.saved = cloneChartMap(charts)
+	s.revisions = revisions
+	s.savedRevisions = cloneRevisionMap(revisions)
+	s.dirtyRevisions = make(map[string]bool)
+	return s.migrateRevisionsFile()
+}
+
+// readRevisions reads the revision history of every chart, including
+// any still in the legacy single file
+func (s *JSONFileStore) readRevisions() (map[string][]*Revision, error) {
+	revisions := make(map[string][]*Revision)
+	if data, err := os.ReadFile(s.legacyRevisionsFile()); err == nil {
+		if err := json.Unmarshal(data, &revisions); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(s.revisionsDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return revisions, nil
+		}
+		return nil, err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".json" {
+			continue
+		}
+		id, err := url.PathUnescape(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.revisionsDir(), name))
+		if err != nil {
+			return nil, err
+		}
+		var revs []*Revision
+		if err := json.Unmarshal(data, &revs); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		revisions[id] = revs
+	}
+	return revisions, nil
+}
+
+// migrateRevisionsFile moves the history out of the legacy single file
+// into per-chart files
+func (s *JSONFileStore) migrateRevisionsFile() error {
+	if _, err := os.Stat(s.legacyRevisionsFile()); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for id := range s.revisions {
+		if err := s.writeRevisions(id); err != nil {
+			return err
+		}
+	}
+	logInfof("Moved revision history from %s to %s", s.legacyRevisionsFile(), s.revisionsDir())
+	return os.Remove(s.legacyRevisionsFile())
+}
+
+// readChartsFile parses a charts file, returning nil if it does not exist
+func readChartsFile(filename string) (map[string]*Chart, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	charts := make(map[string]*Chart)
+	if err := json.Unmarshal(data, &charts); err != nil {
+		return nil, err
+	}
+	return charts, nil
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory,
+// syncs it and renames it over filename, so readers and crashes only ever
+// see the old or the new contents
+func writeFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(filename)
+	tmp, err := os.CreateTemp(dir, filepath.Base(filename)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // no-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, filename); err != nil {
+		return err
+	}
+
+	// Persist the rename itself; not all platforms support syncing directories
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import "log"
+
+// Log levels in increasing order of severity
+const (
+	levelDebug = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+var minLogLevel = levelInfo
+
+// setLogLevel sets the minimum level that is written to the log
+func setLogLevel(level string) {
+	for i, l := range logLevels {
+		if l == level {
+			minLogLevel = i
+		}
+	}
+}
+
+func logf(level int, format string, args ...interface{}) {
+	if level < minLogLevel {
+		return
+	}
+	log.Printf(logLevelPrefixes[level]+format, args...)
+}
+
+var logLevelPrefixes = []string{"DEBUG ", "", "WARN ", "ERROR "}
+
+func logDebugf(format string, args ...interface{}) { logf(levelDebug, format, args...) }
+func logInfof(format string, args ...interface{})  { logf(levelInfo, format, args...) }
+func logWarnf(format string, args ...interface{})  { logf(levelWarn, format, args...) }
+func logErrorf(format string, args ...interface{}) { logf(levelError, format, args...) }
@@ -2,10 +2,13 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"sync"
 
 	"github.com/gorilla/mux"
@@ -16,54 +19,125 @@ const (
 	contentTypeHeader  = "Content-Type"
 	jsonContentType    = "application/json"
 	chartNotFoundMsg   = "Chart not found"
+	saveFailedMsg      = "Failed to save changes"
 	contentDisposition = "Content-Disposition"
+	etagHeader         = "ETag"
+	ifMatchHeader      = "If-Match"
 )
 
 var (
-	store    *ChartStore
+	store    ChartStore
 	storeMux sync.RWMutex
-	dataFile = "charts.json"
+	cfg      *Config
 )
 
 func main() {
+	var err error
+	cfg, err = loadConfig(os.Args[1:])
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	setLogLevel(cfg.LogLevel)
+
+	if cfg.PrintConfig {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(cfg)
+		return
+	}
+
+	if err := os.MkdirAll(cfg.DataDir, 0755); err != nil {
+		log.Fatalf("Could not create data directory: %v", err)
+	}
+
 	// Initialize the store
-	store = NewChartStore()
-	if err := store.Load(dataFile); err != nil {
-		log.Printf("Could not load data file: %v", err)
+	switch cfg.Storage {
+	case storageSQLite:
+		sqliteStore, err := NewSQLiteStore(cfg.DataFile())
+		if err != nil {
+			log.Fatalf("Could not open database: %v", err)
+		}
+		defer sqliteStore.Close()
+		store = sqliteStore
+	default:
+		store = NewJSONFileStore(cfg.DataFile())
+	}
+	if err := store.Load(); err != nil {
+		logErrorf("Could not load data file: %v", err)
 	}
 
-	router := mux.NewRouter()
+	if cfg.ImportJSON != "" {
+		n, err := store.(*SQLiteStore).ImportJSONFile(cfg.ImportJSON)
+		if err != nil {
+			log.Fatalf("Import failed: %v", err)
+		}
+		logInfof("Imported %d charts from %s", n, cfg.ImportJSON)
+		return
+	}
+
+	router := apiRouter()
+
+	// Serve static files
+	router.PathPrefix("/").Handler(http.FileServer(http.Dir(cfg.StaticDir)))
+
+	logInfof("Server starting on %s (storage: %s, data: %s)", cfg.ListenAddr, cfg.Storage, cfg.DataFile())
+	log.Fatal(http.ListenAndServe(cfg.ListenAddr, router))
+}
 
-	// API routes
+// apiRouter returns a router serving the API routes under /api. Every
+// route must be described in apiOperations; TestContract enforces this.
+func apiRouter() *mux.Router {
+	router := mux.NewRouter()
 	api := router.PathPrefix("/api").Subrouter()
+	api.HandleFunc("/openapi.json", openAPIHandler).Methods("GET")
 	api.HandleFunc("/charts", getChartsHandler).Methods("GET")
 	api.HandleFunc("/charts", createChartHandler).Methods("POST")
 	api.HandleFunc(chartIDPath, getChartHandler).Methods("GET")
 	api.HandleFunc(chartIDPath, updateChartHandler).Methods("PUT")
+	api.HandleFunc(chartIDPath, patchChartHandler).Methods("PATCH")
 	api.HandleFunc(chartIDPath, deleteChartHandler).Methods("DELETE")
+	api.HandleFunc(chartIDPath+"/revisions", listRevisionsHandler).Methods("GET")
+	api.HandleFunc(chartIDPath+"/revisions/diff", diffRevisionsHandler).Methods("GET")
+	api.HandleFunc(chartIDPath+"/revisions/{rev:[0-9]+}", getRevisionHandler).Methods("GET")
+	api.HandleFunc(chartIDPath+"/revisions/{rev:[0-9]+}/restore", restoreRevisionHandler).Methods("POST")
+	api.HandleFunc(chartIDPath+"/milestones", listMilestonesHandler).Methods("GET")
+	api.HandleFunc(chartIDPath+"/milestones", createMilestoneHandler).Methods("POST")
+	api.HandleFunc(chartIDPath+"/milestones/{milestoneId}", getMilestoneHandler).Methods("GET")
+	api.HandleFunc(chartIDPath+"/milestones/{milestoneId}", updateMilestoneHandler).Methods("PUT")
+	api.HandleFunc(chartIDPath+"/milestones/{milestoneId}", deleteMilestoneHandler).Methods("DELETE")
+	api.HandleFunc(chartIDPath+"/categories", listCategoriesHandler).Methods("GET")
+	api.HandleFunc(chartIDPath+"/categories", createCategoryHandler).Methods("POST")
+	api.HandleFunc(chartIDPath+"/categories/{catId}", getCategoryHandler).Methods("GET")
+	api.HandleFunc(chartIDPath+"/categories/{catId}", updateCategoryHandler).Methods("PATCH")
+	api.HandleFunc(chartIDPath+"/categories/{catId}", deleteCategoryHandler).Methods("DELETE")
+	api.HandleFunc(chartIDPath+"/categories/{catId}/tasks", listTasksHandler).Methods("GET")
+	api.HandleFunc(chartIDPath+"/categories/{catId}/tasks", createTaskHandler).Methods("POST")
+	api.HandleFunc(chartIDPath+"/categories/{catId}/tasks/{taskId}", getTaskHandler).Methods("GET")
+	api.HandleFunc(chartIDPath+"/categories/{catId}/tasks/{taskId}", updateTaskHandler).Methods("PATCH")
+	api.HandleFunc(chartIDPath+"/categories/{catId}/tasks/{taskId}", deleteTaskHandler).Methods("DELETE")
+	api.HandleFunc(chartIDPath+"/categories/{catId}/tasks/{taskId}/move", moveTaskHandler).Methods("POST")
+	api.HandleFunc(chartIDPath+"/schedule", scheduleHandler).Methods("GET")
+	api.HandleFunc(chartIDPath+"/baselines", listBaselinesHandler).Methods("GET")
+	api.HandleFunc(chartIDPath+"/baselines", createBaselineHandler).Methods("POST")
+	api.HandleFunc(chartIDPath+"/baselines/{baselineId}", getBaselineHandler).Methods("GET")
+	api.HandleFunc(chartIDPath+"/baselines/{baselineId}", deleteBaselineHandler).Methods("DELETE")
+	api.HandleFunc(chartIDPath+"/baselines/{baselineId}/slip", baselineSlipHandler).Methods("GET")
+	api.HandleFunc("/people", listPeopleHandler).Methods("GET")
+	api.HandleFunc("/people", createPersonHandler).Methods("POST")
+	api.HandleFunc("/people/{personId}", getPersonHandler).Methods("GET")
+	api.HandleFunc("/people/{personId}", updatePersonHandler).Methods("PUT")
+	api.HandleFunc("/people/{personId}", deletePersonHandler).Methods("DELETE")
+	api.HandleFunc("/people/{personId}/tasks", personTasksHandler).Methods("GET")
+	api.HandleFunc("/workload", workloadHandler).Methods("GET")
+	api.HandleFunc("/workload/svg", workloadSVGHandler).Methods("GET")
+	api.HandleFunc("/workload/png", workloadPNGHandler).Methods("GET")
 	api.HandleFunc(chartIDPath+"/export/svg", exportSVGHandler).Methods("GET")
 	api.HandleFunc(chartIDPath+"/export/png", exportPNGHandler).Methods("GET")
 	api.HandleFunc(chartIDPath+"/export/pdf", exportPDFHandler).Methods("GET")
-
-	// Serve static files
-	router.PathPrefix("/").Handler(http.FileServer(http.Dir("./static")))
-
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-
-	log.Printf("Server starting on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, router))
-}
-
-func getChartsHandler(w http.ResponseWriter, r *http.Request) {
-	storeMux.RLock()
-	charts := store.GetAll()
-	storeMux.RUnlock()
-
-	w.Header().Set(contentTypeHeader, jsonContentType)
-	json.NewEncoder(w).Encode(charts)
+	return router
 }
 
 func createChartHandler(w http.ResponseWriter, r *http.Request) {
@@ -72,13 +146,38 @@ func createChartHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	// Baselines are only made through the baseline endpoints
+	chart.Baselines = nil
+	if err := validateChart(&chart); err != nil {
+		writeValidationProblem(w, err)
+		return
+	}
 
 	storeMux.Lock()
+	// Replacing a chart goes through PUT, which honours If-Match
+	if chart.ID != "" && store.Get(chart.ID) != nil {
+		storeMux.Unlock()
+		http.Error(w, fmt.Sprintf("chart %q already exists", chart.ID), http.StatusConflict)
+		return
+	}
+	if err := validateAssignees(&chart); err != nil {
+		storeMux.Unlock()
+		writeValidationProblem(w, err)
+		return
+	}
 	store.Add(&chart)
-	store.Save(dataFile)
+	store.AddRevision(&chart, requestAuthor(r), "")
+	if err := store.Save(); err != nil {
+		store.Rollback()
+		storeMux.Unlock()
+		logErrorf("Error saving chart %s: %v", chart.ID, err)
+		http.Error(w, saveFailedMsg, http.StatusInternalServerError)
+		return
+	}
 	storeMux.Unlock()
 
 	w.Header().Set(contentTypeHeader, jsonContentType)
+	w.Header().Set(etagHeader, chartETag(&chart))
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(chart)
 }
@@ -97,6 +196,7 @@ func getChartHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set(contentTypeHeader, jsonContentType)
+	w.Header().Set(etagHeader, chartETag(chart))
 	json.NewEncoder(w).Encode(chart)
 }
 
@@ -109,14 +209,40 @@ func updateChartHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if err := validateChart(&chart); err != nil {
+		writeValidationProblem(w, err)
+		return
+	}
 
 	chart.ID = id
 	storeMux.Lock()
+	current := store.Get(id)
+	if preconditionFailed(r, current) {
+		storeMux.Unlock()
+		writePreconditionFailed(w, current)
+		return
+	}
+	if err := errors.Join(validateTransitions(current, &chart), validateAssignees(&chart)); err != nil {
+		storeMux.Unlock()
+		writeValidationProblem(w, err)
+		return
+	}
+	if current != nil {
+		chart.Baselines = current.Baselines
+	}
 	store.Update(&chart)
-	store.Save(dataFile)
+	store.AddRevision(&chart, requestAuthor(r), "")
+	if err := store.Save(); err != nil {
+		store.Rollback()
+		storeMux.Unlock()
+		logErrorf("Error saving chart %s: %v", id, err)
+		http.Error(w, saveFailedMsg, http.StatusInternalServerError)
+		return
+	}
 	storeMux.Unlock()
 
 	w.Header().Set(contentTypeHeader, jsonContentType)
+	w.Header().Set(etagHeader, chartETag(&chart))
 	json.NewEncoder(w).Encode(chart)
 }
 
@@ -125,27 +251,129 @@ func deleteChartHandler(w http.ResponseWriter, r *http.Request) {
 	id := vars["id"]
 
 	storeMux.Lock()
+	if current := store.Get(id); preconditionFailed(r, current) {
+		storeMux.Unlock()
+		writePreconditionFailed(w, current)
+		return
+	}
 	store.Delete(id)
-	store.Save(dataFile)
+	if err := store.Save(); err != nil {
+		store.Rollback()
+		storeMux.Unlock()
+		logErrorf("Error deleting chart %s: %v", id, err)
+		http.Error(w, saveFailedMsg, http.StatusInternalServerError)
+		return
+	}
 	storeMux.Unlock()
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func exportSVGHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id := vars["id"]
+// httpError is an error that should be reported with a specific status
+type httpError struct {
+	status int
+	msg    string
+}
 
-	storeMux.RLock()
-	chart := store.Get(id)
-	storeMux.RUnlock()
+func (e *httpError) Error() string {
+	return e.msg
+}
 
-	if chart == nil {
+// modifyChart applies fn to a copy of the chart named in the request and
+// saves the result as a new revision, honouring If-Match. It writes the
+// error response itself and reports whether the change was saved; on
+// success the ETag header is set and the caller writes the body. Errors
+// from fn and validation are reported as a 422 problem unless they are an
+// *httpError.
+func modifyChart(w http.ResponseWriter, r *http.Request, message string, fn func(chart *Chart) error) (*Chart, bool) {
+	id := mux.Vars(r)["id"]
+
+	storeMux.Lock()
+	defer storeMux.Unlock()
+
+	current := store.Get(id)
+	if current == nil {
 		http.Error(w, chartNotFoundMsg, http.StatusNotFound)
+		return nil, false
+	}
+	if preconditionFailed(r, current) {
+		writePreconditionFailed(w, current)
+		return nil, false
+	}
+
+	chart := cloneChart(current)
+	err := fn(chart)
+	if err == nil {
+		err = errors.Join(validateChart(chart), validateTransitions(current, chart), validateAssignees(chart))
+	}
+	if err != nil {
+		var he *httpError
+		if errors.As(err, &he) {
+			http.Error(w, he.msg, he.status)
+		} else {
+			writeValidationProblem(w, err)
+		}
+		return nil, false
+	}
+
+	store.Update(chart)
+	store.AddRevision(chart, requestAuthor(r), message)
+	if err := store.Save(); err != nil {
+		store.Rollback()
+		logErrorf("Error saving chart %s: %v", id, err)
+		http.Error(w, saveFailedMsg, http.StatusInternalServerError)
+		return nil, false
+	}
+
+	w.Header().Set(etagHeader, chartETag(chart))
+	return chart, true
+}
+
+// chartETag returns the strong entity tag for the current version of a chart
+func chartETag(chart *Chart) string {
+	return fmt.Sprintf(`"%d"`, chart.Version)
+}
+
+// preconditionFailed reports whether the request's If-Match header rules
+// out modifying chart, which is nil if it does not exist
+func preconditionFailed(r *http.Request, chart *Chart) bool {
+	ifMatch := r.Header.Get(ifMatchHeader)
+	if ifMatch == "" {
+		return false
+	}
+	if chart == nil {
+		return true
+	}
+	for _, tag := range strings.Split(ifMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == chartETag(chart) {
+			return false
+		}
+	}
+	return true
+}
+
+// writePreconditionFailed responds with 412 and the current chart so the
+// client can merge its changes and retry
+func writePreconditionFailed(w http.ResponseWriter, chart *Chart) {
+	if chart == nil {
+		http.Error(w, chartNotFoundMsg, http.StatusPreconditionFailed)
 		return
 	}
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	w.Header().Set(etagHeader, chartETag(chart))
+	w.WriteHeader(http.StatusPreconditionFailed)
+	json.NewEncoder(w).Encode(chart)
+}
 
-	svg, err := GenerateSVG(chart)
+func exportSVGHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	chart, opts, ok := prepareExport(w, r)
+	if !ok {
+		return
+	}
+
+	svg, err := GenerateSVG(chart, opts)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error generating SVG: %v", err), http.StatusInternalServerError)
 		return
@@ -157,19 +385,13 @@ func exportSVGHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func exportPNGHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id := vars["id"]
-
-	storeMux.RLock()
-	chart := store.Get(id)
-	storeMux.RUnlock()
-
-	if chart == nil {
-		http.Error(w, chartNotFoundMsg, http.StatusNotFound)
+	id := mux.Vars(r)["id"]
+	chart, opts, ok := prepareExport(w, r)
+	if !ok {
 		return
 	}
 
-	pngData, err := GeneratePNG(chart)
+	pngData, err := GeneratePNG(chart, opts)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error generating PNG: %v", err), http.StatusInternalServerError)
 		return
@@ -181,19 +403,13 @@ func exportPNGHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func exportPDFHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id := vars["id"]
-
-	storeMux.RLock()
-	chart := store.Get(id)
-	storeMux.RUnlock()
-
-	if chart == nil {
-		http.Error(w, chartNotFoundMsg, http.StatusNotFound)
+	id := mux.Vars(r)["id"]
+	chart, opts, ok := prepareExport(w, r)
+	if !ok {
 		return
 	}
 
-	pdfData, err := GeneratePDF(chart)
+	pdfData, err := GeneratePDF(chart, opts)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error generating PDF: %v", err), http.StatusInternalServerError)
 		return
@@ -203,3 +419,122 @@ func exportPDFHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set(contentDisposition, fmt.Sprintf("attachment; filename=\"chart-%s.pdf\"", id))
 	w.Write(pdfData)
 }
+
+// prepareExport loads the chart named in an export request, applies the
+// request's task filter and reads its rendering options. It writes the
+// error response itself and reports whether the export can go ahead.
+func prepareExport(w http.ResponseWriter, r *http.Request) (*Chart, ExportOptions, bool) {
+	storeMux.RLock()
+	chart := store.Get(mux.Vars(r)["id"])
+	storeMux.RUnlock()
+
+	if chart == nil {
+		http.Error(w, chartNotFoundMsg, http.StatusNotFound)
+		return nil, ExportOptions{}, false
+	}
+
+	// Options come first so that analyses such as the critical path see
+	// the whole chart, not just the filtered tasks
+	opts, status, err := exportOptions(r, chart)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return nil, opts, false
+	}
+	filter, err := parseExportFilter(r.URL.Query(), chart)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, opts, false
+	}
+	chart = filter.apply(chart)
+	if groupBy := r.URL.Query().Get("groupBy"); groupBy != "" {
+		def, err := fieldParam(chart, groupBy)
+		if err != nil {
+			http.Error(w, "groupBy: "+err.Error(), http.StatusBadRequest)
+			return nil, opts, false
+		}
+		chart = groupByField(chart, *def)
+	}
+
+	if err := checkExportLimits(chart); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return nil, opts, false
+	}
+	return chart, opts, true
+}
+
+// exportOptions reads the rendering options from the export request's
+// query string. On error it also returns the status to report.
+func exportOptions(r *http.Request, chart *Chart) (ExportOptions, int, error) {
+	var opts ExportOptions
+	switch highlight := r.URL.Query().Get("highlight"); highlight {
+	case "":
+	case "critical":
+		critical, err := criticalTasks(chart)
+		if err != nil {
+			return opts, http.StatusUnprocessableEntity, fmt.Errorf("cannot schedule chart: %w", err)
+		}
+		opts.Critical = critical
+	default:
+		return opts, http.StatusBadRequest, fmt.Errorf("unknown highlight %q (want critical)", highlight)
+	}
+
+	if labels := r.URL.Query().Get("labels"); labels != "" {
+		var fields []*FieldDef
+		for _, label := range strings.Split(labels, ",") {
+			label = strings.TrimSpace(label)
+			switch {
+			case label == "progress":
+				opts.ProgressLabels = true
+			case label == "assignees":
+				storeMux.RLock()
+				opts.Initials = taskInitials(chart, peopleByID())
+				storeMux.RUnlock()
+			case strings.HasPrefix(label, fieldParamPrefix):
+				def, err := fieldParam(chart, label)
+				if err != nil {
+					return opts, http.StatusBadRequest, err
+				}
+				fields = append(fields, def)
+			default:
+				return opts, http.StatusBadRequest, fmt.Errorf("unknown label %q (want progress, assignees or %s<key>)", label, fieldParamPrefix)
+			}
+		}
+		if len(fields) > 0 {
+			opts.FieldLabels = fieldLabels(chart, fields)
+		}
+	}
+
+	if ref := r.URL.Query().Get("baseline"); ref != "" {
+		i := findBaseline(chart, ref)
+		if i < 0 {
+			return opts, http.StatusBadRequest, fmt.Errorf("unknown baseline %q", ref)
+		}
+		opts.Baseline = baselineTasks(chart.Baselines[i])
+	}
+	return opts, 0, nil
+}
+
+// checkExportLimits rejects charts too large to render within the
+// configured export limits
+func checkExportLimits(chart *Chart) error {
+	tl := buildTimeline(chart)
+	if limit := exportColumnLimit(tl.granularity); len(tl.columns) > limit {
+		return fmt.Errorf("chart spans %d %s columns, export limit is %d", len(tl.columns), tl.granularity, limit)
+	}
+
+	tasks := 0
+	for _, cat := range chart.Categories {
+		tasks += len(allTasks(cat.Tasks))
+	}
+	if tasks > cfg.ExportMaxTasks {
+		return fmt.Errorf("chart has %d tasks, export limit is %d", tasks, cfg.ExportMaxTasks)
+	}
+	return nil
+}
+
+// exportColumnLimit scales the configured column limit, which is counted
+// in quarters, to a granularity. Finer columns are drawn narrower, so the
+// limit bounds the width of the export rather than the number of columns.
+func exportColumnLimit(granularity string) int {
+	return cfg.ExportMaxColumns * svgColumnWidths[granularityQuarter] / svgColumnWidths[granularity]
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useTestStore points the global store and configuration at a scratch
+// JSON store with the default settings
+func useTestStore(t *testing.T) {
+	t.Helper()
+	defaults := defaultConfig()
+	cfg = &defaults
+	store = NewJSONFileStore(filepath.Join(t.TempDir(), "charts.json"))
+	if err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExportDayGranularityChart(t *testing.T) {
+	useTestStore(t)
+	router := apiRouter()
+
+	charts := []struct {
+		id, body string
+		want     int
+	}{
+		{"year", `{"id": "year", "title": "Year", "granularity": "day",
+			"startYear": 2025, "startQuarter": 1, "endYear": 2025, "endQuarter": 4,
+			"categories": [{"id": "eng", "name": "Engineering", "color": "#3498db", "tasks": [
+				{"id": "build", "title": "Build", "startDate": "2025-02-03", "endDate": "2025-11-28"}
+			]}]}`, http.StatusOK},
+		{"decade", `{"id": "decade", "title": "Decade", "granularity": "day",
+			"startYear": 2025, "startQuarter": 1, "endYear": 2034, "endQuarter": 4}`, http.StatusUnprocessableEntity},
+	}
+	for _, c := range charts {
+		req := httptest.NewRequest("POST", "/api/charts", strings.NewReader(c.body))
+		req.Header.Set(contentTypeHeader, jsonContentType)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("creating %s: got status %d: %s", c.id, rec.Code, rec.Body)
+		}
+
+		for _, format := range []string{"svg", "png", "pdf"} {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/charts/"+c.id+"/export/"+format, nil))
+			if rec.Code != c.want {
+				t.Errorf("exporting %s as %s: got status %d, want %d: %s", c.id, format, rec.Code, c.want, rec.Body)
+			}
+			if format == "png" && rec.Code == http.StatusOK {
+				checkPNGHeaderLabels(t, store.Get(c.id), rec.Body.Bytes())
+			}
+		}
+	}
+}
+
+// checkPNGHeaderLabels decodes a PNG export of chart and checks that every
+// group and column cell of the timeline header holds exactly its label
+func checkPNGHeaderLabels(t *testing.T, chart *Chart, data []byte) {
+	t.Helper()
+	decoded, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewRGBA(decoded.Bounds())
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			img.Set(x, y, decoded.At(x, y))
+		}
+	}
+
+	tl := buildTimeline(chart)
+	colW := svgColumnWidths[tl.granularity]
+	left, headerHeight := 20+200, 80
+	groups := tl.groups()
+	if groups != nil {
+		headerHeight += 24
+	}
+	for _, g := range groups {
+		cell := image.Rect(left+g.first*colW, headerHeight-30-24, left+(g.first+g.count)*colW, headerHeight-30)
+		checkCellLabel(t, img, cell, 16, g.label)
+	}
+	for i, col := range tl.columns {
+		cell := image.Rect(left+i*colW, headerHeight-30, left+(i+1)*colW, headerHeight)
+		checkCellLabel(t, img, cell, 20, col.label)
+	}
+}
+
+// checkCellLabel compares the text pixels inside cell with label drawn
+// centred on a blank cell with its baseline the given offset from the top
+func checkCellLabel(t *testing.T, img *image.RGBA, cell image.Rectangle, baseline int, label string) {
+	t.Helper()
+	text := color.RGBA{51, 51, 51, 255}
+	want := image.NewRGBA(image.Rect(0, 0, cell.Dx(), cell.Dy()))
+	drawText(want, (cell.Dx()-textWidth(label))/2, baseline, label, text)
+	for y := 0; y < cell.Dy(); y++ {
+		for x := 0; x < cell.Dx(); x++ {
+			drawn := img.RGBAAt(cell.Min.X+x, cell.Min.Y+y) == text
+			if drawn != (want.RGBAAt(x, y) == text) {
+				t.Errorf("header cell at %v does not read %q", cell.Min, label)
+				return
+			}
+		}
+	}
+}
+
+func TestExportFiscalPNGHeader(t *testing.T) {
+	chart := &Chart{ID: "fy", Title: "Fiscal", Granularity: "month",
+		StartYear: 2025, StartQ: 2, EndYear: 2025, EndQ: 4,
+		Fiscal: &FiscalCalendar{StartMonth: 10, Label: "FY{yy} Q{q}"}}
+	if groups := buildTimeline(chart).groups(); len(groups) == 0 || groups[0].label != "FY25 Q2" {
+		t.Fatalf("groups = %+v, want fiscal quarters starting with FY25 Q2", groups)
+	}
+	data, err := GeneratePNG(chart, ExportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPNGHeaderLabels(t, chart, data)
+}
+
+func TestCreateChartIgnoresBaselines(t *testing.T) {
+	useTestStore(t)
+	req := httptest.NewRequest("POST", "/api/charts", strings.NewReader(`{"id": "plan", "title": "Plan",
+		"startYear": 2025, "startQuarter": 1, "endYear": 2025, "endQuarter": 4,
+		"baselines": [{"id": "b1", "name": "Forged", "tasks": [{"taskId": "missing"}]}]}`))
+	req.Header.Set(contentTypeHeader, jsonContentType)
+	rec := httptest.NewRecorder()
+	apiRouter().ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("got status %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	if baselines := store.Get("plan").Baselines; len(baselines) != 0 {
+		t.Errorf("created chart has baselines %+v, want none", baselines)
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const milestoneNotFoundMsg = "Milestone not found"
+
+// validateMilestones checks that every milestone has a valid date within
+// the chart's range and belongs to no category or to one of the chart's
+// categories
+func validateMilestones(chart *Chart) error {
+	categories := make(map[string]bool, len(chart.Categories))
+	for _, cat := range chart.Categories {
+		categories[cat.ID] = true
+	}
+	first, last, rangeOK := chartSpan(chart)
+
+	var errs []error
+	for i, m := range chart.Milestones {
+		path := fmt.Sprintf("/milestones/%d", i)
+		if d, ok := parseDate(m.Date); !ok {
+			errs = append(errs, fieldErrorf(path+"/date", "milestone %q has invalid date %q (want YYYY-MM-DD)", m.Title, m.Date))
+		} else if rangeOK && (d.Before(first) || !d.Before(last)) {
+			errs = append(errs, fieldErrorf(path+"/date", "milestone %q on %s is outside the chart's range", m.Title, m.Date))
+		}
+		if m.CategoryID != "" && !categories[m.CategoryID] {
+			errs = append(errs, fieldErrorf(path+"/categoryId", "milestone %q is in unknown category %q", m.Title, m.CategoryID))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// milestoneRows splits the chart's milestones into those drawn on a row
+// of their own above the categories and those drawn on a row at the end
+// of their category
+func milestoneRows(chart *Chart) ([]Milestone, map[string][]Milestone) {
+	categories := make(map[string]bool, len(chart.Categories))
+	for _, cat := range chart.Categories {
+		categories[cat.ID] = true
+	}
+
+	var top []Milestone
+	byCategory := make(map[string][]Milestone)
+	for _, m := range chart.Milestones {
+		if categories[m.CategoryID] {
+			byCategory[m.CategoryID] = append(byCategory[m.CategoryID], m)
+		} else {
+			top = append(top, m)
+		}
+	}
+	return top, byCategory
+}
+
+// countMilestoneRows returns how many rows milestoneRows needs
+func countMilestoneRows(chart *Chart) int {
+	top, byCategory := milestoneRows(chart)
+	rows := len(byCategory)
+	if len(top) > 0 {
+		rows++
+	}
+	return rows
+}
+
+// milestonePosition returns the horizontal position of a milestone in
+// column units, centred on its day
+func (t *timeline) milestonePosition(m Milestone) (float64, bool) {
+	d, ok := parseDate(m.Date)
+	if !ok {
+		return -1, false
+	}
+	pos := t.position(d.Add(12 * time.Hour))
+	return pos, pos >= 0
+}
+
+// milestoneColor returns the colour a milestone is drawn in
+func milestoneColor(m Milestone, catColor string) string {
+	if m.Color != "" {
+		return m.Color
+	}
+	if catColor != "" {
+		return catColor
+	}
+	return "#34495e"
+}
+
+func findMilestone(chart *Chart, id string) int {
+	for i, m := range chart.Milestones {
+		if m.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func listMilestonesHandler(w http.ResponseWriter, r *http.Request) {
+	storeMux.RLock()
+	chart := store.Get(mux.Vars(r)["id"])
+	storeMux.RUnlock()
+
+	if chart == nil {
+		http.Error(w, chartNotFoundMsg, http.StatusNotFound)
+		return
+	}
+
+	milestones := chart.Milestones
+	if milestones == nil {
+		milestones = []Milestone{}
+	}
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	w.Header().Set(etagHeader, chartETag(chart))
+	json.NewEncoder(w).Encode(milestones)
+}
+
+func getMilestoneHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	storeMux.RLock()
+	chart := store.Get(vars["id"])
+	storeMux.RUnlock()
+
+	if chart == nil {
+		http.Error(w, chartNotFoundMsg, http.StatusNotFound)
+		return
+	}
+	i := findMilestone(chart, vars["milestoneId"])
+	if i < 0 {
+		http.Error(w, milestoneNotFoundMsg, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	w.Header().Set(etagHeader, chartETag(chart))
+	json.NewEncoder(w).Encode(chart.Milestones[i])
+}
+
+func createMilestoneHandler(w http.ResponseWriter, r *http.Request) {
+	var m Milestone
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	_, ok := modifyChart(w, r, fmt.Sprintf("Added milestone %q", m.Title), func(chart *Chart) error {
+		if m.ID == "" {
+			m.ID = uuid.New().String()
+		} else if findMilestone(chart, m.ID) >= 0 {
+			return &httpError{http.StatusConflict, fmt.Sprintf("milestone %q already exists", m.ID)}
+		}
+		chart.Milestones = append(chart.Milestones, m)
+		return nil
+	})
+	if !ok {
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(m)
+}
+
+func updateMilestoneHandler(w http.ResponseWriter, r *http.Request) {
+	var m Milestone
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	m.ID = mux.Vars(r)["milestoneId"]
+
+	_, ok := modifyChart(w, r, fmt.Sprintf("Updated milestone %q", m.Title), func(chart *Chart) error {
+		i := findMilestone(chart, m.ID)
+		if i < 0 {
+			return &httpError{http.StatusNotFound, milestoneNotFoundMsg}
+		}
+		chart.Milestones[i] = m
+		return nil
+	})
+	if !ok {
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	json.NewEncoder(w).Encode(m)
+}
+
+func deleteMilestoneHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["milestoneId"]
+
+	_, ok := modifyChart(w, r, "Deleted milestone "+id, func(chart *Chart) error {
+		i := findMilestone(chart, id)
+		if i < 0 {
+			return &httpError{http.StatusNotFound, milestoneNotFoundMsg}
+		}
+		chart.Milestones = append(chart.Milestones[:i], chart.Milestones[i+1:]...)
+		return nil
+	})
+	if !ok {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -2,7 +2,7 @@ package main
 
 import (
 	"encoding/json"
-	"os"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,132 +10,231 @@ import (
 
 // Chart represents a Gantt chart
 type Chart struct {
-	ID         string     `json:"id"`
-	Title      string     `json:"title"`
-	StartYear  int        `json:"startYear"`
-	StartQ     int        `json:"startQuarter"`
-	EndYear    int        `json:"endYear"`
-	EndQ       int        `json:"endQuarter"`
-	Categories []Category `json:"categories"`
-	CreatedAt  time.Time  `json:"createdAt"`
-	UpdatedAt  time.Time  `json:"updatedAt"`
+	ID          string          `json:"id"`
+	Title       string          `json:"title"`
+	StartYear   int             `json:"startYear"`
+	StartQ      int             `json:"startQuarter"`
+	EndYear     int             `json:"endYear"`
+	EndQ        int             `json:"endQuarter"`
+	Granularity string          `json:"granularity,omitempty"`
+	Fiscal      *FiscalCalendar `json:"fiscal,omitempty"` // if set, all years and quarters are fiscal
+	Owner       string          `json:"owner,omitempty"`  // person ID
+	Categories  []Category      `json:"categories"`
+	Milestones  []Milestone     `json:"milestones,omitempty"`
+	Baselines   []Baseline      `json:"baselines,omitempty"` // managed by the baseline endpoints
+	Fields      []FieldDef      `json:"fields,omitempty"`    // custom fields tasks may set
+	Version     int             `json:"version"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	UpdatedAt   time.Time       `json:"updatedAt"`
 }
 
 // Category represents a grouping of tasks
 type Category struct {
-	ID    string `json:"id"`
-	Name  string `json:"name"`
-	Color string `json:"color"`
-	Tasks []Task `json:"tasks"`
+	ID    string   `json:"id"`
+	Name  string   `json:"name"`
+	Color string   `json:"color"`
+	Owner string   `json:"owner,omitempty"` // person ID
+	Tags  []string `json:"tags,omitempty"`  // apply to every task in the category
+	Tasks []Task   `json:"tasks"`
 }
 
 // Task represents a single item in the Gantt chart
 type Task struct {
+	ID           string       `json:"id"`
+	Title        string       `json:"title"`
+	Description  string       `json:"description"`
+	StartYear    int          `json:"startYear"`
+	StartQ       int          `json:"startQuarter"`
+	EndYear      int          `json:"endYear"`
+	EndQ         int          `json:"endQuarter"`
+	StartDate    string       `json:"startDate,omitempty"`
+	EndDate      string       `json:"endDate,omitempty"`
+	Color        string       `json:"color,omitempty"`
+	Progress     int          `json:"progress,omitempty"` // percent complete, 0-100
+	Status       string       `json:"status,omitempty"`
+	Assignees    []string     `json:"assignees,omitempty"` // person IDs
+	Tags         []string     `json:"tags,omitempty"`      // apply to the task's subtasks too
+	Dependencies []Dependency `json:"dependencies,omitempty"`
+	// Subtasks nest to any depth; a task with subtasks spans them
+	Subtasks  []Task `json:"subtasks,omitempty"`
+	Collapsed bool   `json:"collapsed,omitempty"` // export only the summary bar
+	// Fields holds custom field values by key: numbers for number fields,
+	// strings otherwise
+	Fields map[string]interface{} `json:"fields,omitempty"`
+}
+
+// Dependency links a task to a predecessor task anywhere in the chart
+type Dependency struct {
+	TaskID string `json:"taskId"`
+	Type   string `json:"type,omitempty"` // FS (default), SS or FF
+}
+
+// Milestone marks a single point in time, optionally within a category
+type Milestone struct {
 	ID          string `json:"id"`
 	Title       string `json:"title"`
-	Description string `json:"description"`
-	StartYear   int    `json:"startYear"`
-	StartQ      int    `json:"startQuarter"`
-	EndYear     int    `json:"endYear"`
-	EndQ        int    `json:"endQuarter"`
+	Description string `json:"description,omitempty"`
+	Date        string `json:"date"` // YYYY-MM-DD
+	CategoryID  string `json:"categoryId,omitempty"`
 	Color       string `json:"color,omitempty"`
 }
 
-// ChartStore manages the collection of charts
-type ChartStore struct {
-	charts map[string]*Chart
+// FieldDef defines a custom field that the chart's tasks may set
+type FieldDef struct {
+	Key      string   `json:"key"` // used in task fields and query parameters
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`              // text, number, enum, date or url
+	Options  []string `json:"options,omitempty"` // enum values, in display order
+	Required bool     `json:"required,omitempty"`
 }
 
-// NewChartStore creates a new chart store
-func NewChartStore() *ChartStore {
-	return &ChartStore{
-		charts: make(map[string]*Chart),
-	}
+// FiscalCalendar defines a fiscal year starting in a month other than
+// January, and how its quarters are labelled
+type FiscalCalendar struct {
+	StartMonth int    `json:"startMonth"`        // 1-12
+	Label      string `json:"label,omitempty"`   // e.g. "FY{yy} Q{q}", also {yyyy}
+	NamedBy    string `json:"namedBy,omitempty"` // calendar year naming the fiscal year: end (default) or start
 }
 
-// Add adds a new chart to the store
-func (s *ChartStore) Add(chart *Chart) {
-	if chart.ID == "" {
-		chart.ID = uuid.New().String()
-	}
-	now := time.Now()
-	chart.CreatedAt = now
-	chart.UpdatedAt = now
+// Baseline is a named snapshot of every task's dates, kept to compare the
+// current plan against
+type Baseline struct {
+	ID        string         `json:"id"`
+	Name      string         `json:"name"`
+	CreatedAt time.Time      `json:"createdAt"`
+	Tasks     []BaselineTask `json:"tasks,omitempty"` // omitted from listings
+}
 
-	// Ensure all categories and tasks have IDs
-	for i := range chart.Categories {
-		if chart.Categories[i].ID == "" {
-			chart.Categories[i].ID = uuid.New().String()
-		}
-		for j := range chart.Categories[i].Tasks {
-			if chart.Categories[i].Tasks[j].ID == "" {
-				chart.Categories[i].Tasks[j].ID = uuid.New().String()
-			}
-		}
-	}
+// BaselineTask holds a task's dates as they were when a baseline was
+// frozen, in the same form as on Task
+type BaselineTask struct {
+	TaskID    string `json:"taskId"`
+	StartYear int    `json:"startYear"`
+	StartQ    int    `json:"startQuarter"`
+	EndYear   int    `json:"endYear"`
+	EndQ      int    `json:"endQuarter"`
+	StartDate string `json:"startDate,omitempty"`
+	EndDate   string `json:"endDate,omitempty"`
+}
 
-	s.charts[chart.ID] = chart
+// Person is an entry in the server's people directory, referenced by ID
+// from task assignees and chart and category owners
+type Person struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Email    string `json:"email,omitempty"`
+	Team     string `json:"team,omitempty"`
+	Initials string `json:"initials"` // derived from Name if not given
 }
 
-// Get retrieves a chart by ID
-func (s *ChartStore) Get(id string) *Chart {
-	return s.charts[id]
+// ChartStore manages the collection of charts. Add, Update and Delete
+// stage changes in memory; Save persists everything staged since the
+// last successful Save as a single unit, and Rollback discards it.
+type ChartStore interface {
+	// Load populates the store from its backing storage
+	Load() error
+	// Get retrieves a chart by ID, or nil if it does not exist
+	Get(id string) *Chart
+	// GetAll returns all charts
+	GetAll() []*Chart
+	// Add adds a new chart, assigning IDs, timestamps and version 1
+	Add(chart *Chart)
+	// Update replaces an existing chart, preserving its creation time and
+	// incrementing its version
+	Update(chart *Chart)
+	// Delete removes a chart and its revisions
+	Delete(id string)
+	// AddRevision records a snapshot of chart as its next revision
+	AddRevision(chart *Chart, author, message string) *Revision
+	// Revisions lists the revisions of a chart, oldest first
+	Revisions(chartID string) []*Revision
+	// Revision returns a single revision of a chart, or nil
+	Revision(chartID string, number int) *Revision
+	// People lists the people directory, sorted by name
+	People() []*Person
+	// Person retrieves a person by ID, or nil if they do not exist
+	Person(id string) *Person
+	// PutPerson adds or replaces a person, assigning an ID and initials
+	// if missing
+	PutPerson(person *Person)
+	// DeletePerson removes a person from the directory
+	DeletePerson(id string)
+	// Save persists all staged changes
+	Save() error
+	// Rollback discards changes staged since the last successful Save
+	Rollback()
 }
 
-// GetAll returns all charts
-func (s *ChartStore) GetAll() []*Chart {
-	charts := make([]*Chart, 0, len(s.charts))
-	for _, chart := range s.charts {
-		charts = append(charts, chart)
+// cloneChartMap returns a shallow copy of a chart map. Stores replace
+// charts wholesale rather than mutating them, so sharing pointers
+// between the copies is safe.
+func cloneChartMap(charts map[string]*Chart) map[string]*Chart {
+	clone := make(map[string]*Chart, len(charts))
+	for id, chart := range charts {
+		clone[id] = chart
 	}
-	return charts
+	return clone
 }
 
-// Update updates an existing chart
-func (s *ChartStore) Update(chart *Chart) {
-	if existing := s.charts[chart.ID]; existing != nil {
-		chart.CreatedAt = existing.CreatedAt
+func clonePersonMap(people map[string]*Person) map[string]*Person {
+	clone := make(map[string]*Person, len(people))
+	for id, person := range people {
+		clone[id] = person
 	}
-	chart.UpdatedAt = time.Now()
+	return clone
+}
 
-	// Ensure all categories and tasks have IDs
+// normalizeChart ensures the chart and all of its categories, tasks and
+// milestones have IDs, that task quarters agree with any task dates and
+// that parent tasks span their subtasks
+func normalizeChart(chart *Chart) {
+	if chart.ID == "" {
+		chart.ID = uuid.New().String()
+	}
+	cal := chartCalendar(chart)
 	for i := range chart.Categories {
 		if chart.Categories[i].ID == "" {
 			chart.Categories[i].ID = uuid.New().String()
 		}
-		for j := range chart.Categories[i].Tasks {
-			if chart.Categories[i].Tasks[j].ID == "" {
-				chart.Categories[i].Tasks[j].ID = uuid.New().String()
+		walkTasks(chart.Categories[i].Tasks, func(task *Task, _ int) {
+			if task.ID == "" {
+				task.ID = uuid.New().String()
 			}
+			syncTaskQuarters(task, cal)
+		})
+		rollupSubtasks(chart.Categories[i].Tasks, cal)
+	}
+	for i := range chart.Milestones {
+		if chart.Milestones[i].ID == "" {
+			chart.Milestones[i].ID = uuid.New().String()
+		}
+	}
+	for i := range chart.Baselines {
+		if chart.Baselines[i].ID == "" {
+			chart.Baselines[i].ID = uuid.New().String()
 		}
 	}
-
-	s.charts[chart.ID] = chart
 }
 
-// Delete removes a chart
-func (s *ChartStore) Delete(id string) {
-	delete(s.charts, id)
+// validateChart checks a chart's field values and the references between
+// its items. Failures are fieldErrors, so they can be reported by path.
+func validateChart(chart *Chart) error {
+	return errors.Join(
+		validateRange(chart), validateTaskDates(chart), validateColors(chart), validateIDs(chart),
+		validateSubtasks(chart), validateDependencies(chart), validateMilestones(chart), validateProgress(chart),
+		validateStatuses(chart), validateBaselines(chart), validateCustomFields(chart), validateTags(chart), validateFiscal(chart),
+	)
 }
 
-// Save persists the charts to a file
-func (s *ChartStore) Save(filename string) error {
-	data, err := json.MarshalIndent(s.charts, "", "  ")
+// cloneChart returns a deep copy of a chart
+func cloneChart(chart *Chart) *Chart {
+	data, err := json.Marshal(chart)
 	if err != nil {
-		return err
+		panic(err) // Chart always marshals
 	}
-	return os.WriteFile(filename, data, 0644)
-}
-
-// Load reads charts from a file
-func (s *ChartStore) Load(filename string) error {
-	data, err := os.ReadFile(filename)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil // File doesn't exist yet, not an error
-		}
-		return err
+	var clone Chart
+	if err := json.Unmarshal(data, &clone); err != nil {
+		panic(err)
 	}
-
-	return json.Unmarshal(data, &s.charts)
+	return &clone
 }
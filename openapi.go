@@ -0,0 +1,649 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const openAPIDescription = `Plans quarterly Gantt charts. Component schemas are generated from the ` +
+	`server's Go types: required lists the members the server always sends, ` +
+	`while requests may leave any member out to take its zero value. Errors ` +
+	`are plain text, except validation failures, which are RFC 7807 problems.`
+
+// schemaOverrides adds the constraints that the Go types cannot express to
+// the generated component schemas, keyed by component and member name, or
+// by component name alone for the component itself. A nil value removes
+// the keyword.
+var schemaOverrides = map[string]map[string]interface{}{
+	// Request bodies need only these members
+	"PatchOp":         {"required": []string{"op", "path"}},
+	"TaskMove":        {"required": nil},
+	"BaselineRequest": {"required": []string{"name"}},
+
+	"Chart.startYear":            {"minimum": minYear, "maximum": maxYear},
+	"Chart.endYear":              {"minimum": minYear, "maximum": maxYear},
+	"Chart.startQuarter":         {"minimum": 1, "maximum": 4},
+	"Chart.endQuarter":           {"minimum": 1, "maximum": 4},
+	"Chart.granularity":          {"enum": []string{granularityQuarter, granularityMonth, granularityWeek, granularityDay}},
+	"ChartSummary.granularity":   {"enum": []string{granularityQuarter, granularityMonth, granularityWeek, granularityDay}},
+	"Category.color":             {"pattern": `^(#[0-9A-Fa-f]{6})?$`},
+	"Task.startQuarter":          {"minimum": 1, "maximum": 4},
+	"Task.endQuarter":            {"minimum": 1, "maximum": 4},
+	"Task.startDate":             {"format": "date"},
+	"Task.endDate":               {"format": "date"},
+	"Task.color":                 {"pattern": hexColorPattern.String()},
+	"Task.progress":              {"minimum": 0, "maximum": 100},
+	"Task.status":                {"enum": []string{statusPlanned, statusInProgress, statusAtRisk, statusBlocked, statusDone, statusCancelled}},
+	"Task.fields":                {"additionalProperties": map[string]interface{}{"type": []string{"string", "number"}}},
+	"Dependency.type":            {"enum": []string{depFinishToStart, depStartToStart, depFinishToFinish}},
+	"Milestone.date":             {"format": "date"},
+	"Milestone.color":            {"pattern": hexColorPattern.String()},
+	"FieldDef.type":              {"enum": []string{fieldText, fieldNumber, fieldEnum, fieldDate, fieldURL}},
+	"FiscalCalendar.startMonth":  {"minimum": 1, "maximum": 12},
+	"FiscalCalendar.namedBy":     {"enum": []string{fiscalNamedByEnd, fiscalNamedByStart}},
+	"BaselineTask.startDate":     {"format": "date"},
+	"BaselineTask.endDate":       {"format": "date"},
+	"RevisionChange.op":          {"enum": []string{"added", "removed", "changed", "reordered"}},
+	"PatchOp.op":                 {"enum": []string{"add", "remove", "replace", "move", "copy", "test"}},
+	"Problem.status":             {"minimum": 400, "maximum": 599},
+	"TaskSchedule.durationDays":  {"minimum": 0},
+	"TaskSchedule.slackDays":     {"minimum": 0},
+	"ChartSummary.categoryCount": {"minimum": 0},
+	"ChartSummary.taskCount":     {"minimum": 0},
+	"WorkloadReport.capacity":    {"minimum": 1},
+	"WorkloadRow.capacity":       {"minimum": 1},
+	"WorkloadCell.load":          {"minimum": 0},
+	"Revision.number":            {"minimum": 1},
+	"RevisionDiff.from":          {"minimum": 1},
+	"RevisionDiff.to":            {"minimum": 1},
+	"TaskMove.position":          {"minimum": 0},
+	"BaselineRequest.name":       {"minLength": 1},
+	"Person.name":                {"minLength": 1},
+	"Person.email":               {"format": "email"},
+	"FiscalCalendar.label":       {"description": "Quarter label with {yyyy}, {yy} and {q} placeholders"},
+	"Task.collapsed":             {"description": "Export only the summary bar"},
+	"Task.subtasks":              {"description": "Subtasks nest to any depth; a task with subtasks spans them"},
+	"Revision.chart":             {"description": "The chart as of the revision; left out of listings"},
+	"Baseline.tasks":             {"description": "The frozen task dates; left out of listings"},
+}
+
+// schemaGenerator builds JSON Schemas for Go types, registering every
+// struct it meets as a component
+type schemaGenerator struct {
+	components map[string]interface{}
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+)
+
+// componentName names the component of a struct type after the type, so
+// that unexported types such as problem become Problem
+func componentName(t reflect.Type) string {
+	return strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+}
+
+// schema returns the schema of t's JSON encoding
+func (g *schemaGenerator) schema(t reflect.Type) map[string]interface{} {
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case rawMessageType:
+		return map[string]interface{}{}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schema(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		name := componentName(t)
+		if _, ok := g.components[name]; !ok {
+			// Reserve the name first, since tasks contain tasks
+			g.components[name] = nil
+			g.components[name] = g.structSchema(t, name)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+// structSchema describes a struct as encoding/json writes it. Members
+// without omitempty are always sent and so required; slices, maps and
+// pointers among them may be null.
+func (g *schemaGenerator) structSchema(t reflect.Type, name string) map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+		member, options, _ := strings.Cut(tag, ",")
+		if member == "" {
+			member = field.Name
+		}
+
+		s := g.schema(field.Type)
+		for k, v := range schemaOverrides[name+"."+member] {
+			s[k] = v
+		}
+		omitEmpty := strings.Contains(options, "omitempty")
+		if !omitEmpty {
+			required = append(required, member)
+			switch field.Type.Kind() {
+			case reflect.Slice, reflect.Map, reflect.Pointer:
+				if field.Type != rawMessageType {
+					s = nullable(s)
+				}
+			}
+		}
+		properties[member] = s
+	}
+
+	s := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	for k, v := range schemaOverrides[name] {
+		if v == nil {
+			delete(s, k)
+		} else {
+			s[k] = v
+		}
+	}
+	return s
+}
+
+// nullable extends a schema to allow null
+func nullable(s map[string]interface{}) map[string]interface{} {
+	if typ, ok := s["type"].(string); ok {
+		s["type"] = []string{typ, "null"}
+		return s
+	}
+	return map[string]interface{}{"anyOf": []interface{}{s, map[string]interface{}{"type": "null"}}}
+}
+
+// valueSchema returns the schema for a response or request body given as
+// a Go value of the body's type, a literal schema, or nil for plain text
+func (g *schemaGenerator) valueSchema(v interface{}) map[string]interface{} {
+	switch v := v.(type) {
+	case nil:
+		return map[string]interface{}{"type": "string"}
+	case map[string]interface{}:
+		return v
+	}
+	return g.schema(reflect.TypeOf(v))
+}
+
+// apiParam is a query or header parameter of an operation
+type apiParam struct {
+	name, in    string
+	description string
+	schema      map[string]interface{}
+	required    bool
+}
+
+// apiContent is a body in one media type. schema is as for valueSchema.
+type apiContent struct {
+	mediaType string
+	schema    interface{}
+}
+
+// apiResponse is one documented response of an operation. Responses with
+// the same status are merged, so that one status may have several media
+// types.
+type apiResponse struct {
+	status  int
+	content []apiContent
+	headers []string // keys of apiHeaders
+}
+
+// apiOperation documents a route registered by apiRouter
+type apiOperation struct {
+	method, path string // path below /api, with {name} placeholders
+	id, summary  string
+	description  string
+	params       []apiParam
+	body         []apiContent
+	responses    []apiResponse
+}
+
+// Response headers
+var apiHeaders = map[string]struct {
+	description string
+	required    bool
+}{
+	etagHeader:         {"The chart's version, for If-Match", true},
+	totalCountHeader:   {"The number of charts matching the search", true},
+	"Link":             {`The next page, with rel="next", if there is one`, false},
+	acceptPatchHeader:  {"The accepted PATCH media types", true},
+	contentDisposition: {"Names the exported file", true},
+}
+
+var (
+	ifMatchParam = apiParam{ifMatchHeader, "header", "Only apply the change if the chart's ETag matches, or * for any version", nil, false}
+	authorParam  = apiParam{authorHeader, "header", "Who the new revision is attributed to", nil, false}
+
+	revisionParams = []apiParam{
+		{"from", "query", "The older revision number", map[string]interface{}{"type": "integer", "minimum": 1}, true},
+		{"to", "query", "The newer revision number", map[string]interface{}{"type": "integer", "minimum": 1}, true},
+	}
+	listParams = []apiParam{
+		{"q", "query", "Search words, all of which must appear in the chart's title or in a category, task or milestone", nil, false},
+		{"sort", "query", "Sort key, prefixed with - to sort descending", map[string]interface{}{"type": "string", "enum": []string{
+			sortTitle, "-" + sortTitle, sortCreatedAt, "-" + sortCreatedAt, sortUpdatedAt, "-" + sortUpdatedAt}}, false},
+		{"fields", "query", "summary lists ChartSummary objects instead of whole charts", map[string]interface{}{"type": "string", "enum": []string{"full", "summary"}}, false},
+		{"limit", "query", "Page size; all matching charts are listed without it", map[string]interface{}{"type": "integer", "minimum": 1, "maximum": maxChartPageSize}, false},
+		{"cursor", "query", "Opaque cursor from the Link header of the previous page", nil, false},
+	}
+	exportParams = []apiParam{
+		{"status", "query", "Comma-separated statuses to include, or " + filterNone + " for tasks without one", nil, false},
+		{"tags", "query", "Comma-separated tags, any of which a task must carry", nil, false},
+		{"excludeTags", "query", "Comma-separated tags that leave a task out", nil, false},
+		{"categories", "query", "Comma-separated category IDs or names to include", nil, false},
+		{"assignee", "query", "Comma-separated person IDs, or " + filterNone + " for unassigned tasks", nil, false},
+		{"from", "query", "Start of the window tasks must overlap: a date, month or quarter", nil, false},
+		{"to", "query", "End of the window tasks must overlap: a date, month or quarter", nil, false},
+		{"groupBy", "query", "Group tasks by a custom field, given as " + fieldParamPrefix + "<key>", nil, false},
+		{"highlight", "query", "Highlight the critical path", map[string]interface{}{"type": "string", "enum": []string{"critical"}}, false},
+		{"labels", "query", "Comma-separated bar labels: progress, assignees or " + fieldParamPrefix + "<key>", nil, false},
+		{"baseline", "query", "Baseline ID or name to draw the frozen dates of", nil, false},
+	}
+	workloadParams = []apiParam{
+		{"capacity", "query", "Parallel tasks per person per quarter; defaults to the server's setting", map[string]interface{}{"type": "integer", "minimum": 1}, false},
+		{"from", "query", "First quarter, e.g. 2025-Q3", map[string]interface{}{"type": "string", "pattern": `^[0-9]+-Q[1-4]$`}, false},
+		{"to", "query", "Last quarter, e.g. 2026-Q2", map[string]interface{}{"type": "string", "pattern": `^[0-9]+-Q[1-4]$`}, false},
+	}
+)
+
+// Path parameters, by name
+var apiPathParams = map[string]apiParam{
+	"id":          {"id", "path", "Chart ID", nil, true},
+	"rev":         {"rev", "path", "Revision number", map[string]interface{}{"type": "integer", "minimum": 1}, true},
+	"milestoneId": {"milestoneId", "path", "Milestone ID", nil, true},
+	"catId":       {"catId", "path", "Category ID", nil, true},
+	"taskId":      {"taskId", "path", "Task ID, which may be a subtask's", nil, true},
+	"baselineId":  {"baselineId", "path", "Baseline ID or name", nil, true},
+	"personId":    {"personId", "path", "Person ID", nil, true},
+}
+
+func jsonBody(v interface{}) []apiContent {
+	return []apiContent{{jsonContentType, v}}
+}
+
+func reply(status int, v interface{}, headers ...string) apiResponse {
+	return apiResponse{status, jsonBody(v), headers}
+}
+
+func textReply(status int) apiResponse {
+	return apiResponse{status: status, content: []apiContent{{"text/plain", nil}}}
+}
+
+func fileReply(mediaType string, schema interface{}) apiResponse {
+	return apiResponse{http.StatusOK, []apiContent{{mediaType, schema}}, []string{contentDisposition}}
+}
+
+var (
+	noContent     = apiResponse{status: http.StatusNoContent}
+	badRequest    = textReply(http.StatusBadRequest)
+	notFound      = textReply(http.StatusNotFound)
+	conflict      = textReply(http.StatusConflict)
+	unprocessed   = textReply(http.StatusUnprocessableEntity)
+	serverError   = textReply(http.StatusInternalServerError)
+	invalidFields = apiResponse{status: http.StatusUnprocessableEntity, content: []apiContent{{problemContentType, problem{}}}}
+	// The current chart is returned so the client can merge and retry,
+	// unless it has been deleted
+	preconditionFailedReply = apiResponse{status: http.StatusPreconditionFailed, content: []apiContent{{jsonContentType, Chart{}}, {"text/plain", nil}}}
+
+	binarySchema = map[string]interface{}{"type": "string", "format": "binary"}
+)
+
+// modifyReplies are the failures of every change made through modifyChart
+// patchBody and unsupportedPatch describe the PATCH media types, which
+// every PATCH operation shares
+var (
+	patchBody = []apiContent{
+		{mergePatchContentType, map[string]interface{}{"type": "object"}},
+		{jsonPatchContentType, []patchOp{}},
+	}
+	unsupportedPatch = apiResponse{http.StatusUnsupportedMediaType, []apiContent{{"text/plain", nil}}, []string{acceptPatchHeader}}
+)
+
+var modifyReplies = []apiResponse{notFound, preconditionFailedReply, invalidFields, serverError}
+
+func responses(list ...interface{}) []apiResponse {
+	var all []apiResponse
+	for _, item := range list {
+		switch item := item.(type) {
+		case apiResponse:
+			all = append(all, item)
+		case []apiResponse:
+			all = append(all, item...)
+		}
+	}
+	return all
+}
+
+func params(list ...interface{}) []apiParam {
+	var all []apiParam
+	for _, item := range list {
+		switch item := item.(type) {
+		case apiParam:
+			all = append(all, item)
+		case []apiParam:
+			all = append(all, item...)
+		}
+	}
+	return all
+}
+
+// apiOperations documents every route apiRouter registers
+var apiOperations = []apiOperation{
+	{method: "GET", path: "/openapi.json", id: "getOpenAPI", summary: "Get this OpenAPI document",
+		responses: responses(reply(http.StatusOK, map[string]interface{}{"type": "object"}))},
+
+	{method: "GET", path: "/charts", id: "listCharts", summary: "List charts",
+		description: "Charts are sorted by title unless sort says otherwise. With limit, a Link header points to the next page.",
+		params:      listParams,
+		responses: responses(reply(http.StatusOK, map[string]interface{}{"type": "array", "items": map[string]interface{}{"anyOf": []interface{}{
+			map[string]interface{}{"$ref": "#/components/schemas/Chart"},
+			map[string]interface{}{"$ref": "#/components/schemas/ChartSummary"},
+		}}}, totalCountHeader, "Link"), badRequest)},
+	{method: "POST", path: "/charts", id: "createChart", summary: "Create a chart",
+		description: "Missing chart, category, task and milestone IDs are assigned. Any baselines are ignored; they are made by the baseline endpoints. An existing chart cannot be replaced this way; use PUT.",
+		body:        jsonBody(Chart{}),
+		responses:   responses(reply(http.StatusCreated, Chart{}, etagHeader), badRequest, conflict, invalidFields, serverError)},
+	{method: "GET", path: "/charts/{id}", id: "getChart", summary: "Get a chart",
+		responses: responses(reply(http.StatusOK, Chart{}, etagHeader), notFound)},
+	{method: "PUT", path: "/charts/{id}", id: "updateChart", summary: "Replace a chart",
+		description: "The chart's baselines are kept; they are managed by the baseline endpoints.",
+		params:      params(ifMatchParam, authorParam),
+		body:        jsonBody(Chart{}),
+		responses:   responses(reply(http.StatusOK, Chart{}, etagHeader), badRequest, preconditionFailedReply, invalidFields, serverError)},
+	{method: "PATCH", path: "/charts/{id}", id: "patchChart", summary: "Patch a chart",
+		description: "Applies an RFC 7396 merge patch or an RFC 6902 JSON Patch. The chart's ID and baselines cannot be changed.",
+		params:      params(ifMatchParam, authorParam),
+		body:        patchBody,
+		responses:   responses(reply(http.StatusOK, Chart{}, etagHeader), badRequest, conflict, unsupportedPatch, modifyReplies)},
+	{method: "DELETE", path: "/charts/{id}", id: "deleteChart", summary: "Delete a chart and its revisions",
+		params:    params(ifMatchParam),
+		responses: responses(noContent, preconditionFailedReply, serverError)},
+
+	{method: "GET", path: "/charts/{id}/revisions", id: "listRevisions", summary: "List a chart's revisions, oldest first",
+		responses: responses(reply(http.StatusOK, []Revision{}), notFound)},
+	{method: "GET", path: "/charts/{id}/revisions/diff", id: "diffRevisions", summary: "Compare two revisions of a chart",
+		params:    revisionParams,
+		responses: responses(reply(http.StatusOK, RevisionDiff{}), badRequest, notFound)},
+	{method: "GET", path: "/charts/{id}/revisions/{rev}", id: "getRevision", summary: "Get a revision of a chart",
+		responses: responses(reply(http.StatusOK, Revision{}), badRequest, notFound)},
+	{method: "POST", path: "/charts/{id}/revisions/{rev}/restore", id: "restoreRevision", summary: "Restore a chart to a revision",
+		description: "Saves the old chart as a new revision, validated like any other edit. The chart's baselines are kept.",
+		params:      params(ifMatchParam, authorParam),
+		responses:   responses(reply(http.StatusOK, Chart{}, etagHeader), badRequest, modifyReplies)},
+
+	{method: "GET", path: "/charts/{id}/milestones", id: "listMilestones", summary: "List a chart's milestones",
+		responses: responses(reply(http.StatusOK, []Milestone{}, etagHeader), notFound)},
+	{method: "POST", path: "/charts/{id}/milestones", id: "createMilestone", summary: "Add a milestone",
+		params:    params(ifMatchParam, authorParam),
+		body:      jsonBody(Milestone{}),
+		responses: responses(reply(http.StatusCreated, Milestone{}, etagHeader), badRequest, conflict, modifyReplies)},
+	{method: "GET", path: "/charts/{id}/milestones/{milestoneId}", id: "getMilestone", summary: "Get a milestone",
+		responses: responses(reply(http.StatusOK, Milestone{}, etagHeader), notFound)},
+	{method: "PUT", path: "/charts/{id}/milestones/{milestoneId}", id: "updateMilestone", summary: "Replace a milestone",
+		params:    params(ifMatchParam, authorParam),
+		body:      jsonBody(Milestone{}),
+		responses: responses(reply(http.StatusOK, Milestone{}, etagHeader), badRequest, modifyReplies)},
+	{method: "DELETE", path: "/charts/{id}/milestones/{milestoneId}", id: "deleteMilestone", summary: "Delete a milestone",
+		params:    params(ifMatchParam, authorParam),
+		responses: responses(noContent, modifyReplies)},
+
+	{method: "GET", path: "/charts/{id}/categories", id: "listCategories", summary: "List a chart's categories with their tasks",
+		responses: responses(reply(http.StatusOK, []Category{}, etagHeader), notFound)},
+	{method: "POST", path: "/charts/{id}/categories", id: "createCategory", summary: "Add a category",
+		params:    params(ifMatchParam, authorParam),
+		body:      jsonBody(Category{}),
+		responses: responses(reply(http.StatusCreated, Category{}, etagHeader), badRequest, conflict, modifyReplies)},
+	{method: "GET", path: "/charts/{id}/categories/{catId}", id: "getCategory", summary: "Get a category with its tasks",
+		responses: responses(reply(http.StatusOK, Category{}, etagHeader), notFound)},
+	{method: "PATCH", path: "/charts/{id}/categories/{catId}", id: "patchCategory", summary: "Patch a category",
+		description: "Accepts the same patch types as a chart. Members of a merge patch replace the category's, including its whole task list if given.",
+		params:      params(ifMatchParam, authorParam),
+		body:        patchBody,
+		responses:   responses(reply(http.StatusOK, Category{}, etagHeader), badRequest, conflict, unsupportedPatch, modifyReplies)},
+	{method: "DELETE", path: "/charts/{id}/categories/{catId}", id: "deleteCategory", summary: "Delete a category and its tasks",
+		description: "Dependencies on the tasks are dropped and the category's milestones become chart-wide.",
+		params:      params(ifMatchParam, authorParam),
+		responses:   responses(noContent, modifyReplies)},
+
+	{method: "GET", path: "/charts/{id}/categories/{catId}/tasks", id: "listTasks", summary: "List a category's top-level tasks with their subtasks",
+		responses: responses(reply(http.StatusOK, []Task{}, etagHeader), notFound)},
+	{method: "POST", path: "/charts/{id}/categories/{catId}/tasks", id: "createTask", summary: "Add a task",
+		params:    params(apiParam{"parent", "query", "ID of the task to add a subtask to", nil, false}, ifMatchParam, authorParam),
+		body:      jsonBody(Task{}),
+		responses: responses(reply(http.StatusCreated, Task{}, etagHeader), badRequest, conflict, modifyReplies)},
+	{method: "GET", path: "/charts/{id}/categories/{catId}/tasks/{taskId}", id: "getTask", summary: "Get a task with its subtasks",
+		responses: responses(reply(http.StatusOK, Task{}, etagHeader), notFound)},
+	{method: "PATCH", path: "/charts/{id}/categories/{catId}/tasks/{taskId}", id: "patchTask", summary: "Patch a task",
+		description: "Accepts the same patch types as a chart. Members of a merge patch replace the task's, including its whole subtask list if given.",
+		params:      params(ifMatchParam, authorParam),
+		body:        patchBody,
+		responses:   responses(reply(http.StatusOK, Task{}, etagHeader), badRequest, conflict, unsupportedPatch, modifyReplies)},
+	{method: "DELETE", path: "/charts/{id}/categories/{catId}/tasks/{taskId}", id: "deleteTask", summary: "Delete a task and its subtasks",
+		description: "Dependencies on the deleted tasks are dropped.",
+		params:      params(ifMatchParam, authorParam),
+		responses:   responses(noContent, modifyReplies)},
+	{method: "POST", path: "/charts/{id}/categories/{catId}/tasks/{taskId}/move", id: "moveTask", summary: "Move a task with its subtasks",
+		params:    params(ifMatchParam, authorParam),
+		body:      jsonBody(taskMove{}),
+		responses: responses(reply(http.StatusOK, Task{}, etagHeader), badRequest, modifyReplies)},
+
+	{method: "GET", path: "/charts/{id}/schedule", id: "getSchedule", summary: "Compute the chart's critical path schedule",
+		responses: responses(reply(http.StatusOK, Schedule{}, etagHeader), notFound, unprocessed)},
+
+	{method: "GET", path: "/charts/{id}/baselines", id: "listBaselines", summary: "List a chart's baselines without their tasks",
+		responses: responses(reply(http.StatusOK, []Baseline{}, etagHeader), notFound)},
+	{method: "POST", path: "/charts/{id}/baselines", id: "createBaseline", summary: "Freeze the chart's task dates as a baseline",
+		params:    params(ifMatchParam, authorParam),
+		body:      jsonBody(baselineRequest{}),
+		responses: responses(reply(http.StatusCreated, Baseline{}, etagHeader), badRequest, conflict, unprocessed, modifyReplies)},
+	{method: "GET", path: "/charts/{id}/baselines/{baselineId}", id: "getBaseline", summary: "Get a baseline",
+		responses: responses(reply(http.StatusOK, Baseline{}, etagHeader), notFound)},
+	{method: "DELETE", path: "/charts/{id}/baselines/{baselineId}", id: "deleteBaseline", summary: "Delete a baseline",
+		params:    params(ifMatchParam, authorParam),
+		responses: responses(noContent, modifyReplies)},
+	{method: "GET", path: "/charts/{id}/baselines/{baselineId}/slip", id: "getBaselineSlip", summary: "Report each task's slip against a baseline",
+		responses: responses(reply(http.StatusOK, BaselineSlip{}, etagHeader), notFound)},
+
+	{method: "GET", path: "/people", id: "listPeople", summary: "List the people directory, sorted by name",
+		responses: responses(reply(http.StatusOK, []Person{}))},
+	{method: "POST", path: "/people", id: "createPerson", summary: "Add a person",
+		body:      jsonBody(Person{}),
+		responses: responses(reply(http.StatusCreated, Person{}), badRequest, conflict, invalidFields, serverError)},
+	{method: "GET", path: "/people/{personId}", id: "getPerson", summary: "Get a person",
+		responses: responses(reply(http.StatusOK, Person{}), notFound)},
+	{method: "PUT", path: "/people/{personId}", id: "updatePerson", summary: "Replace a person",
+		body:      jsonBody(Person{}),
+		responses: responses(reply(http.StatusOK, Person{}), badRequest, notFound, invalidFields, serverError)},
+	{method: "DELETE", path: "/people/{personId}", id: "deletePerson", summary: "Delete a person who is no longer assigned to or owning anything",
+		responses: responses(noContent, notFound, conflict, serverError)},
+	{method: "GET", path: "/people/{personId}/tasks", id: "listPersonTasks", summary: "List the tasks assigned to a person across every chart",
+		responses: responses(reply(http.StatusOK, []PersonTask{}), notFound)},
+
+	{method: "GET", path: "/workload", id: "getWorkload", summary: "Report each person's and team's load per quarter",
+		params:    workloadParams,
+		responses: responses(reply(http.StatusOK, WorkloadReport{}), badRequest)},
+	{method: "GET", path: "/workload/svg", id: "exportWorkloadSVG", summary: "Export the workload report as SVG",
+		params:    workloadParams,
+		responses: responses(fileReply("image/svg+xml", nil), badRequest, unprocessed)},
+	{method: "GET", path: "/workload/png", id: "exportWorkloadPNG", summary: "Export the workload report as PNG",
+		params:    workloadParams,
+		responses: responses(fileReply("image/png", binarySchema), badRequest, unprocessed, serverError)},
+
+	{method: "GET", path: "/charts/{id}/export/svg", id: "exportSVG", summary: "Export a chart as SVG",
+		description: "Custom field filters are given as " + fieldParamPrefix + "<key>=<values>.",
+		params:      exportParams,
+		responses:   responses(fileReply("image/svg+xml", nil), badRequest, notFound, unprocessed, serverError)},
+	{method: "GET", path: "/charts/{id}/export/png", id: "exportPNG", summary: "Export a chart as PNG",
+		description: "Custom field filters are given as " + fieldParamPrefix + "<key>=<values>.",
+		params:      exportParams,
+		responses:   responses(fileReply("image/png", binarySchema), badRequest, notFound, unprocessed, serverError)},
+	{method: "GET", path: "/charts/{id}/export/pdf", id: "exportPDF", summary: "Export a chart as PDF",
+		description: "Custom field filters are given as " + fieldParamPrefix + "<key>=<values>.",
+		params:      exportParams,
+		responses:   responses(fileReply("application/pdf", binarySchema), badRequest, notFound, unprocessed, serverError)},
+}
+
+var pathParamPattern = regexp.MustCompile(`\{(\w+)\}`)
+
+// parameter describes p as an OpenAPI parameter object
+func (p apiParam) parameter() map[string]interface{} {
+	schema := p.schema
+	if schema == nil {
+		schema = map[string]interface{}{"type": "string"}
+	}
+	param := map[string]interface{}{
+		"name":        p.name,
+		"in":          p.in,
+		"description": p.description,
+		"schema":      schema,
+	}
+	if p.required {
+		param["required"] = true
+	}
+	return param
+}
+
+func (g *schemaGenerator) content(list []apiContent) map[string]interface{} {
+	content := make(map[string]interface{}, len(list))
+	for _, c := range list {
+		content[c.mediaType] = map[string]interface{}{"schema": g.valueSchema(c.schema)}
+	}
+	return content
+}
+
+// operation describes op as an OpenAPI operation object
+func (g *schemaGenerator) operation(op apiOperation) map[string]interface{} {
+	var parameters []interface{}
+	for _, m := range pathParamPattern.FindAllStringSubmatch(op.path, -1) {
+		parameters = append(parameters, apiPathParams[m[1]].parameter())
+	}
+	for _, p := range op.params {
+		parameters = append(parameters, p.parameter())
+	}
+
+	byStatus := make(map[string]map[string]interface{})
+	for _, resp := range op.responses {
+		status := fmt.Sprint(resp.status)
+		r, ok := byStatus[status]
+		if !ok {
+			r = map[string]interface{}{"description": http.StatusText(resp.status)}
+			byStatus[status] = r
+		}
+		if len(resp.content) > 0 {
+			content, _ := r["content"].(map[string]interface{})
+			if content == nil {
+				content = make(map[string]interface{})
+				r["content"] = content
+			}
+			for mediaType, c := range g.content(resp.content) {
+				content[mediaType] = c
+			}
+		}
+		if len(resp.headers) > 0 {
+			headers := make(map[string]interface{})
+			for _, name := range resp.headers {
+				h := apiHeaders[name]
+				headers[name] = map[string]interface{}{
+					"description": h.description,
+					"required":    h.required,
+					"schema":      map[string]interface{}{"type": "string"},
+				}
+			}
+			r["headers"] = headers
+		}
+	}
+	responses := make(map[string]interface{}, len(byStatus))
+	for status, r := range byStatus {
+		responses[status] = r
+	}
+
+	o := map[string]interface{}{
+		"operationId": op.id,
+		"summary":     op.summary,
+		"responses":   responses,
+	}
+	if op.description != "" {
+		o["description"] = op.description
+	}
+	if len(parameters) > 0 {
+		o["parameters"] = parameters
+	}
+	if len(op.body) > 0 {
+		o["requestBody"] = map[string]interface{}{"required": true, "content": g.content(op.body)}
+	}
+	return o
+}
+
+// openAPIDocument builds the OpenAPI document from apiOperations and the
+// Go types they name
+func openAPIDocument() map[string]interface{} {
+	g := schemaGenerator{components: make(map[string]interface{})}
+	// listCharts refers to ChartSummary from a literal schema
+	g.schema(reflect.TypeOf(ChartSummary{}))
+	paths := make(map[string]interface{})
+	for _, op := range apiOperations {
+		item, _ := paths["/api"+op.path].(map[string]interface{})
+		if item == nil {
+			item = make(map[string]interface{})
+			paths["/api"+op.path] = item
+		}
+		item[strings.ToLower(op.method)] = g.operation(op)
+	}
+	return map[string]interface{}{
+		"openapi": "3.1.0",
+		"info": map[string]interface{}{
+			"title":       "go-ghant",
+			"version":     "1",
+			"description": openAPIDescription,
+		},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": g.components},
+	}
+}
+
+var openAPIJSON = sync.OnceValue(func() []byte {
+	data, err := json.MarshalIndent(openAPIDocument(), "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return data
+})
+
+func openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	w.Write(openAPIJSON())
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// PATCH body media types
+const (
+	mergePatchContentType = "application/merge-patch+json" // RFC 7396
+	jsonPatchContentType  = "application/json-patch+json"  // RFC 6902
+	acceptPatchHeader     = "Accept-Patch"
+)
+
+// patchOp is one operation of a JSON Patch document. Value is nil when
+// the member is absent and holds null when it is explicitly null.
+type patchOp struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// jsonValue converts v to its generic JSON form: maps, slices, strings,
+// float64s, bools and nil
+func jsonValue(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	err = json.Unmarshal(data, &value)
+	return value, err
+}
+
+// fromJSONValue decodes a generic JSON value into dst
+func fromJSONValue(value interface{}, dst interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
+
+// mergePatch applies an RFC 7396 merge patch to target: objects merge
+// member by member, null removes a member, and anything else replaces
+// the target outright
+func mergePatch(target, patch interface{}) interface{} {
+	members, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	merged, ok := target.(map[string]interface{})
+	if !ok {
+		merged = make(map[string]interface{}, len(members))
+	}
+	for name, value := range members {
+		if value == nil {
+			delete(merged, name)
+		} else {
+			merged[name] = mergePatch(merged[name], value)
+		}
+	}
+	return merged
+}
+
+// patchFunc applies a parsed PATCH body to a generic JSON document
+type patchFunc func(doc interface{}) (interface{}, error)
+
+// readPatch reads a PATCH body as a merge patch or a JSON Patch,
+// according to its Content-Type. It writes a 400 for a malformed body
+// and a 415 for any other media type, and reports whether the patch can
+// be applied.
+func readPatch(w http.ResponseWriter, r *http.Request) (patchFunc, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get(contentTypeHeader))
+	switch mediaType {
+	case mergePatchContentType:
+		// Any other value would replace the whole resource
+		var patch map[string]interface{}
+		if err := json.Unmarshal(body, &patch); err != nil || patch == nil {
+			http.Error(w, "merge patch must be a JSON object", http.StatusBadRequest)
+			return nil, false
+		}
+		return func(doc interface{}) (interface{}, error) {
+			return mergePatch(doc, patch), nil
+		}, true
+	case jsonPatchContentType:
+		ops, err := parseJSONPatch(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil, false
+		}
+		return func(doc interface{}) (interface{}, error) {
+			return applyJSONPatch(doc, ops)
+		}, true
+	}
+	w.Header().Set(acceptPatchHeader, mergePatchContentType+", "+jsonPatchContentType)
+	http.Error(w, fmt.Sprintf("Content-Type must be %s or %s", mergePatchContentType, jsonPatchContentType), http.StatusUnsupportedMediaType)
+	return nil, false
+}
+
+// patchItem applies a patch to current, a category or task, and decodes
+// the result into dst. A failed JSON Patch operation is a conflict, and a
+// result that is not a valid item a bad request.
+func patchItem(current interface{}, apply patchFunc, dst interface{}) error {
+	target, err := jsonValue(current)
+	if err != nil {
+		return err
+	}
+	doc, err := apply(target)
+	if err != nil {
+		return &httpError{http.StatusConflict, err.Error()}
+	}
+	if err := fromJSONValue(doc, dst); err != nil {
+		return &httpError{http.StatusBadRequest, err.Error()}
+	}
+	return nil
+}
+
+// parseJSONPatch decodes an RFC 6902 document and checks that every
+// operation is known and has the members it needs
+func parseJSONPatch(body []byte) ([]patchOp, error) {
+	var ops []patchOp
+	if err := json.Unmarshal(body, &ops); err != nil {
+		return nil, errors.New("JSON Patch must be an array of operations")
+	}
+	for i, op := range ops {
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				return nil, fmt.Errorf("operation %d (%s) needs a value", i, op.Op)
+			}
+		case "move", "copy":
+			if op.From == nil {
+				return nil, fmt.Errorf("operation %d (%s) needs from", i, op.Op)
+			}
+			if _, err := parsePointer(*op.From); err != nil {
+				return nil, fmt.Errorf("operation %d: %w", i, err)
+			}
+		case "remove":
+		default:
+			return nil, fmt.Errorf("operation %d has unknown op %q (want add, remove, replace, move, copy or test)", i, op.Op)
+		}
+		if op.Path == nil {
+			return nil, fmt.Errorf("operation %d (%s) needs a path", i, op.Op)
+		}
+		if _, err := parsePointer(*op.Path); err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+	return ops, nil
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into unescaped reference
+// tokens; the empty pointer names the whole document
+func parsePointer(p string) ([]string, error) {
+	if p == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(p, "/") {
+		return nil, fmt.Errorf("JSON Pointer %q must be empty or start with /", p)
+	}
+	unescape := strings.NewReplacer("~1", "/", "~0", "~")
+	tokens := strings.Split(p[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = unescape.Replace(t)
+	}
+	return tokens, nil
+}
+
+// arrayIndex parses an array index token. When adding, the index may be
+// one past the end, which "-" also names.
+func arrayIndex(token string, length int, adding bool) (int, error) {
+	if adding && token == "-" {
+		return length, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || token[0] == '+' || token[0] == '-' || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("%q is not an array index", token)
+	}
+	if i > length || (i == length && !adding) {
+		return 0, fmt.Errorf("index %d is out of range", i)
+	}
+	return i, nil
+}
+
+// pointerGet returns the value at tokens
+func pointerGet(doc interface{}, tokens []string) (interface{}, error) {
+	for _, token := range tokens {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("member %q does not exist", token)
+			}
+			doc = value
+		case []interface{}:
+			i, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, fmt.Errorf("cannot look up %q in a scalar", token)
+		}
+	}
+	return doc, nil
+}
+
+// pointerUpdate returns doc with fn applied to the container holding the
+// last token. fn returns the new container, since adding to or removing
+// from an array changes its length.
+func pointerUpdate(doc interface{}, tokens []string, fn func(container interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(tokens) == 1 {
+		return fn(doc, tokens[0])
+	}
+	child, err := pointerGet(doc, tokens[:1])
+	if err != nil {
+		return nil, err
+	}
+	if child, err = pointerUpdate(child, tokens[1:], fn); err != nil {
+		return nil, err
+	}
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		node[tokens[0]] = child
+	case []interface{}:
+		i, _ := arrayIndex(tokens[0], len(node), false)
+		node[i] = child
+	}
+	return doc, nil
+}
+
+func pointerAdd(doc interface{}, tokens []string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	return pointerUpdate(doc, tokens, func(container interface{}, token string) (interface{}, error) {
+		switch node := container.(type) {
+		case map[string]interface{}:
+			node[token] = value
+			return node, nil
+		case []interface{}:
+			i, err := arrayIndex(token, len(node), true)
+			if err != nil {
+				return nil, err
+			}
+			node = append(node, nil)
+			copy(node[i+1:], node[i:])
+			node[i] = value
+			return node, nil
+		}
+		return nil, fmt.Errorf("cannot add %q to a scalar", token)
+	})
+}
+
+func pointerRemove(doc interface{}, tokens []string) (interface{}, error) {
+	if len(tokens) == 0 {
+		return nil, errors.New("cannot remove the whole document")
+	}
+	return pointerUpdate(doc, tokens, func(container interface{}, token string) (interface{}, error) {
+		switch node := container.(type) {
+		case map[string]interface{}:
+			if _, ok := node[token]; !ok {
+				return nil, fmt.Errorf("member %q does not exist", token)
+			}
+			delete(node, token)
+			return node, nil
+		case []interface{}:
+			i, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			return append(node[:i], node[i+1:]...), nil
+		}
+		return nil, fmt.Errorf("cannot remove %q from a scalar", token)
+	})
+}
+
+func pointerReplace(doc interface{}, tokens []string, value interface{}) (interface{}, error) {
+	if _, err := pointerGet(doc, tokens); err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	return pointerUpdate(doc, tokens, func(container interface{}, token string) (interface{}, error) {
+		switch node := container.(type) {
+		case map[string]interface{}:
+			node[token] = value
+		case []interface{}:
+			i, _ := arrayIndex(token, len(node), false)
+			node[i] = value
+		}
+		return container, nil
+	})
+}
+
+// applyJSONPatch applies the operations to doc in order, stopping at the
+// first that fails
+func applyJSONPatch(doc interface{}, ops []patchOp) (interface{}, error) {
+	for i, op := range ops {
+		path, _ := parsePointer(*op.Path)
+		var value interface{}
+		if op.Value != nil {
+			if err := json.Unmarshal(op.Value, &value); err != nil {
+				return nil, err
+			}
+		}
+
+		var err error
+		switch op.Op {
+		case "add":
+			doc, err = pointerAdd(doc, path, value)
+		case "remove":
+			doc, err = pointerRemove(doc, path)
+		case "replace":
+			doc, err = pointerReplace(doc, path, value)
+		case "move", "copy":
+			from, _ := parsePointer(*op.From)
+			if value, err = pointerGet(doc, from); err != nil {
+				break
+			}
+			if op.Op == "copy" {
+				// The copy must not share maps or slices with the original
+				value, _ = jsonValue(value)
+			} else if *op.Path == *op.From {
+				break
+			} else if strings.HasPrefix(*op.Path, *op.From+"/") {
+				err = errors.New("cannot move a value into itself")
+				break
+			} else if doc, err = pointerRemove(doc, from); err != nil {
+				break
+			}
+			doc, err = pointerAdd(doc, path, value)
+		case "test":
+			var actual interface{}
+			if actual, err = pointerGet(doc, path); err == nil && !reflect.DeepEqual(actual, value) {
+				data, _ := json.Marshal(actual)
+				err = fmt.Errorf("value is %s", data)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s) failed: %w", i, op.Op, *op.Path, err)
+		}
+	}
+	return doc, nil
+}
+
+// patchChartHandler applies a merge patch or JSON Patch to a chart. The
+// patch is applied to the chart's JSON under the store lock and the result
+// is validated like a full update, so either all of it applies or none.
+func patchChartHandler(w http.ResponseWriter, r *http.Request) {
+	apply, ok := readPatch(w, r)
+	if !ok {
+		return
+	}
+
+	chart, ok := modifyChart(w, r, "", func(chart *Chart) error {
+		doc, err := jsonValue(chart)
+		if err != nil {
+			return err
+		}
+		if doc, err = apply(doc); err != nil {
+			return &httpError{http.StatusConflict, err.Error()}
+		}
+		var patched Chart
+		if err := fromJSONValue(doc, &patched); err != nil {
+			return fmt.Errorf("patched chart is invalid: %w", err)
+		}
+		// As with a full update, the ID and baselines cannot be changed
+		patched.ID = chart.ID
+		patched.Baselines = chart.Baselines
+		*chart = patched
+		return nil
+	})
+	if !ok {
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	json.NewEncoder(w).Encode(chart)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func decodeJSON(t *testing.T, s string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("decoding %s: %v", s, err)
+	}
+	return v
+}
+
+// The examples of RFC 6902 appendix A, with an empty want for those that
+// must fail
+func TestApplyJSONPatch(t *testing.T) {
+	tests := []struct {
+		name, doc, patch, want string
+	}{
+		{"A.1 add object member", `{"foo": "bar"}`,
+			`[{"op": "add", "path": "/baz", "value": "qux"}]`,
+			`{"baz": "qux", "foo": "bar"}`},
+		{"A.2 add array element", `{"foo": ["bar", "baz"]}`,
+			`[{"op": "add", "path": "/foo/1", "value": "qux"}]`,
+			`{"foo": ["bar", "qux", "baz"]}`},
+		{"A.3 remove object member", `{"baz": "qux", "foo": "bar"}`,
+			`[{"op": "remove", "path": "/baz"}]`,
+			`{"foo": "bar"}`},
+		{"A.4 remove array element", `{"foo": ["bar", "qux", "baz"]}`,
+			`[{"op": "remove", "path": "/foo/1"}]`,
+			`{"foo": ["bar", "baz"]}`},
+		{"A.5 replace value", `{"baz": "qux", "foo": "bar"}`,
+			`[{"op": "replace", "path": "/baz", "value": "boo"}]`,
+			`{"baz": "boo", "foo": "bar"}`},
+		{"A.6 move value", `{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`,
+			`[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}]`,
+			`{"foo": {"bar": "baz"}, "qux": {"corge": "grault", "thud": "fred"}}`},
+		{"A.7 move array element", `{"foo": ["all", "grass", "cows", "eat"]}`,
+			`[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`,
+			`{"foo": ["all", "cows", "eat", "grass"]}`},
+		{"A.8 test value", `{"baz": "qux", "foo": ["a", 2, "c"]}`,
+			`[{"op": "test", "path": "/baz", "value": "qux"}, {"op": "test", "path": "/foo/1", "value": 2}]`,
+			`{"baz": "qux", "foo": ["a", 2, "c"]}`},
+		{"A.9 failed test", `{"baz": "qux"}`,
+			`[{"op": "test", "path": "/baz", "value": "bar"}]`,
+			``},
+		{"A.10 add nested member", `{"foo": "bar"}`,
+			`[{"op": "add", "path": "/child", "value": {"grandchild": {}}}]`,
+			`{"foo": "bar", "child": {"grandchild": {}}}`},
+		{"A.11 ignore unrecognized members", `{"foo": "bar"}`,
+			`[{"op": "add", "path": "/baz", "value": "qux", "xyz": 123}]`,
+			`{"foo": "bar", "baz": "qux"}`},
+		{"A.12 add to nonexistent target", `{"foo": "bar"}`,
+			`[{"op": "add", "path": "/baz/bat", "value": "qux"}]`,
+			``},
+		{"A.13 invalid patch document", `{"foo": "bar"}`,
+			`[{"op": "add", "path": "/baz", "value": "qux", "op": "remove"}]`,
+			``},
+		{"A.14 escaped pointer", `{"/": 9, "~1": 10}`,
+			`[{"op": "test", "path": "/~01", "value": 10}]`,
+			`{"/": 9, "~1": 10}`},
+		{"A.15 string is not a number", `{"/": 9, "~1": 10}`,
+			`[{"op": "test", "path": "/~01", "value": "10"}]`,
+			``},
+		{"A.16 add array value", `{"foo": ["bar"]}`,
+			`[{"op": "add", "path": "/foo/-", "value": ["abc", "def"]}]`,
+			`{"foo": ["bar", ["abc", "def"]]}`},
+		{"move into own child", `{"a": {"b": {}}}`,
+			`[{"op": "move", "from": "/a", "path": "/a/b/c"}]`,
+			``},
+	}
+	for _, test := range tests {
+		ops, err := parseJSONPatch([]byte(test.patch))
+		var got interface{}
+		if err == nil {
+			got, err = applyJSONPatch(decodeJSON(t, test.doc), ops)
+		}
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("%s: got %v, want an error", test.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if want := decodeJSON(t, test.want); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, want)
+		}
+	}
+}
+
+// The examples of RFC 7396 appendix A
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		target, patch, want string
+	}{
+		{`{"a": "b"}`, `{"a": "c"}`, `{"a": "c"}`},
+		{`{"a": "b"}`, `{"b": "c"}`, `{"a": "b", "b": "c"}`},
+		{`{"a": "b"}`, `{"a": null}`, `{}`},
+		{`{"a": "b", "b": "c"}`, `{"a": null}`, `{"b": "c"}`},
+		{`{"a": ["b"]}`, `{"a": "c"}`, `{"a": "c"}`},
+		{`{"a": "c"}`, `{"a": ["b"]}`, `{"a": ["b"]}`},
+		{`{"a": {"b": "c"}}`, `{"a": {"b": "d", "c": null}}`, `{"a": {"b": "d"}}`},
+		{`{"a": [{"b": "c"}]}`, `{"a": [1]}`, `{"a": [1]}`},
+		{`["a", "b"]`, `["c", "d"]`, `["c", "d"]`},
+		{`{"a": "b"}`, `["c"]`, `["c"]`},
+		{`{"a": "foo"}`, `null`, `null`},
+		{`{"a": "foo"}`, `"bar"`, `"bar"`},
+		{`{"e": null}`, `{"a": 1}`, `{"e": null, "a": 1}`},
+		{`[1, 2]`, `{"a": "b", "c": null}`, `{"a": "b"}`},
+		{`{}`, `{"a": {"bb": {"ccc": null}}}`, `{"a": {"bb": {}}}`},
+	}
+	for _, test := range tests {
+		got := mergePatch(decodeJSON(t, test.target), decodeJSON(t, test.patch))
+		if want := decodeJSON(t, test.want); !reflect.DeepEqual(got, want) {
+			t.Errorf("merging %s into %s: got %v, want %v", test.patch, test.target, got, want)
+		}
+	}
+}
+
+func TestPatchChartFailedTest(t *testing.T) {
+	useTestStore(t)
+	router := apiRouter()
+
+	req := httptest.NewRequest("POST", "/api/charts", strings.NewReader(`{"id": "plan", "title": "Plan",
+		"startYear": 2025, "startQuarter": 1, "endYear": 2025, "endQuarter": 4}`))
+	req.Header.Set(contentTypeHeader, jsonContentType)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("creating chart: got status %d: %s", rec.Code, rec.Body)
+	}
+	revisions := len(store.Revisions("plan"))
+
+	// The replace applies before the test fails, and must not stick
+	req = httptest.NewRequest("PATCH", "/api/charts/plan", strings.NewReader(`[
+		{"op": "replace", "path": "/title", "value": "Renamed"},
+		{"op": "test", "path": "/startYear", "value": 2024}
+	]`))
+	req.Header.Set(contentTypeHeader, jsonPatchContentType)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("got status %d, want %d: %s", rec.Code, http.StatusConflict, rec.Body)
+	}
+	if title := store.Get("plan").Title; title != "Plan" {
+		t.Errorf("title is %q after the failed patch, want Plan", title)
+	}
+	if n := len(store.Revisions("plan")); n != revisions {
+		t.Errorf("got %d revisions after the failed patch, want %d", n, revisions)
+	}
+}
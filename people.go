@@ -0,0 +1,345 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const personNotFoundMsg = "Person not found"
+
+// maxInitials is the longest initials a person may have
+const maxInitials = 3
+
+// PersonTask is a task assigned to a person, with the chart and category
+// it belongs to
+type PersonTask struct {
+	ChartID      string `json:"chartId"`
+	ChartTitle   string `json:"chartTitle"`
+	CategoryID   string `json:"categoryId"`
+	CategoryName string `json:"categoryName"`
+	Task         Task   `json:"task"`
+}
+
+// normalizePerson assigns a person an ID and initials if they lack them
+func normalizePerson(person *Person) {
+	if person.ID == "" {
+		person.ID = uuid.New().String()
+	}
+	if person.Initials == "" {
+		person.Initials = nameInitials(person.Name)
+	}
+}
+
+// nameInitials returns the upper-cased first letters of the first and
+// last words of a name, e.g. "AL" for "Ada King Lovelace"
+func nameInitials(name string) string {
+	words := strings.Fields(name)
+	if len(words) == 0 {
+		return ""
+	}
+	first := []rune(words[0])[0]
+	if len(words) == 1 {
+		return string(unicode.ToUpper(first))
+	}
+	last := []rune(words[len(words)-1])[0]
+	return string([]rune{unicode.ToUpper(first), unicode.ToUpper(last)})
+}
+
+// validatePerson checks a directory entry's fields
+func validatePerson(person *Person) error {
+	var errs []error
+	if strings.TrimSpace(person.Name) == "" {
+		errs = append(errs, fieldErrorf("/name", "person name is required"))
+	}
+	if person.Email != "" {
+		if addr, err := mail.ParseAddress(person.Email); err != nil || addr.Address != person.Email {
+			errs = append(errs, fieldErrorf("/email", "person %q has invalid email %q", person.Name, person.Email))
+		}
+	}
+	if len([]rune(person.Initials)) > maxInitials {
+		errs = append(errs, fieldErrorf("/initials", "person %q has initials %q (want at most %d characters)", person.Name, person.Initials, maxInitials))
+	}
+	return errors.Join(errs...)
+}
+
+// sortPeople orders people by name, then ID
+func sortPeople(people []*Person) []*Person {
+	sort.Slice(people, func(i, j int) bool {
+		if people[i].Name != people[j].Name {
+			return people[i].Name < people[j].Name
+		}
+		return people[i].ID < people[j].ID
+	})
+	return people
+}
+
+// validateAssignees checks that every owner and assignee in the chart is
+// in the people directory. The caller must hold storeMux.
+func validateAssignees(chart *Chart) error {
+	var errs []error
+	if chart.Owner != "" && store.Person(chart.Owner) == nil {
+		errs = append(errs, fieldErrorf("/owner", "chart owner %q is not in the people directory", chart.Owner))
+	}
+	for i, cat := range chart.Categories {
+		if cat.Owner != "" && store.Person(cat.Owner) == nil {
+			errs = append(errs, fieldErrorf(fmt.Sprintf("/categories/%d/owner", i), "category %q owner %q is not in the people directory", cat.Name, cat.Owner))
+		}
+	}
+	walkChartTasks(chart, func(task *Task, path string) {
+		seen := make(map[string]bool, len(task.Assignees))
+		for i, id := range task.Assignees {
+			assigneePath := fmt.Sprintf("%s/assignees/%d", path, i)
+			switch {
+			case seen[id]:
+				errs = append(errs, fieldErrorf(assigneePath, "task %q lists assignee %q twice", task.Title, id))
+			case store.Person(id) == nil:
+				errs = append(errs, fieldErrorf(assigneePath, "task %q assignee %q is not in the people directory", task.Title, id))
+			}
+			seen[id] = true
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// personReferences returns the titles of the charts that assign or are
+// owned by a person. The caller must hold storeMux.
+func personReferences(id string) []string {
+	var titles []string
+	for _, chart := range store.GetAll() {
+		if chartReferences(chart, id) {
+			titles = append(titles, chart.Title)
+		}
+	}
+	sort.Strings(titles)
+	return titles
+}
+
+func chartReferences(chart *Chart, personID string) bool {
+	if chart.Owner == personID {
+		return true
+	}
+	for _, cat := range chart.Categories {
+		if cat.Owner == personID {
+			return true
+		}
+		for _, task := range allTasks(cat.Tasks) {
+			if isAssigned(task, personID) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isAssigned(task Task, personID string) bool {
+	for _, id := range task.Assignees {
+		if id == personID {
+			return true
+		}
+	}
+	return false
+}
+
+// taskInitials returns the label printed on each task's bar: the initials
+// of its assignees, or else of its category's or chart's owner
+func taskInitials(chart *Chart, people map[string]*Person) map[string]string {
+	initials := func(ids ...string) string {
+		var parts []string
+		for _, id := range ids {
+			if p := people[id]; p != nil && p.Initials != "" {
+				parts = append(parts, p.Initials)
+			}
+		}
+		return strings.Join(parts, " ")
+	}
+
+	labels := make(map[string]string)
+	for _, cat := range chart.Categories {
+		for _, task := range allTasks(cat.Tasks) {
+			label := initials(task.Assignees...)
+			if label == "" {
+				label = initials(cat.Owner)
+			}
+			if label == "" {
+				label = initials(chart.Owner)
+			}
+			if label != "" {
+				labels[task.ID] = label
+			}
+		}
+	}
+	return labels
+}
+
+// peopleByID indexes the people directory. The caller must hold storeMux.
+func peopleByID() map[string]*Person {
+	people := make(map[string]*Person)
+	for _, p := range store.People() {
+		people[p.ID] = p
+	}
+	return people
+}
+
+func listPeopleHandler(w http.ResponseWriter, r *http.Request) {
+	storeMux.RLock()
+	people := store.People()
+	storeMux.RUnlock()
+
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	json.NewEncoder(w).Encode(people)
+}
+
+func getPersonHandler(w http.ResponseWriter, r *http.Request) {
+	storeMux.RLock()
+	person := store.Person(mux.Vars(r)["personId"])
+	storeMux.RUnlock()
+
+	if person == nil {
+		http.Error(w, personNotFoundMsg, http.StatusNotFound)
+		return
+	}
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	json.NewEncoder(w).Encode(person)
+}
+
+func createPersonHandler(w http.ResponseWriter, r *http.Request) {
+	var person Person
+	if err := json.NewDecoder(r.Body).Decode(&person); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	normalizePerson(&person)
+	if err := validatePerson(&person); err != nil {
+		writeValidationProblem(w, err)
+		return
+	}
+
+	storeMux.Lock()
+	if store.Person(person.ID) != nil {
+		storeMux.Unlock()
+		http.Error(w, fmt.Sprintf("Person %s already exists", person.ID), http.StatusConflict)
+		return
+	}
+	if !putPerson(w, &person) {
+		storeMux.Unlock()
+		return
+	}
+	storeMux.Unlock()
+
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(person)
+}
+
+func updatePersonHandler(w http.ResponseWriter, r *http.Request) {
+	var person Person
+	if err := json.NewDecoder(r.Body).Decode(&person); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	person.ID = mux.Vars(r)["personId"]
+	normalizePerson(&person)
+	if err := validatePerson(&person); err != nil {
+		writeValidationProblem(w, err)
+		return
+	}
+
+	storeMux.Lock()
+	if store.Person(person.ID) == nil {
+		storeMux.Unlock()
+		http.Error(w, personNotFoundMsg, http.StatusNotFound)
+		return
+	}
+	if !putPerson(w, &person) {
+		storeMux.Unlock()
+		return
+	}
+	storeMux.Unlock()
+
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	json.NewEncoder(w).Encode(person)
+}
+
+// putPerson stores a person and saves, writing the error response on
+// failure. The caller must hold storeMux.
+func putPerson(w http.ResponseWriter, person *Person) bool {
+	store.PutPerson(person)
+	if err := store.Save(); err != nil {
+		store.Rollback()
+		logErrorf("Error saving person %s: %v", person.ID, err)
+		http.Error(w, saveFailedMsg, http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// deletePersonHandler removes a person who is no longer assigned to or
+// owning anything
+func deletePersonHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["personId"]
+
+	storeMux.Lock()
+	defer storeMux.Unlock()
+
+	if store.Person(id) == nil {
+		http.Error(w, personNotFoundMsg, http.StatusNotFound)
+		return
+	}
+	if charts := personReferences(id); len(charts) > 0 {
+		http.Error(w, fmt.Sprintf("Person is still assigned in: %s", strings.Join(charts, ", ")), http.StatusConflict)
+		return
+	}
+	store.DeletePerson(id)
+	if err := store.Save(); err != nil {
+		store.Rollback()
+		logErrorf("Error deleting person %s: %v", id, err)
+		http.Error(w, saveFailedMsg, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// personTasksHandler lists the tasks assigned to a person across every
+// chart, ordered by chart title and then chart order
+func personTasksHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["personId"]
+
+	storeMux.RLock()
+	person := store.Person(id)
+	charts := store.GetAll()
+	storeMux.RUnlock()
+
+	if person == nil {
+		http.Error(w, personNotFoundMsg, http.StatusNotFound)
+		return
+	}
+
+	sort.Slice(charts, func(i, j int) bool {
+		if charts[i].Title != charts[j].Title {
+			return charts[i].Title < charts[j].Title
+		}
+		return charts[i].ID < charts[j].ID
+	})
+	tasks := []PersonTask{}
+	for _, chart := range charts {
+		for _, cat := range chart.Categories {
+			for _, task := range allTasks(cat.Tasks) {
+				if isAssigned(task, id) {
+					tasks = append(tasks, PersonTask{chart.ID, chart.Title, cat.ID, cat.Name, task})
+				}
+			}
+		}
+	}
+
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	json.NewEncoder(w).Encode(tasks)
+}
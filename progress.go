@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+)
+
+// validateProgress checks that every task's progress is a percentage
+func validateProgress(chart *Chart) error {
+	var errs []error
+	walkChartTasks(chart, func(task *Task, path string) {
+		if task.Progress < 0 || task.Progress > 100 {
+			errs = append(errs, fieldErrorf(path+"/progress", "task %q has progress %d (want 0-100)", task.Title, task.Progress))
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// categoryProgress returns the progress of a category's tasks weighted by
+// their duration. It reports false if none of them tracks progress.
+func categoryProgress(cat Category, cal calendar) (int, bool) {
+	tracked := false
+	var done, total float64
+	for _, task := range cat.Tasks {
+		if task.Progress > 0 {
+			tracked = true
+		}
+		start, end, ok := taskDates(task, cal)
+		if !ok {
+			continue
+		}
+		days := end.Sub(start).Hours() / 24
+		done += days * float64(task.Progress)
+		total += days
+	}
+	if !tracked || total == 0 {
+		return 0, false
+	}
+	return int(math.Round(done / total)), true
+}
+
+func progressLabel(progress int) string {
+	return strconv.Itoa(progress) + "%"
+}
+
+// shadeRGBA scales a colour's channels by factor, so factors below 1
+// darken it
+func shadeRGBA(col color.RGBA, factor float64) color.RGBA {
+	scale := func(c uint8) uint8 {
+		return uint8(min(255, math.Round(float64(c)*factor)))
+	}
+	return color.RGBA{scale(col.R), scale(col.G), scale(col.B), col.A}
+}
+
+// shadeColor is shadeRGBA for #rrggbb colours
+func shadeColor(hex string, factor float64) string {
+	col := shadeRGBA(parseColor(hex), factor)
+	return fmt.Sprintf("#%02x%02x%02x", col.R, col.G, col.B)
+}
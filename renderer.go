@@ -3,13 +3,15 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"strings"
 )
 
 type svgLayoutConfig struct {
 	headerHeight           int
+	groupRowHeight         int
 	baseRowHeight          int
-	quarterWidth           int
+	columnWidth            int
 	labelWidth             int
 	padding                int
 	categoryHeaderHeight   int
@@ -18,23 +20,74 @@ type svgLayoutConfig struct {
 	verticalPaddingPerTask int
 }
 
+// minBarWidth keeps bars of very short tasks visible, in pixels
+const minBarWidth = 3
+
+// milestoneSize is the half-diagonal of a milestone diamond, in pixels
+const milestoneSize = 8
+
+// svgColumnWidths is the width in pixels of one timeline column
+var svgColumnWidths = map[string]int{
+	granularityQuarter: 120,
+	granularityMonth:   60,
+	granularityWeek:    40,
+	granularityDay:     24,
+}
+
+// ExportOptions adjusts how a chart is rendered. The zero value renders
+// the chart as stored.
+type ExportOptions struct {
+	// Critical holds the IDs of tasks to highlight as critical
+	Critical map[string]bool
+	// ProgressLabels adds each task's percentage next to its bar
+	ProgressLabels bool
+	// Initials maps task IDs to the owner initials printed on their bars
+	Initials map[string]string
+	// Baseline maps task IDs to their baselined dates, drawn as a ghost
+	// bar under the task's bar
+	Baseline map[string]Task
+	// FieldLabels maps task IDs to custom field values printed next to
+	// their bars
+	FieldLabels map[string]string
+}
+
+// barLabel returns the text printed after a task's bar, if any
+func (o ExportOptions) barLabel(task Task) string {
+	var parts []string
+	if o.ProgressLabels {
+		parts = append(parts, progressLabel(task.Progress))
+	}
+	if label := o.FieldLabels[task.ID]; label != "" {
+		parts = append(parts, label)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// criticalColor rings bars on the critical path. The ring sits outside
+// the bar so that it never hides the bar's own status outline.
+const criticalColor = "#c0392b"
+
 type svgRenderContext struct {
 	buf                *bytes.Buffer
-	quarters           []quarterInfo
-	totalQuarters      int
+	timeline           *timeline
+	totalColumns       int
 	config             svgLayoutConfig
+	opts               ExportOptions
 	perTaskHeights     map[string]int
 	perCategoryHeights map[string]int
+	bars               map[string]barRect
+	hatchDefined       bool
 }
 
 // GenerateSVG creates an SVG representation of the Gantt chart
-func GenerateSVG(chart *Chart) (string, error) {
-	quarters := calculateQuarters(chart.StartYear, chart.StartQ, chart.EndYear, chart.EndQ)
+func GenerateSVG(chart *Chart, opts ExportOptions) (string, error) {
+	tl := buildTimeline(chart)
 
 	config := svgLayoutConfig{
 		headerHeight:           80,
+		groupRowHeight:         24,
 		baseRowHeight:          40,
-		quarterWidth:           120,
+		columnWidth:            svgColumnWidths[tl.granularity],
 		labelWidth:             200,
 		padding:                20,
 		categoryHeaderHeight:   35,
@@ -42,30 +95,37 @@ func GenerateSVG(chart *Chart) (string, error) {
 		descLineHeight:         12,
 		verticalPaddingPerTask: 8,
 	}
+	if tl.groups() != nil {
+		config.headerHeight += config.groupRowHeight
+	}
 
 	perTaskHeights, perCategoryHeights := calculateDynamicHeights(chart, config)
 
 	totalCategoryHeadersHeight := sumCategoryHeights(chart, perCategoryHeights)
 	totalTaskHeight := sumTaskHeights(chart, perTaskHeights)
 
-	width := config.labelWidth + len(quarters)*config.quarterWidth + config.padding*2
-	height := config.headerHeight + totalCategoryHeadersHeight + totalTaskHeight + config.padding*2
+	width := config.labelWidth + len(tl.columns)*config.columnWidth + config.padding*2
+	milestonesHeight := countMilestoneRows(chart) * config.baseRowHeight
+	height := config.headerHeight + totalCategoryHeadersHeight + totalTaskHeight + milestonesHeight + config.padding*2
 
 	var buf bytes.Buffer
 	ctx := &svgRenderContext{
 		buf:                &buf,
-		quarters:           quarters,
-		totalQuarters:      len(quarters),
+		timeline:           tl,
+		totalColumns:       len(tl.columns),
 		config:             config,
+		opts:               opts,
 		perTaskHeights:     perTaskHeights,
 		perCategoryHeights: perCategoryHeights,
+		bars:               make(map[string]barRect),
 	}
 
 	ctx.writeSVGHeader(width, height)
 	ctx.writeBackground(width, height)
 	ctx.writeTitle(chart.Title)
-	ctx.writeQuarterHeaders(height)
+	ctx.writeTimelineHeaders(height)
 	ctx.writeCategoriesAndTasks(chart)
+	ctx.writeDependencies(chart)
 	buf.WriteString(`</svg>`)
 
 	return buf.String(), nil
@@ -83,7 +143,8 @@ func calculateDynamicHeights(chart *Chart, config svgLayoutConfig) (map[string]i
 		}
 		perCategoryHeights[cat.ID] = catH
 
-		for _, task := range cat.Tasks {
+		for _, row := range visibleTasks(cat.Tasks) {
+			task := row.task
 			titleLines := wrapText(task.Title, 28)
 			descLines := wrapText(task.Description, 36)
 			h := config.baseRowHeight
@@ -111,8 +172,8 @@ func sumCategoryHeights(chart *Chart, perCategoryHeights map[string]int) int {
 func sumTaskHeights(chart *Chart, perTaskHeights map[string]int) int {
 	total := 0
 	for _, cat := range chart.Categories {
-		for _, task := range cat.Tasks {
-			if h, ok := perTaskHeights[task.ID]; ok {
+		for _, row := range visibleTasks(cat.Tasks) {
+			if h, ok := perTaskHeights[row.task.ID]; ok {
 				total += h
 			}
 		}
@@ -134,20 +195,34 @@ func (ctx *svgRenderContext) writeTitle(title string) {
 		ctx.config.padding, ctx.config.padding+20, escapeXML(title)))
 }
 
-func (ctx *svgRenderContext) writeQuarterHeaders(height int) {
-	for i, q := range ctx.quarters {
-		x := ctx.config.padding + ctx.config.labelWidth + i*ctx.config.quarterWidth
-		y := ctx.config.headerHeight
+func (ctx *svgRenderContext) writeTimelineHeaders(height int) {
+	y := ctx.config.headerHeight
+	for i, g := range ctx.timeline.groups() {
+		x := ctx.config.padding + ctx.config.labelWidth + g.first*ctx.config.columnWidth
+		w := g.count * ctx.config.columnWidth
+
+		color := "#dcdcdc"
+		if i%2 == 1 {
+			color = "#ebebeb"
+		}
+		ctx.buf.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" fill="%s" stroke="#ccc" stroke-width="1"/>`,
+			x, y-30-ctx.config.groupRowHeight, w, ctx.config.groupRowHeight, color))
+		ctx.buf.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="header" text-anchor="middle">%s</text>`,
+			x+w/2, y-30-8, escapeXML(g.label)))
+	}
+
+	for i, col := range ctx.timeline.columns {
+		x := ctx.config.padding + ctx.config.labelWidth + i*ctx.config.columnWidth
 
 		color := "#e8e8e8"
 		if i%2 == 1 {
 			color = "#f5f5f5"
 		}
 		ctx.buf.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" fill="%s" stroke="#ccc" stroke-width="1"/>`,
-			x, y-30, ctx.config.quarterWidth, 30, color))
+			x, y-30, ctx.config.columnWidth, 30, color))
 
-		ctx.buf.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="header" text-anchor="middle">Q%d %d</text>`,
-			x+ctx.config.quarterWidth/2, y-10, q.quarter, q.year))
+		ctx.buf.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="header" text-anchor="middle">%s</text>`,
+			x+ctx.config.columnWidth/2, y-10, escapeXML(col.label)))
 
 		ctx.buf.WriteString(fmt.Sprintf(`<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#ddd" stroke-width="1"/>`,
 			x, y, x, height-ctx.config.padding))
@@ -156,8 +231,15 @@ func (ctx *svgRenderContext) writeQuarterHeaders(height int) {
 
 func (ctx *svgRenderContext) writeCategoriesAndTasks(chart *Chart) {
 	currentY := ctx.config.headerHeight
+	top, byCategory := milestoneRows(chart)
+	if len(top) > 0 {
+		currentY = ctx.writeMilestoneRow(top, "", currentY)
+	}
 	for _, cat := range chart.Categories {
 		currentY = ctx.writeCategory(cat, currentY)
+		if ms := byCategory[cat.ID]; len(ms) > 0 {
+			currentY = ctx.writeMilestoneRow(ms, cat.Color, currentY)
+		}
 	}
 }
 
@@ -171,12 +253,16 @@ func (ctx *svgRenderContext) writeCategory(cat Category, currentY int) int {
 		ctx.config.padding, currentY, ctx.config.labelWidth, catH, cat.Color))
 
 	ctx.writeCategoryName(cat.Name, currentY, catH)
+	if progress, ok := categoryProgress(cat, ctx.timeline.cal); ok {
+		ctx.buf.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="desc" text-anchor="end">%s</text>`,
+			ctx.config.padding+ctx.config.labelWidth-10, currentY+22, progressLabel(progress)))
+	}
 
 	ctx.buf.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" fill="%s" opacity="0.05"/>`,
-		ctx.config.padding+ctx.config.labelWidth, currentY, ctx.totalQuarters*ctx.config.quarterWidth, catH, cat.Color))
+		ctx.config.padding+ctx.config.labelWidth, currentY, ctx.totalColumns*ctx.config.columnWidth, catH, cat.Color))
 
 	currentY += catH
-	return ctx.writeTasks(cat.Tasks, cat.Color, currentY)
+	return ctx.writeTasks(visibleTasks(cat.Tasks), cat.Color, currentY)
 }
 
 func (ctx *svgRenderContext) writeCategoryName(name string, currentY, catH int) {
@@ -197,8 +283,9 @@ func (ctx *svgRenderContext) writeCategoryName(name string, currentY, catH int)
 	}
 }
 
-func (ctx *svgRenderContext) writeTasks(tasks []Task, catColor string, currentY int) int {
-	for _, task := range tasks {
+func (ctx *svgRenderContext) writeTasks(rows []taskRow, catColor string, currentY int) int {
+	for _, row := range rows {
+		task := row.task
 		h := ctx.perTaskHeights[task.ID]
 		if h == 0 {
 			h = ctx.config.baseRowHeight
@@ -207,7 +294,8 @@ func (ctx *svgRenderContext) writeTasks(tasks []Task, catColor string, currentY
 		ctx.buf.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" fill="#fff" stroke="#ddd" stroke-width="1"/>`,
 			ctx.config.padding, currentY, ctx.config.labelWidth, h))
 
-		ctx.writeTaskText(task, currentY)
+		ctx.writeTaskText(task, row.depth, currentY)
+		ctx.writeBaselineBar(task, currentY+h-baselineBarHeight-2)
 		ctx.writeTaskBar(task, catColor, currentY, h)
 
 		currentY += h
@@ -215,32 +303,40 @@ func (ctx *svgRenderContext) writeTasks(tasks []Task, catColor string, currentY
 	return currentY
 }
 
-func (ctx *svgRenderContext) writeTaskText(task Task, currentY int) int {
+func (ctx *svgRenderContext) writeTaskText(task Task, depth, currentY int) int {
 	titleLines := wrapText(task.Title, 28)
 	descLines := wrapText(task.Description, 36)
 	textY := currentY + 14
+	textX := ctx.config.padding + 10 + depth*indentWidth
 
 	if len(titleLines) > 0 {
-		ctx.buf.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="label">`, ctx.config.padding+10, textY))
+		decoration := ""
+		if task.Status == statusCancelled {
+			decoration = ` text-decoration="line-through"`
+		}
+		if isSummary(task) {
+			decoration += ` font-weight="bold"`
+		}
+		ctx.buf.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="label"%s>`, textX, textY, decoration))
 		for i, ln := range titleLines {
 			dy := 0
 			if i > 0 {
 				dy = ctx.config.titleLineHeight
 			}
-			ctx.buf.WriteString(fmt.Sprintf(`<tspan x="%d" dy="%d">%s</tspan>`, ctx.config.padding+10, dy, escapeXML(ln)))
+			ctx.buf.WriteString(fmt.Sprintf(`<tspan x="%d" dy="%d">%s</tspan>`, textX, dy, escapeXML(ln)))
 		}
 		ctx.buf.WriteString(`</text>`)
 		textY += len(titleLines) * ctx.config.titleLineHeight
 	}
 
 	if len(descLines) > 0 {
-		ctx.buf.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="desc">`, ctx.config.padding+10, textY+4))
+		ctx.buf.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="desc">`, textX, textY+4))
 		for i, ln := range descLines {
 			dy := 0
 			if i > 0 {
 				dy = ctx.config.descLineHeight
 			}
-			ctx.buf.WriteString(fmt.Sprintf(`<tspan x="%d" dy="%d">%s</tspan>`, ctx.config.padding+10, dy, escapeXML(ln)))
+			ctx.buf.WriteString(fmt.Sprintf(`<tspan x="%d" dy="%d">%s</tspan>`, textX, dy, escapeXML(ln)))
 		}
 		ctx.buf.WriteString(`</text>`)
 	}
@@ -249,15 +345,13 @@ func (ctx *svgRenderContext) writeTaskText(task Task, currentY int) int {
 }
 
 func (ctx *svgRenderContext) writeTaskBar(task Task, catColor string, currentY, h int) {
-	startIdx := findQuarterIndex(ctx.quarters, task.StartYear, task.StartQ)
-	endIdx := findQuarterIndex(ctx.quarters, task.EndYear, task.EndQ)
-
-	if startIdx < 0 || endIdx < 0 {
+	startPos, endPos, ok := ctx.timeline.taskSpan(task)
+	if !ok {
 		return
 	}
 
-	barX := ctx.config.padding + ctx.config.labelWidth + startIdx*ctx.config.quarterWidth
-	barWidth := (endIdx - startIdx + 1) * ctx.config.quarterWidth
+	barX := ctx.config.padding + ctx.config.labelWidth + int(math.Round(startPos*float64(ctx.config.columnWidth)))
+	barWidth := max(int(math.Round((endPos-startPos)*float64(ctx.config.columnWidth))), minBarWidth+4)
 	barY := currentY + 8
 	barHeight := h - 16
 
@@ -270,10 +364,154 @@ func (ctx *svgRenderContext) writeTaskBar(task Task, catColor string, currentY,
 		barHeight = 12
 	}
 
+	if isSummary(task) {
+		ctx.writeSummaryBar(task, taskColor, barX, barY+(barHeight-summaryBarHeight)/2, barWidth)
+		ctx.bars[task.ID] = barRect{float64(barX + 2), float64(barY), float64(barWidth - 4), float64(barHeight)}
+		return
+	}
+
 	ctx.buf.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" fill="%s" rx="4" opacity="0.8"/>`,
 		barX+2, barY, barWidth-4, barHeight, taskColor))
-	ctx.buf.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" fill="none" stroke="%s" stroke-width="2" rx="4"/>`,
-		barX+2, barY, barWidth-4, barHeight, darken(taskColor)))
+	if task.Progress > 0 {
+		ctx.buf.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" fill="%s" rx="4" opacity="0.9"/>`,
+			barX+2, barY, progressWidth(barWidth-4, task.Progress), barHeight, shadeColor(taskColor, 0.65)))
+	}
+	if task.Status == statusPlanned {
+		ctx.writeHatchPattern()
+		ctx.buf.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" fill="url(#status-hatch)" rx="4"/>`,
+			barX+2, barY, barWidth-4, barHeight))
+	}
+	switch {
+	case task.Status == statusAtRisk:
+		ctx.buf.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" fill="none" stroke="%s" stroke-width="3" rx="4"/>`,
+			barX+2, barY, barWidth-4, barHeight, atRiskColor))
+	case task.Status == statusBlocked:
+		ctx.buf.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" fill="none" stroke="%s" stroke-width="2" stroke-dasharray="4 2" rx="4"/>`,
+			barX+2, barY, barWidth-4, barHeight, blockedColor))
+	default:
+		ctx.buf.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" fill="none" stroke="%s" stroke-width="2" rx="4"/>`,
+			barX+2, barY, barWidth-4, barHeight, darken(taskColor)))
+	}
+	if ctx.opts.Critical[task.ID] {
+		ctx.buf.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" fill="none" stroke="%s" stroke-width="2" rx="6"/>`,
+			barX-1, barY-4, barWidth+2, barHeight+8, criticalColor))
+	}
+	if task.Status == statusCancelled {
+		ctx.buf.WriteString(fmt.Sprintf(`<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s" stroke-width="2"/>`,
+			barX, barY+barHeight/2, barX+barWidth, barY+barHeight/2, cancelledColor))
+	}
+
+	if label := ctx.opts.barLabel(task); label != "" {
+		ctx.buf.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="desc">%s</text>`,
+			barX+barWidth+2, barY+barHeight/2+4, escapeXML(label)))
+	}
+	if initials := ctx.opts.Initials[task.ID]; initials != "" {
+		ctx.buf.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="desc" fill="#fff" font-weight="bold">%s</text>`,
+			barX+6, barY+barHeight/2+4, escapeXML(initials)))
+	}
+
+	ctx.bars[task.ID] = barRect{float64(barX + 2), float64(barY), float64(barWidth - 4), float64(barHeight)}
+}
+
+// writeBaselineBar draws a task's baselined dates as a thin ghost bar
+// starting at y, just below where its bar is drawn
+func (ctx *svgRenderContext) writeBaselineBar(task Task, y int) {
+	old, ok := ctx.opts.Baseline[task.ID]
+	if !ok {
+		return
+	}
+	startPos, endPos, ok := ctx.timeline.taskSpan(old)
+	if !ok {
+		return
+	}
+	x := ctx.config.padding + ctx.config.labelWidth + int(math.Round(startPos*float64(ctx.config.columnWidth)))
+	w := max(int(math.Round((endPos-startPos)*float64(ctx.config.columnWidth))), minBarWidth+4)
+	ctx.buf.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" fill="%s" rx="1"/>`,
+		x+2, y, w-4, baselineBarHeight, baselineColor))
+}
+
+// writeSummaryBar draws a parent task as a thin dark bar with a
+// downward cap at each end, so it reads as a bracket over its subtasks
+func (ctx *svgRenderContext) writeSummaryBar(task Task, taskColor string, barX, barY, barWidth int) {
+	x0, x1 := barX+2, barX+barWidth-2
+	capY := barY + summaryBarHeight
+	dark := shadeColor(taskColor, summaryShade)
+	ctx.buf.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`,
+		x0, barY, x1-x0, summaryBarHeight, dark))
+	if task.Progress > 0 {
+		ctx.buf.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`,
+			x0, barY+2, progressWidth(x1-x0, task.Progress), summaryBarHeight-4, taskColor))
+	}
+	ctx.buf.WriteString(fmt.Sprintf(`<polygon points="%d,%d %d,%d %d,%d" fill="%s"/>`,
+		x0, capY, x0+summaryCapSize, capY, x0, capY+summaryCapSize, dark))
+	ctx.buf.WriteString(fmt.Sprintf(`<polygon points="%d,%d %d,%d %d,%d" fill="%s"/>`,
+		x1-summaryCapSize, capY, x1, capY, x1, capY+summaryCapSize, dark))
+
+	if label := ctx.opts.barLabel(task); label != "" {
+		ctx.buf.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="desc">%s</text>`,
+			barX+barWidth+2, barY+summaryBarHeight/2+4, escapeXML(label)))
+	}
+}
+
+// writeHatchPattern defines the diagonal hatching used for planned tasks
+// the first time it is needed
+func (ctx *svgRenderContext) writeHatchPattern() {
+	if ctx.hatchDefined {
+		return
+	}
+	ctx.hatchDefined = true
+	ctx.buf.WriteString(`<defs><pattern id="status-hatch" patternUnits="userSpaceOnUse" width="6" height="6" patternTransform="rotate(45)"><line x1="0" y1="0" x2="0" y2="6" stroke="#fff" stroke-width="2" opacity="0.7"/></pattern></defs>`)
+}
+
+// progressWidth returns how much of a bar of the given width is filled
+func progressWidth(width, progress int) int {
+	return int(math.Round(float64(width) * float64(progress) / 100))
+}
+
+// writeMilestoneRow draws a row of diamond markers, each labelled with
+// its milestone's title
+func (ctx *svgRenderContext) writeMilestoneRow(milestones []Milestone, catColor string, currentY int) int {
+	h := ctx.config.baseRowHeight
+	ctx.buf.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" fill="#fff" stroke="#ddd" stroke-width="1"/>`,
+		ctx.config.padding, currentY, ctx.config.labelWidth, h))
+	ctx.buf.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="label" font-style="italic">Milestones</text>`,
+		ctx.config.padding+10, currentY+h/2+4))
+
+	for _, m := range milestones {
+		pos, ok := ctx.timeline.milestonePosition(m)
+		if !ok {
+			continue
+		}
+		cx := ctx.config.padding + ctx.config.labelWidth + int(math.Round(pos*float64(ctx.config.columnWidth)))
+		cy := currentY + h/2
+		color := milestoneColor(m, catColor)
+		ctx.buf.WriteString(fmt.Sprintf(`<polygon points="%d,%d %d,%d %d,%d %d,%d" fill="%s" stroke="%s" stroke-width="1.5"/>`,
+			cx, cy-milestoneSize, cx+milestoneSize, cy, cx, cy+milestoneSize, cx-milestoneSize, cy, color, darken(color)))
+		ctx.buf.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="label">%s</text>`,
+			cx+milestoneSize+4, cy+4, escapeXML(m.Title)))
+	}
+	return currentY + h
+}
+
+func (ctx *svgRenderContext) writeDependencies(chart *Chart) {
+	arrows := dependencyArrows(chart, ctx.bars, 8)
+	if len(arrows) == 0 {
+		return
+	}
+
+	ctx.buf.WriteString(`<defs><marker id="dep-arrow" viewBox="0 0 10 10" refX="9" refY="5" markerWidth="7" markerHeight="7" orient="auto"><path d="M0,0 L10,5 L0,10 z" fill="#555"/></marker></defs>`)
+	for _, arrow := range arrows {
+		var d strings.Builder
+		for i, p := range arrow.points {
+			cmd := "L"
+			if i == 0 {
+				cmd = "M"
+			}
+			d.WriteString(fmt.Sprintf("%s%g,%g ", cmd, p.x, p.y))
+		}
+		ctx.buf.WriteString(fmt.Sprintf(`<path d="%s" fill="none" stroke="#555" stroke-width="1.5" marker-end="url(#dep-arrow)"/>`,
+			strings.TrimSpace(d.String())))
+	}
 }
 
 func wrapText(s string, maxChars int) []string {
@@ -303,42 +541,6 @@ func wrapText(s string, maxChars int) []string {
 	return lines
 }
 
-type quarterInfo struct {
-	year    int
-	quarter int
-}
-
-func calculateQuarters(startYear, startQ, endYear, endQ int) []quarterInfo {
-	var quarters []quarterInfo
-
-	for year := startYear; year <= endYear; year++ {
-		startQuarter := 1
-		endQuarter := 4
-
-		if year == startYear {
-			startQuarter = startQ
-		}
-		if year == endYear {
-			endQuarter = endQ
-		}
-
-		for q := startQuarter; q <= endQuarter; q++ {
-			quarters = append(quarters, quarterInfo{year: year, quarter: q})
-		}
-	}
-
-	return quarters
-}
-
-func findQuarterIndex(quarters []quarterInfo, year, quarter int) int {
-	for i, q := range quarters {
-		if q.year == year && q.quarter == quarter {
-			return i
-		}
-	}
-	return -1
-}
-
 func escapeXML(s string) string {
 	s = strings.ReplaceAll(s, "&", "&amp;")
 	s = strings.ReplaceAll(s, "<", "&lt;")
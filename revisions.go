@@ -0,0 +1,317 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	revisionNotFoundMsg = "Revision not found"
+	authorHeader        = "X-Author"
+)
+
+// Revision is an immutable snapshot of a chart taken when it was saved
+type Revision struct {
+	ChartID   string    `json:"chartId"`
+	Number    int       `json:"number"`
+	Author    string    `json:"author"`
+	Message   string    `json:"message,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	Chart     *Chart    `json:"chart,omitempty"`
+}
+
+// RevisionChange describes one difference between two revisions
+type RevisionChange struct {
+	Path string      `json:"path"`
+	Op   string      `json:"op"`
+	From interface{} `json:"from,omitempty"`
+	To   interface{} `json:"to,omitempty"`
+}
+
+// RevisionDiff is the result of comparing two revisions of a chart
+type RevisionDiff struct {
+	From    int              `json:"from"`
+	To      int              `json:"to"`
+	Changes []RevisionChange `json:"changes"`
+}
+
+func newRevision(chart *Chart, number int, author, message string) *Revision {
+	return &Revision{
+		ChartID:   chart.ID,
+		Number:    number,
+		Author:    author,
+		Message:   message,
+		CreatedAt: time.Now(),
+		Chart:     cloneChart(chart),
+	}
+}
+
+// summary returns the revision without its chart snapshot
+func (r *Revision) summary() *Revision {
+	s := *r
+	s.Chart = nil
+	return &s
+}
+
+// diffCharts compares two chart snapshots field by field. Categories and
+// tasks are matched by ID, so reordering or editing an item is reported
+// against that item rather than its position.
+func diffCharts(from, to *Chart) []RevisionChange {
+	var a, b interface{}
+	if err := roundTripJSON(from, &a); err != nil {
+		return nil
+	}
+	if err := roundTripJSON(to, &b); err != nil {
+		return nil
+	}
+
+	changes := []RevisionChange{}
+	diffValues("", a, b, &changes)
+	return changes
+}
+
+func roundTripJSON(v interface{}, out interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+func diffValues(path string, a, b interface{}, changes *[]RevisionChange) {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		if bv, ok := b.(map[string]interface{}); ok {
+			diffObjects(path, av, bv, changes)
+			return
+		}
+	case []interface{}:
+		if bv, ok := b.([]interface{}); ok && hasIDs(av) && hasIDs(bv) {
+			diffIDLists(path, av, bv, changes)
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		*changes = append(*changes, RevisionChange{Path: path, Op: "changed", From: a, To: b})
+	}
+}
+
+func diffObjects(path string, a, b map[string]interface{}, changes *[]RevisionChange) {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if k == "createdAt" || k == "updatedAt" {
+			continue
+		}
+		p := k
+		if path != "" {
+			p = path + "." + k
+		}
+		av, inA := a[k]
+		bv, inB := b[k]
+		switch {
+		case !inA:
+			*changes = append(*changes, RevisionChange{Path: p, Op: "added", To: bv})
+		case !inB:
+			*changes = append(*changes, RevisionChange{Path: p, Op: "removed", From: av})
+		default:
+			diffValues(p, av, bv, changes)
+		}
+	}
+}
+
+// diffIDLists compares lists of objects that carry an "id" field
+func diffIDLists(path string, a, b []interface{}, changes *[]RevisionChange) {
+	byID := make(map[string]interface{}, len(a))
+	for _, item := range a {
+		byID[itemID(item)] = item
+	}
+
+	seen := make(map[string]bool, len(b))
+	common := make(map[string]bool, len(b))
+	for _, item := range b {
+		id := itemID(item)
+		seen[id] = true
+		p := fmt.Sprintf("%s[%s]", path, id)
+		if old, ok := byID[id]; ok {
+			common[id] = true
+			diffValues(p, old, item, changes)
+		} else {
+			*changes = append(*changes, RevisionChange{Path: p, Op: "added", To: item})
+		}
+	}
+	for _, item := range a {
+		if id := itemID(item); !seen[id] {
+			*changes = append(*changes, RevisionChange{Path: fmt.Sprintf("%s[%s]", path, id), Op: "removed", From: item})
+		}
+	}
+
+	if from, to := idOrder(a, common), idOrder(b, common); !reflect.DeepEqual(from, to) {
+		*changes = append(*changes, RevisionChange{Path: path, Op: "reordered", From: from, To: to})
+	}
+}
+
+// idOrder lists the IDs of items that appear in both revisions, in order
+func idOrder(items []interface{}, keep map[string]bool) []string {
+	ids := []string{}
+	for _, item := range items {
+		if id := itemID(item); keep[id] {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func hasIDs(items []interface{}) bool {
+	for _, item := range items {
+		if itemID(item) == "" {
+			return false
+		}
+	}
+	return true
+}
+
+func itemID(item interface{}) string {
+	obj, ok := item.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	id, _ := obj["id"].(string)
+	return id
+}
+
+func requestAuthor(r *http.Request) string {
+	return r.Header.Get(authorHeader)
+}
+
+func parseRevisionNumber(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid revision number %q", s)
+	}
+	return n, nil
+}
+
+func listRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	storeMux.RLock()
+	chart := store.Get(id)
+	revisions := store.Revisions(id)
+	storeMux.RUnlock()
+
+	if chart == nil {
+		http.Error(w, chartNotFoundMsg, http.StatusNotFound)
+		return
+	}
+
+	summaries := make([]*Revision, 0, len(revisions))
+	for _, rev := range revisions {
+		summaries = append(summaries, rev.summary())
+	}
+
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	json.NewEncoder(w).Encode(summaries)
+}
+
+func getRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	number, err := parseRevisionNumber(vars["rev"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	storeMux.RLock()
+	rev := store.Revision(vars["id"], number)
+	storeMux.RUnlock()
+
+	if rev == nil {
+		http.Error(w, revisionNotFoundMsg, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	json.NewEncoder(w).Encode(rev)
+}
+
+func diffRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	query := r.URL.Query()
+
+	from, err := parseRevisionNumber(query.Get("from"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseRevisionNumber(query.Get("to"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	storeMux.RLock()
+	fromRev := store.Revision(id, from)
+	toRev := store.Revision(id, to)
+	storeMux.RUnlock()
+
+	if fromRev == nil || toRev == nil {
+		http.Error(w, revisionNotFoundMsg, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	json.NewEncoder(w).Encode(RevisionDiff{
+		From:    from,
+		To:      to,
+		Changes: diffCharts(fromRev.Chart, toRev.Chart),
+	})
+}
+
+func restoreRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	number, err := parseRevisionNumber(vars["rev"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The snapshot is validated like any other edit, since it may predate
+	// rules or refer to people that have since been removed
+	chart, ok := modifyChart(w, r, fmt.Sprintf("Restored revision %d", number), func(chart *Chart) error {
+		rev := store.Revision(id, number)
+		if rev == nil {
+			return &httpError{http.StatusNotFound, revisionNotFoundMsg}
+		}
+		// Restoring an old plan keeps the baselines frozen since
+		restored := cloneChart(rev.Chart)
+		restored.ID = id
+		restored.Baselines = chart.Baselines
+		*chart = *restored
+		return nil
+	})
+	if !ok {
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	json.NewEncoder(w).Encode(chart)
+}
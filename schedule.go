@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Schedule is the result of a critical path analysis of a chart. Dates
+// are YYYY-MM-DD; finish dates are inclusive, like Task.EndDate.
+type Schedule struct {
+	ChartID string `json:"chartId"`
+	// Start is the earliest planned start of any scheduled task
+	Start string `json:"start,omitempty"`
+	// PlannedEnd is the latest planned end of any scheduled task
+	PlannedEnd string `json:"plannedEnd,omitempty"`
+	// EarliestEnd is the earliest the chart can finish once every
+	// dependency is honoured
+	EarliestEnd string `json:"earliestEnd,omitempty"`
+	// CriticalPath lists the tasks without slack, in order of start
+	CriticalPath []string       `json:"criticalPath"`
+	Tasks        []TaskSchedule `json:"tasks"`
+	// Unscheduled lists tasks without a usable start and end
+	Unscheduled []string `json:"unscheduled,omitempty"`
+}
+
+// TaskSchedule holds the computed window of a single task
+type TaskSchedule struct {
+	TaskID         string `json:"taskId"`
+	Title          string `json:"title"`
+	DurationDays   int    `json:"durationDays"`
+	EarliestStart  string `json:"earliestStart"`
+	EarliestFinish string `json:"earliestFinish"`
+	LatestStart    string `json:"latestStart"`
+	LatestFinish   string `json:"latestFinish"`
+	SlackDays      int    `json:"slackDays"`
+	Critical       bool   `json:"critical"`
+}
+
+// scheduleNode is a task during scheduling. Times are whole days since
+// the earliest planned start, with finishes exclusive.
+type scheduleNode struct {
+	task           *Task
+	order          int
+	planned        int
+	duration       int
+	es, ef, ls, lf int
+	preds          []scheduleEdge
+	succs          []scheduleEdge
+}
+
+type scheduleEdge struct {
+	node    *scheduleNode
+	depType string
+}
+
+// computeSchedule runs a critical path analysis over the chart's task
+// dependencies. Each task keeps its planned duration and starts no
+// earlier than planned; dependencies can only push it later. Slack is how
+// many days a task can slip without moving the chart's earliest end.
+func computeSchedule(chart *Chart) (*Schedule, error) {
+	if err := validateDependencies(chart); err != nil {
+		return nil, err
+	}
+
+	sched := &Schedule{ChartID: chart.ID, CriticalPath: []string{}, Tasks: []TaskSchedule{}}
+
+	type dated struct {
+		task       *Task
+		start, end time.Time
+	}
+	var tasks []dated
+	var origin time.Time
+	cal := chartCalendar(chart)
+	for i := range chart.Categories {
+		// Summary tasks span their subtasks, so only leaves are scheduled
+		walkTasks(chart.Categories[i].Tasks, func(task *Task, _ int) {
+			if isSummary(*task) {
+				return
+			}
+			start, end, ok := taskDates(*task, cal)
+			if !ok {
+				sched.Unscheduled = append(sched.Unscheduled, task.ID)
+				return
+			}
+			if len(tasks) == 0 || start.Before(origin) {
+				origin = start
+			}
+			tasks = append(tasks, dated{task, start, end})
+		})
+	}
+	if len(tasks) == 0 {
+		return sched, nil
+	}
+
+	days := func(t time.Time) int {
+		return int(math.Round(t.Sub(origin).Hours() / 24))
+	}
+	date := func(day int) string {
+		return origin.AddDate(0, 0, day).Format(dateLayout)
+	}
+
+	nodes := make(map[string]*scheduleNode, len(tasks))
+	ordered := make([]*scheduleNode, 0, len(tasks))
+	plannedEnd := 0
+	for i, t := range tasks {
+		n := &scheduleNode{task: t.task, order: i, planned: days(t.start), duration: days(t.end) - days(t.start)}
+		nodes[t.task.ID] = n
+		ordered = append(ordered, n)
+		plannedEnd = max(plannedEnd, days(t.end))
+	}
+	for _, n := range ordered {
+		for _, dep := range n.task.Dependencies {
+			pred, ok := nodes[dep.TaskID]
+			if !ok {
+				continue // predecessor is unscheduled
+			}
+			n.preds = append(n.preds, scheduleEdge{pred, dependencyType(dep)})
+			pred.succs = append(pred.succs, scheduleEdge{n, dependencyType(dep)})
+		}
+	}
+
+	topo := topologicalOrder(ordered)
+
+	// Forward pass: earliest start and finish
+	end := 0
+	for _, n := range topo {
+		n.es = n.planned
+		for _, e := range n.preds {
+			switch e.depType {
+			case depStartToStart:
+				n.es = max(n.es, e.node.es)
+			case depFinishToFinish:
+				n.es = max(n.es, e.node.ef-n.duration)
+			default:
+				n.es = max(n.es, e.node.ef)
+			}
+		}
+		n.ef = n.es + n.duration
+		end = max(end, n.ef)
+	}
+
+	// Backward pass: latest finish and start that keep the earliest end
+	for i := len(topo) - 1; i >= 0; i-- {
+		n := topo[i]
+		n.lf = end
+		for _, e := range n.succs {
+			switch e.depType {
+			case depStartToStart:
+				n.lf = min(n.lf, e.node.ls+n.duration)
+			case depFinishToFinish:
+				n.lf = min(n.lf, e.node.lf)
+			default:
+				n.lf = min(n.lf, e.node.ls)
+			}
+		}
+		n.ls = n.lf - n.duration
+	}
+
+	for _, n := range ordered {
+		slack := n.ls - n.es
+		sched.Tasks = append(sched.Tasks, TaskSchedule{
+			TaskID:         n.task.ID,
+			Title:          n.task.Title,
+			DurationDays:   n.duration,
+			EarliestStart:  date(n.es),
+			EarliestFinish: date(n.ef - 1),
+			LatestStart:    date(n.ls),
+			LatestFinish:   date(n.lf - 1),
+			SlackDays:      slack,
+			Critical:       slack == 0,
+		})
+	}
+
+	critical := make([]*scheduleNode, 0)
+	for _, n := range ordered {
+		if n.ls == n.es {
+			critical = append(critical, n)
+		}
+	}
+	sort.SliceStable(critical, func(i, j int) bool {
+		return critical[i].es < critical[j].es
+	})
+	for _, n := range critical {
+		sched.CriticalPath = append(sched.CriticalPath, n.task.ID)
+	}
+
+	sched.Start = date(0)
+	sched.PlannedEnd = date(plannedEnd - 1)
+	sched.EarliestEnd = date(end - 1)
+	return sched, nil
+}
+
+// topologicalOrder sorts nodes so every predecessor comes before its
+// successors, keeping chart order where dependencies allow
+func topologicalOrder(nodes []*scheduleNode) []*scheduleNode {
+	pending := make(map[*scheduleNode]int, len(nodes))
+	for _, n := range nodes {
+		pending[n] = len(n.preds)
+	}
+
+	var ready, order []*scheduleNode
+	for _, n := range nodes {
+		if pending[n] == 0 {
+			ready = append(ready, n)
+		}
+	}
+	for len(ready) > 0 {
+		sort.Slice(ready, func(i, j int) bool { return ready[i].order < ready[j].order })
+		n := ready[0]
+		ready = ready[1:]
+		order = append(order, n)
+		for _, e := range n.succs {
+			pending[e.node]--
+			if pending[e.node] == 0 {
+				ready = append(ready, e.node)
+			}
+		}
+	}
+	return order
+}
+
+// criticalTasks returns the IDs of the tasks on the chart's critical path
+func criticalTasks(chart *Chart) (map[string]bool, error) {
+	sched, err := computeSchedule(chart)
+	if err != nil {
+		return nil, err
+	}
+	critical := make(map[string]bool, len(sched.CriticalPath))
+	for _, id := range sched.CriticalPath {
+		critical[id] = true
+	}
+	return critical, nil
+}
+
+func scheduleHandler(w http.ResponseWriter, r *http.Request) {
+	storeMux.RLock()
+	chart := store.Get(mux.Vars(r)["id"])
+	storeMux.RUnlock()
+
+	if chart == nil {
+		http.Error(w, chartNotFoundMsg, http.StatusNotFound)
+		return
+	}
+
+	sched, err := computeSchedule(chart)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Cannot schedule chart: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	w.Header().Set(etagHeader, chartETag(chart))
+	json.NewEncoder(w).Encode(sched)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestComputeScheduleDiamond(t *testing.T) {
+	// design fans out to build and docs, which both feed release; build is
+	// planned to overlap design and so gets pushed back
+	chart := &Chart{
+		StartYear: 2025, StartQ: 1, EndYear: 2025, EndQ: 4,
+		Categories: []Category{
+			{ID: "eng", Name: "Engineering", Tasks: []Task{
+				{ID: "design", Title: "Design", StartDate: "2025-01-01", EndDate: "2025-01-10"},
+				{ID: "build", Title: "Build", StartDate: "2025-01-05", EndDate: "2025-01-14",
+					Dependencies: []Dependency{{TaskID: "design"}}},
+				{ID: "docs", Title: "Docs", StartDate: "2025-01-11", EndDate: "2025-01-13",
+					Dependencies: []Dependency{{TaskID: "design"}}},
+			}},
+			{ID: "ops", Name: "Operations", Tasks: []Task{
+				{ID: "release", Title: "Release", StartDate: "2025-01-21", EndDate: "2025-01-25",
+					Dependencies: []Dependency{{TaskID: "build"}, {TaskID: "docs"}, {TaskID: "announce"}}},
+				{ID: "announce", Title: "Announce"},
+			}},
+		},
+	}
+
+	sched, err := computeSchedule(chart)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		id            string
+		earliestStart string
+		latestFinish  string
+		slack         int
+		critical      bool
+	}{
+		{"design", "2025-01-01", "2025-01-10", 0, true},
+		{"build", "2025-01-11", "2025-01-20", 0, true},
+		{"docs", "2025-01-11", "2025-01-20", 7, false},
+		{"release", "2025-01-21", "2025-01-25", 0, true},
+	}
+	if len(sched.Tasks) != len(tests) {
+		t.Fatalf("scheduled %d tasks, want %d", len(sched.Tasks), len(tests))
+	}
+	for i, test := range tests {
+		got := sched.Tasks[i]
+		if got.TaskID != test.id || got.EarliestStart != test.earliestStart || got.LatestFinish != test.latestFinish ||
+			got.SlackDays != test.slack || got.Critical != test.critical {
+			t.Errorf("task %d = %+v, want %+v", i, got, test)
+		}
+	}
+
+	if want := []string{"design", "build", "release"}; !reflect.DeepEqual(sched.CriticalPath, want) {
+		t.Errorf("critical path = %v, want %v", sched.CriticalPath, want)
+	}
+	if want := []string{"announce"}; !reflect.DeepEqual(sched.Unscheduled, want) {
+		t.Errorf("unscheduled = %v, want %v", sched.Unscheduled, want)
+	}
+	if sched.EarliestEnd != "2025-01-25" {
+		t.Errorf("earliest end = %s, want 2025-01-25", sched.EarliestEnd)
+	}
+}
@@ -0,0 +1,812 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteMigrations holds the schema history. Entry i upgrades the
+// database to version i+1; append new entries, never edit old ones.
+var sqliteMigrations = []string{
+	// 1: initial schema
+	`CREATE TABLE charts (
+		id            TEXT PRIMARY KEY,
+		title         TEXT NOT NULL,
+		start_year    INTEGER NOT NULL,
+		start_quarter INTEGER NOT NULL,
+		end_year      INTEGER NOT NULL,
+		end_quarter   INTEGER NOT NULL,
+		created_at    TEXT NOT NULL,
+		updated_at    TEXT NOT NULL
+	);
+	CREATE TABLE categories (
+		id       TEXT NOT NULL,
+		chart_id TEXT NOT NULL REFERENCES charts(id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		name     TEXT NOT NULL,
+		color    TEXT NOT NULL,
+		PRIMARY KEY (chart_id, id)
+	);
+	CREATE TABLE tasks (
+		id            TEXT NOT NULL,
+		chart_id      TEXT NOT NULL,
+		category_id   TEXT NOT NULL,
+		position      INTEGER NOT NULL,
+		title         TEXT NOT NULL,
+		description   TEXT NOT NULL,
+		start_year    INTEGER NOT NULL,
+		start_quarter INTEGER NOT NULL,
+		end_year      INTEGER NOT NULL,
+		end_quarter   INTEGER NOT NULL,
+		color         TEXT NOT NULL,
+		PRIMARY KEY (chart_id, category_id, id),
+		FOREIGN KEY (chart_id, category_id) REFERENCES categories(chart_id, id) ON DELETE CASCADE
+	);`,
+	// 2: revision history; rows outlive the chart row, which is rewritten on every save
+	`CREATE TABLE revisions (
+		chart_id   TEXT NOT NULL,
+		number     INTEGER NOT NULL,
+		author     TEXT NOT NULL,
+		message    TEXT NOT NULL,
+		created_at TEXT NOT NULL,
+		snapshot   TEXT NOT NULL,
+		PRIMARY KEY (chart_id, number)
+	);`,
+	// 3: optimistic concurrency version
+	`ALTER TABLE charts ADD COLUMN version INTEGER NOT NULL DEFAULT 0;`,
+	// 4: timeline granularity and task calendar dates
+	`ALTER TABLE charts ADD COLUMN granularity TEXT NOT NULL DEFAULT '';
+	ALTER TABLE tasks ADD COLUMN start_date TEXT NOT NULL DEFAULT '';
+	ALTER TABLE tasks ADD COLUMN end_date TEXT NOT NULL DEFAULT '';`,
+	// 5: task dependencies, stored as a JSON array
+	`ALTER TABLE tasks ADD COLUMN dependencies TEXT NOT NULL DEFAULT '';`,
+	// 6: milestones; category_id is empty for chart-level milestones
+	`CREATE TABLE milestones (
+		id          TEXT NOT NULL,
+		chart_id    TEXT NOT NULL REFERENCES charts(id) ON DELETE CASCADE,
+		position    INTEGER NOT NULL,
+		title       TEXT NOT NULL,
+		description TEXT NOT NULL,
+		date        TEXT NOT NULL,
+		category_id TEXT NOT NULL,
+		color       TEXT NOT NULL,
+		PRIMARY KEY (chart_id, id)
+	);`,
+	// 7: task progress
+	`ALTER TABLE tasks ADD COLUMN progress INTEGER NOT NULL DEFAULT 0;`,
+	// 8: task status
+	`ALTER TABLE tasks ADD COLUMN status TEXT NOT NULL DEFAULT '';`,
+	// 9: people directory, task assignees (a JSON array of person IDs) and owners
+	`CREATE TABLE people (
+		id       TEXT PRIMARY KEY,
+		name     TEXT NOT NULL,
+		email    TEXT NOT NULL,
+		team     TEXT NOT NULL,
+		initials TEXT NOT NULL
+	);
+	ALTER TABLE charts ADD COLUMN owner TEXT NOT NULL DEFAULT '';
+	ALTER TABLE categories ADD COLUMN owner TEXT NOT NULL DEFAULT '';
+	ALTER TABLE tasks ADD COLUMN assignees TEXT NOT NULL DEFAULT '';`,
+	// 10: subtasks; parent_id is empty for top-level tasks, and position
+	// orders tasks among their siblings
+	`ALTER TABLE tasks ADD COLUMN parent_id TEXT NOT NULL DEFAULT '';
+	ALTER TABLE tasks ADD COLUMN collapsed INTEGER NOT NULL DEFAULT 0;`,
+	// 11: baselines, stored as a JSON array since they are only ever
+	// read and written whole
+	`ALTER TABLE charts ADD COLUMN baselines TEXT NOT NULL DEFAULT '';`,
+	// 12: custom field definitions and task field values, as JSON
+	`ALTER TABLE charts ADD COLUMN fields TEXT NOT NULL DEFAULT '';
+	ALTER TABLE tasks ADD COLUMN fields TEXT NOT NULL DEFAULT '';`,
+	// 13: category and task tags, as JSON arrays
+	`ALTER TABLE categories ADD COLUMN tags TEXT NOT NULL DEFAULT '';
+	ALTER TABLE tasks ADD COLUMN tags TEXT NOT NULL DEFAULT '';`,
+	// 14: fiscal calendar; a start month of 0 means calendar quarters
+	`ALTER TABLE charts ADD COLUMN fiscal_start_month INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE charts ADD COLUMN fiscal_label TEXT NOT NULL DEFAULT '';
+	ALTER TABLE charts ADD COLUMN fiscal_named_by TEXT NOT NULL DEFAULT '';`,
+}
+
+// SQLiteStore is a ChartStore backed by an embedded SQLite database.
+// Charts are cached in memory; Save writes every chart touched since
+// the previous Save, and any new revisions, in a single transaction.
+// Revisions are read from the database on demand. The people directory
+// is cached and saved the same way as charts.
+type SQLiteStore struct {
+	db          *sql.DB
+	charts      map[string]*Chart
+	saved       map[string]*Chart
+	dirty       map[string]bool
+	pending     []*Revision
+	people      map[string]*Person
+	savedPeople map[string]*Person
+	dirtyPeople map[string]bool
+}
+
+// NewSQLiteStore opens (or creates) the database at path and brings its
+// schema up to date
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	// A single connection keeps pragmas and transactions consistent
+	db.SetMaxOpenConns(1)
+
+	if err := migrateSQLite(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteStore{
+		db:          db,
+		charts:      make(map[string]*Chart),
+		saved:       make(map[string]*Chart),
+		dirty:       make(map[string]bool),
+		people:      make(map[string]*Person),
+		savedPeople: make(map[string]*Person),
+		dirtyPeople: make(map[string]bool),
+	}, nil
+}
+
+func migrateSQLite(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TEXT NOT NULL
+	)`); err != nil {
+		return err
+	}
+
+	var current int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return err
+	}
+	if current > len(sqliteMigrations) {
+		return fmt.Errorf("database schema version %d is newer than this binary supports (%d)", current, len(sqliteMigrations))
+	}
+
+	for version := current + 1; version <= len(sqliteMigrations); version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(sqliteMigrations[version-1]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", version, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`,
+			version, time.Now().UTC().Format(time.RFC3339Nano)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("migration %d: %w", version, err)
+		}
+		logInfof("Applied database migration %d", version)
+	}
+
+	return nil
+}
+
+// Close releases the underlying database
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// Add adds a new chart to the store
+func (s *SQLiteStore) Add(chart *Chart) {
+	normalizeChart(chart)
+	now := time.Now()
+	chart.CreatedAt = now
+	chart.UpdatedAt = now
+	chart.Version = 1
+
+	s.charts[chart.ID] = chart
+	s.dirty[chart.ID] = true
+}
+
+// Get retrieves a chart by ID
+func (s *SQLiteStore) Get(id string) *Chart {
+	return s.charts[id]
+}
+
+// GetAll returns all charts
+func (s *SQLiteStore) GetAll() []*Chart {
+	charts := make([]*Chart, 0, len(s.charts))
+	for _, chart := range s.charts {
+		charts = append(charts, chart)
+	}
+	return charts
+}
+
+// Update updates an existing chart
+func (s *SQLiteStore) Update(chart *Chart) {
+	chart.Version = 1
+	if existing := s.charts[chart.ID]; existing != nil {
+		chart.CreatedAt = existing.CreatedAt
+		chart.Version = existing.Version + 1
+	}
+	chart.UpdatedAt = time.Now()
+	normalizeChart(chart)
+
+	s.charts[chart.ID] = chart
+	s.dirty[chart.ID] = true
+}
+
+// Delete removes a chart and its revisions
+func (s *SQLiteStore) Delete(id string) {
+	delete(s.charts, id)
+	s.dirty[id] = true
+
+	pending := s.pending[:0:0]
+	for _, rev := range s.pending {
+		if rev.ChartID != id {
+			pending = append(pending, rev)
+		}
+	}
+	s.pending = pending
+}
+
+// AddRevision records a snapshot of chart as its next revision
+func (s *SQLiteStore) AddRevision(chart *Chart, author, message string) *Revision {
+	rev := newRevision(chart, s.lastRevision(chart.ID)+1, author, message)
+	s.pending = append(s.pending, rev)
+	return rev
+}
+
+// lastRevision returns the number of a chart's latest revision, counting
+// those not yet saved, or 0 if it has none
+func (s *SQLiteStore) lastRevision(chartID string) int {
+	last := 0
+	if !s.dirty[chartID] || s.charts[chartID] != nil {
+		if err := s.db.QueryRow(`SELECT COALESCE(MAX(number), 0) FROM revisions WHERE chart_id = ?`, chartID).Scan(&last); err != nil {
+			logErrorf("Error reading revisions of chart %s: %v", chartID, err)
+		}
+	}
+	for _, rev := range s.pending {
+		if rev.ChartID == chartID && rev.Number > last {
+			last = rev.Number
+		}
+	}
+	return last
+}
+
+// Revisions lists the revisions of a chart, oldest first
+func (s *SQLiteStore) Revisions(chartID string) []*Revision {
+	if s.dirty[chartID] && s.charts[chartID] == nil {
+		return nil // deleted, not yet saved
+	}
+
+	var revs []*Revision
+	rows, err := s.db.Query(`SELECT number, author, message, created_at, snapshot FROM revisions
+		WHERE chart_id = ? ORDER BY number`, chartID)
+	if err != nil {
+		logErrorf("Error reading revisions of chart %s: %v", chartID, err)
+		return nil
+	}
+	defer rows.Close()
+	for rows.Next() {
+		rev, err := scanRevision(rows, chartID)
+		if err != nil {
+			logErrorf("Error reading revisions of chart %s: %v", chartID, err)
+			return nil
+		}
+		revs = append(revs, rev)
+	}
+
+	for _, rev := range s.pending {
+		if rev.ChartID == chartID {
+			revs = append(revs, rev)
+		}
+	}
+	return revs
+}
+
+// Revision returns a single revision of a chart
+func (s *SQLiteStore) Revision(chartID string, number int) *Revision {
+	if s.dirty[chartID] && s.charts[chartID] == nil {
+		return nil // deleted, not yet saved
+	}
+	for _, rev := range s.pending {
+		if rev.ChartID == chartID && rev.Number == number {
+			return rev
+		}
+	}
+
+	row := s.db.QueryRow(`SELECT number, author, message, created_at, snapshot FROM revisions
+		WHERE chart_id = ? AND number = ?`, chartID, number)
+	rev, err := scanRevision(row, chartID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			logErrorf("Error reading revision %d of chart %s: %v", number, chartID, err)
+		}
+		return nil
+	}
+	return rev
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanRevision decodes a revisions row selected as number, author,
+// message, created_at, snapshot
+func scanRevision(row rowScanner, chartID string) (*Revision, error) {
+	rev := &Revision{ChartID: chartID}
+	var createdAt, snapshot string
+	if err := row.Scan(&rev.Number, &rev.Author, &rev.Message, &createdAt, &snapshot); err != nil {
+		return nil, err
+	}
+	rev.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAt)
+	if err := json.Unmarshal([]byte(snapshot), &rev.Chart); err != nil {
+		return nil, fmt.Errorf("decoding revision %d: %w", rev.Number, err)
+	}
+	return rev, nil
+}
+
+// People lists the people directory, sorted by name
+func (s *SQLiteStore) People() []*Person {
+	people := make([]*Person, 0, len(s.people))
+	for _, person := range s.people {
+		people = append(people, person)
+	}
+	return sortPeople(people)
+}
+
+// Person retrieves a person by ID
+func (s *SQLiteStore) Person(id string) *Person {
+	return s.people[id]
+}
+
+// PutPerson adds or replaces a person
+func (s *SQLiteStore) PutPerson(person *Person) {
+	normalizePerson(person)
+	s.people[person.ID] = person
+	s.dirtyPeople[person.ID] = true
+}
+
+// DeletePerson removes a person from the directory
+func (s *SQLiteStore) DeletePerson(id string) {
+	delete(s.people, id)
+	s.dirtyPeople[id] = true
+}
+
+// Save writes every chart and person changed since the last Save in one
+// transaction
+func (s *SQLiteStore) Save() error {
+	if len(s.dirty) == 0 && len(s.pending) == 0 && len(s.dirtyPeople) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	for id := range s.dirtyPeople {
+		if err := writePersonTx(tx, id, s.people[id]); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	for id := range s.dirty {
+		if err := writeChartTx(tx, id, s.charts[id]); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	for _, rev := range s.pending {
+		if err := writeRevisionTx(tx, rev); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for id := range s.dirty {
+		if chart := s.charts[id]; chart != nil {
+			s.saved[id] = chart
+		} else {
+			delete(s.saved, id)
+		}
+	}
+	for id := range s.dirtyPeople {
+		if person := s.people[id]; person != nil {
+			s.savedPeople[id] = person
+		} else {
+			delete(s.savedPeople, id)
+		}
+	}
+	s.dirty = make(map[string]bool)
+	s.pending = nil
+	s.dirtyPeople = make(map[string]bool)
+	return nil
+}
+
+// Rollback restores the cache to what was last written to the database
+func (s *SQLiteStore) Rollback() {
+	for id := range s.dirty {
+		if chart := s.saved[id]; chart != nil {
+			s.charts[id] = chart
+		} else {
+			delete(s.charts, id)
+		}
+	}
+	for id := range s.dirtyPeople {
+		if person := s.savedPeople[id]; person != nil {
+			s.people[id] = person
+		} else {
+			delete(s.people, id)
+		}
+	}
+	s.dirty = make(map[string]bool)
+	s.pending = nil
+	s.dirtyPeople = make(map[string]bool)
+}
+
+// writePersonTx replaces the stored row for a person; a nil person
+// deletes it
+func writePersonTx(tx *sql.Tx, id string, person *Person) error {
+	if _, err := tx.Exec(`DELETE FROM people WHERE id = ?`, id); err != nil {
+		return err
+	}
+	if person == nil {
+		return nil
+	}
+	_, err := tx.Exec(`INSERT INTO people (id, name, email, team, initials) VALUES (?, ?, ?, ?, ?)`,
+		person.ID, person.Name, person.Email, person.Team, person.Initials)
+	return err
+}
+
+// writeChartTx replaces the stored rows for a chart; a nil chart deletes
+// it together with its revisions
+func writeChartTx(tx *sql.Tx, id string, chart *Chart) error {
+	if _, err := tx.Exec(`DELETE FROM charts WHERE id = ?`, id); err != nil {
+		return err
+	}
+	if chart == nil {
+		_, err := tx.Exec(`DELETE FROM revisions WHERE chart_id = ?`, id)
+		return err
+	}
+
+	baselines, err := jsonColumn(chart.Baselines)
+	if err != nil {
+		return err
+	}
+	fields, err := jsonColumn(chart.Fields)
+	if err != nil {
+		return err
+	}
+	var fiscal FiscalCalendar
+	if chart.Fiscal != nil {
+		fiscal = *chart.Fiscal
+	}
+	if _, err := tx.Exec(`INSERT INTO charts (id, title, start_year, start_quarter, end_year, end_quarter, granularity,
+		fiscal_start_month, fiscal_label, fiscal_named_by, owner, baselines, fields, version, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		chart.ID, chart.Title, chart.StartYear, chart.StartQ, chart.EndYear, chart.EndQ, chart.Granularity,
+		fiscal.StartMonth, fiscal.Label, fiscal.NamedBy, chart.Owner, baselines, fields, chart.Version,
+		chart.CreatedAt.Format(time.RFC3339Nano), chart.UpdatedAt.Format(time.RFC3339Nano)); err != nil {
+		return err
+	}
+
+	for catPos, cat := range chart.Categories {
+		tags, err := jsonColumn(cat.Tags)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT INTO categories (id, chart_id, position, name, color, owner, tags) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			cat.ID, chart.ID, catPos, cat.Name, cat.Color, cat.Owner, tags); err != nil {
+			return err
+		}
+		if err := writeTasksTx(tx, chart.ID, cat.ID, "", cat.Tasks); err != nil {
+			return err
+		}
+	}
+
+	for pos, m := range chart.Milestones {
+		if _, err := tx.Exec(`INSERT INTO milestones (id, chart_id, position, title, description, date, category_id, color)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			m.ID, chart.ID, pos, m.Title, m.Description, m.Date, m.CategoryID, m.Color); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeTasksTx inserts a list of sibling tasks and, recursively, their
+// subtasks
+func writeTasksTx(tx *sql.Tx, chartID, catID, parentID string, tasks []Task) error {
+	for pos, task := range tasks {
+		deps, err := jsonColumn(task.Dependencies)
+		if err != nil {
+			return err
+		}
+		assignees, err := jsonColumn(task.Assignees)
+		if err != nil {
+			return err
+		}
+		fields, err := jsonColumn(task.Fields)
+		if err != nil {
+			return err
+		}
+		tags, err := jsonColumn(task.Tags)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT INTO tasks (id, chart_id, category_id, parent_id, position, title, description,
+			start_year, start_quarter, end_year, end_quarter, start_date, end_date, color, progress, status, assignees, dependencies, collapsed, fields, tags)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			task.ID, chartID, catID, parentID, pos, task.Title, task.Description,
+			task.StartYear, task.StartQ, task.EndYear, task.EndQ, task.StartDate, task.EndDate, task.Color, task.Progress, task.Status, assignees, deps, task.Collapsed, fields, tags); err != nil {
+			return err
+		}
+		if err := writeTasksTx(tx, chartID, catID, task.ID, task.Subtasks); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonColumn encodes a list- or map-valued field for a TEXT column; empty
+// values are stored as the empty string
+func jsonColumn(v interface{}) (string, error) {
+	if reflect.ValueOf(v).Len() == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+// scanJSONColumn decodes a column written by jsonColumn
+func scanJSONColumn(s string, v interface{}) error {
+	if s == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(s), v)
+}
+
+func writeRevisionTx(tx *sql.Tx, rev *Revision) error {
+	snapshot, err := json.Marshal(rev.Chart)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO revisions (chart_id, number, author, message, created_at, snapshot)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		rev.ChartID, rev.Number, rev.Author, rev.Message, rev.CreatedAt.Format(time.RFC3339Nano), string(snapshot))
+	return err
+}
+
+// Load reads every chart and person from the database into memory
+func (s *SQLiteStore) Load() error {
+	people := make(map[string]*Person)
+	rows, err := s.db.Query(`SELECT id, name, email, team, initials FROM people`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var person Person
+		if err := rows.Scan(&person.ID, &person.Name, &person.Email, &person.Team, &person.Initials); err != nil {
+			rows.Close()
+			return err
+		}
+		people[person.ID] = &person
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	charts := make(map[string]*Chart)
+
+	rows, err = s.db.Query(`SELECT id, title, start_year, start_quarter, end_year, end_quarter, granularity,
+		fiscal_start_month, fiscal_label, fiscal_named_by, owner, baselines, fields, version, created_at, updated_at FROM charts`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var chart Chart
+		var fiscal FiscalCalendar
+		var baselines, fields, createdAt, updatedAt string
+		if err := rows.Scan(&chart.ID, &chart.Title, &chart.StartYear, &chart.StartQ, &chart.EndYear, &chart.EndQ, &chart.Granularity,
+			&fiscal.StartMonth, &fiscal.Label, &fiscal.NamedBy, &chart.Owner, &baselines, &fields, &chart.Version, &createdAt, &updatedAt); err != nil {
+			rows.Close()
+			return err
+		}
+		if fiscal.StartMonth != 0 {
+			chart.Fiscal = &fiscal
+		}
+		if err := scanJSONColumn(baselines, &chart.Baselines); err != nil {
+			rows.Close()
+			return fmt.Errorf("chart %s baselines: %w", chart.ID, err)
+		}
+		if err := scanJSONColumn(fields, &chart.Fields); err != nil {
+			rows.Close()
+			return fmt.Errorf("chart %s fields: %w", chart.ID, err)
+		}
+		chart.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAt)
+		chart.UpdatedAt, _ = time.Parse(time.RFC3339Nano, updatedAt)
+		chart.Categories = []Category{}
+		charts[chart.ID] = &chart
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	catIndex := make(map[string]map[string]int)
+	rows, err = s.db.Query(`SELECT chart_id, id, name, color, owner, tags FROM categories ORDER BY chart_id, position`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var chartID, tags string
+		cat := Category{Tasks: []Task{}}
+		if err := rows.Scan(&chartID, &cat.ID, &cat.Name, &cat.Color, &cat.Owner, &tags); err != nil {
+			rows.Close()
+			return err
+		}
+		if err := scanJSONColumn(tags, &cat.Tags); err != nil {
+			rows.Close()
+			return fmt.Errorf("category %s tags: %w", cat.ID, err)
+		}
+		chart := charts[chartID]
+		if chart == nil {
+			continue
+		}
+		if catIndex[chartID] == nil {
+			catIndex[chartID] = make(map[string]int)
+		}
+		catIndex[chartID][cat.ID] = len(chart.Categories)
+		chart.Categories = append(chart.Categories, cat)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// Tasks are gathered under their parent and assembled into trees once
+	// every row is read
+	type taskParent struct{ chartID, catID, parentID string }
+	children := make(map[taskParent][]Task)
+	rows, err = s.db.Query(`SELECT chart_id, category_id, parent_id, id, title, description, start_year, start_quarter, end_year, end_quarter,
+		start_date, end_date, color, progress, status, assignees, dependencies, collapsed, fields, tags
+		FROM tasks ORDER BY chart_id, category_id, parent_id, position`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var chartID, catID, parentID, assignees, deps, fields, tags string
+		var task Task
+		if err := rows.Scan(&chartID, &catID, &parentID, &task.ID, &task.Title, &task.Description,
+			&task.StartYear, &task.StartQ, &task.EndYear, &task.EndQ, &task.StartDate, &task.EndDate, &task.Color, &task.Progress, &task.Status, &assignees, &deps, &task.Collapsed, &fields, &tags); err != nil {
+			rows.Close()
+			return err
+		}
+		if err := scanJSONColumn(assignees, &task.Assignees); err != nil {
+			rows.Close()
+			return fmt.Errorf("task %s assignees: %w", task.ID, err)
+		}
+		if err := scanJSONColumn(deps, &task.Dependencies); err != nil {
+			rows.Close()
+			return fmt.Errorf("task %s dependencies: %w", task.ID, err)
+		}
+		if err := scanJSONColumn(fields, &task.Fields); err != nil {
+			rows.Close()
+			return fmt.Errorf("task %s fields: %w", task.ID, err)
+		}
+		if err := scanJSONColumn(tags, &task.Tags); err != nil {
+			rows.Close()
+			return fmt.Errorf("task %s tags: %w", task.ID, err)
+		}
+		key := taskParent{chartID, catID, parentID}
+		children[key] = append(children[key], task)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	var subtasks func(key taskParent) []Task
+	subtasks = func(key taskParent) []Task {
+		tasks := children[key]
+		for i := range tasks {
+			tasks[i].Subtasks = subtasks(taskParent{key.chartID, key.catID, tasks[i].ID})
+		}
+		return tasks
+	}
+	for chartID, chart := range charts {
+		for i := range chart.Categories {
+			if tasks := subtasks(taskParent{chartID, chart.Categories[i].ID, ""}); tasks != nil {
+				chart.Categories[i].Tasks = tasks
+			}
+		}
+	}
+
+	rows, err = s.db.Query(`SELECT chart_id, id, title, description, date, category_id, color
+		FROM milestones ORDER BY chart_id, position`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var chartID string
+		var m Milestone
+		if err := rows.Scan(&chartID, &m.ID, &m.Title, &m.Description, &m.Date, &m.CategoryID, &m.Color); err != nil {
+			rows.Close()
+			return err
+		}
+		if chart := charts[chartID]; chart != nil {
+			chart.Milestones = append(chart.Milestones, m)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	s.charts = charts
+	s.saved = cloneChartMap(charts)
+	s.dirty = make(map[string]bool)
+	s.pending = nil
+	s.people = people
+	s.savedPeople = clonePersonMap(people)
+	s.dirtyPeople = make(map[string]bool)
+	return nil
+}
+
+// ImportJSONFile copies the charts from a charts.json written by
+// JSONFileStore into the database, keeping their IDs, timestamps and
+// revision history, together with the people directory they refer to.
+// Charts and people that already exist in the database are left
+// untouched.
+func (s *SQLiteStore) ImportJSONFile(filename string) (int, error) {
+	if _, err := os.Stat(filename); err != nil {
+		return 0, err
+	}
+	src := NewJSONFileStore(filename)
+	if err := src.Load(); err != nil {
+		return 0, fmt.Errorf("reading %s: %w", filename, err)
+	}
+
+	// Save writes people before charts in the same transaction, so
+	// assignees and owners resolve as soon as the charts exist
+	for id, person := range src.people {
+		if s.people[id] == nil {
+			s.PutPerson(person)
+		}
+	}
+
+	imported := 0
+	for id, chart := range src.charts {
+		if chart == nil || s.charts[id] != nil {
+			continue
+		}
+		chart.ID = id
+		normalizeChart(chart)
+		if chart.CreatedAt.IsZero() {
+			chart.CreatedAt = time.Now()
+		}
+		if chart.UpdatedAt.IsZero() {
+			chart.UpdatedAt = chart.CreatedAt
+		}
+		s.charts[id] = chart
+		s.dirty[id] = true
+		s.pending = append(s.pending, src.Revisions(id)...)
+		s.AddRevision(chart, "", "Imported from "+filepath.Base(filename))
+		imported++
+	}
+
+	if err := s.Save(); err != nil {
+		s.Rollback()
+		return 0, err
+	}
+	return imported, nil
+}
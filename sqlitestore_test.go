@@ -0,0 +1,60 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestImportJSONFile(t *testing.T) {
+	dir := t.TempDir()
+	src := NewJSONFileStore(filepath.Join(dir, "charts.json"))
+	src.PutPerson(&Person{ID: "ada", Name: "Ada Lovelace"})
+	chart := &Chart{ID: "plan", Title: "Plan", Owner: "ada", StartYear: 2025, StartQ: 1, EndYear: 2025, EndQ: 4}
+	src.Add(chart)
+	src.AddRevision(chart, "ada", "")
+	edited := cloneChart(chart)
+	edited.Title = "Plan v2"
+	src.Update(edited)
+	src.AddRevision(edited, "ada", "Renamed")
+	if err := src.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	dst, err := NewSQLiteStore(filepath.Join(dir, "charts.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	if err := dst.Load(); err != nil {
+		t.Fatal(err)
+	}
+	n, err := dst.ImportJSONFile(src.filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("imported %d charts, want 1", n)
+	}
+
+	if dst.Person("ada") == nil {
+		t.Error("people directory was not imported")
+	}
+	store = dst
+	if err := validateAssignees(dst.Get("plan")); err != nil {
+		t.Errorf("imported chart does not validate: %v", err)
+	}
+
+	revs := dst.Revisions("plan")
+	if len(revs) != 3 {
+		t.Fatalf("got %d revisions, want the 2 imported and the import itself", len(revs))
+	}
+	if rev := dst.Revision("plan", 2); rev == nil || rev.Message != "Renamed" || rev.Chart.Title != "Plan v2" {
+		t.Errorf("revision 2 = %+v, want the rename", rev)
+	}
+	if rev := dst.Revision("plan", 4); rev != nil {
+		t.Errorf("revision 4 = %+v, want none", rev)
+	}
+	if rev := dst.AddRevision(dst.Get("plan"), "", ""); rev.Number != 4 {
+		t.Errorf("next revision is number %d, want 4", rev.Number)
+	}
+}
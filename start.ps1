@@ -57,7 +57,7 @@ switch ($choice) {
                 New-Item -ItemType Directory -Path ".\data" | Out-Null
             }
             
-            docker run -d -p 8080:8080 -v "${PWD}/data:/root" --name go-ghant-app go-ghant
+            docker run -d -p 8080:8080 -v "${PWD}/data:/data" --name go-ghant-app go-ghant
             
             if ($LASTEXITCODE -eq 0) {
                 Write-Host "✓ Container started successfully" -ForegroundColor Green
@@ -2,13 +2,23 @@
 let currentChart = null;
 let currentCategoryId = null;
 let currentTaskId = null;
+let currentParentTaskId = null; // parent of a subtask being added
 let editingCategory = null;
 let editingTask = null;
+let editingMilestone = null;
+let editingField = null;
+let editingPerson = null;
+let people = []; // the server's people directory
+let loadedChart = { id: null, etag: null }; // server version the editor is based on
+let chartListNext = null; // URL of the next page of the load chart list
+let chartSearchTimer = null;
+const chartPageSize = 50;
 
 // Initialize
 document.addEventListener('DOMContentLoaded', () => {
     setupEventListeners();
     createNewChart();
+    loadPeople();
 });
 
 function setupEventListeners() {
@@ -19,15 +29,20 @@ function setupEventListeners() {
     
     // Export buttons
     document.getElementById('exportSVG').addEventListener('click', () => exportChart('svg'));
+    document.getElementById('freezeBaselineBtn').addEventListener('click', freezeBaseline);
     document.getElementById('exportPNG').addEventListener('click', () => exportChart('png'));
     document.getElementById('exportPDF').addEventListener('click', () => exportChart('pdf'));
     
     // Chart settings
     document.getElementById('chartTitle').addEventListener('input', updateChartSettings);
+    document.getElementById('chartOwner').addEventListener('change', updateChartSettings);
     document.getElementById('startYear').addEventListener('change', updateChartSettings);
     document.getElementById('startQuarter').addEventListener('change', updateChartSettings);
     document.getElementById('endYear').addEventListener('change', updateChartSettings);
     document.getElementById('endQuarter').addEventListener('change', updateChartSettings);
+    document.getElementById('fiscalStartMonth').addEventListener('change', updateChartSettings);
+    document.getElementById('fiscalLabel').addEventListener('change', updateChartSettings);
+    document.getElementById('fiscalNamedBy').addEventListener('change', updateChartSettings);
     
     // Category modal
     document.getElementById('addCategoryBtn').addEventListener('click', () => openCategoryModal());
@@ -38,9 +53,30 @@ function setupEventListeners() {
     document.getElementById('saveTask').addEventListener('click', saveTask);
     document.getElementById('cancelTask').addEventListener('click', closeTaskModal);
     
+    // Milestone modal
+    document.getElementById('addMilestoneBtn').addEventListener('click', () => openMilestoneModal());
+    document.getElementById('saveMilestone').addEventListener('click', saveMilestone);
+    document.getElementById('cancelMilestone').addEventListener('click', closeMilestoneModal);
+    
+    // Field modal
+    document.getElementById('addFieldBtn').addEventListener('click', () => openFieldModal());
+    document.getElementById('saveField').addEventListener('click', saveField);
+    document.getElementById('cancelField').addEventListener('click', closeFieldModal);
+    document.getElementById('fieldType').addEventListener('change', updateFieldOptionsInput);
+    
+    // Person modal
+    document.getElementById('addPersonBtn').addEventListener('click', () => openPersonModal());
+    document.getElementById('savePerson').addEventListener('click', savePerson);
+    document.getElementById('cancelPerson').addEventListener('click', closePersonModal);
+    
     // Load chart modal
     document.getElementById('closeLoadChart').addEventListener('click', closeLoadChartModal);
     document.getElementById('cancelLoadChart').addEventListener('click', closeLoadChartModal);
+    document.getElementById('loadMoreCharts').addEventListener('click', () => loadChartPage(chartListNext));
+    document.getElementById('chartSearch').addEventListener('input', () => {
+        clearTimeout(chartSearchTimer);
+        chartSearchTimer = setTimeout(searchCharts, 250);
+    });
     
     // Save chart modal
     document.getElementById('closeSaveChart').addEventListener('click', closeSaveChartModal);
@@ -98,9 +134,18 @@ function updateUI() {
     document.getElementById('startQuarter').value = currentChart.startQuarter;
     document.getElementById('endYear').value = currentChart.endYear;
     document.getElementById('endQuarter').value = currentChart.endQuarter;
+    const fiscal = currentChart.fiscal || {};
+    document.getElementById('fiscalStartMonth').value = fiscal.startMonth || 1;
+    document.getElementById('fiscalLabel').value = fiscal.label || '';
+    document.getElementById('fiscalNamedBy').value = fiscal.namedBy || '';
+    fillPersonOptions(document.getElementById('chartOwner'), currentChart.owner, 'No owner');
+    currentChart.categories.forEach(c => rollupSubtasks(c.tasks));
     
     // Render categories
     renderCategories();
+    renderMilestones();
+    renderFields();
+    renderBaselines();
     
     // Update preview
     updatePreview();
@@ -116,6 +161,16 @@ function escapeHtml(text) {
     return div.innerHTML;
 }
 
+// errorMessage reads a failed response: validation problems list every
+// invalid field, other errors are shown as sent
+async function errorMessage(response, fallback) {
+    if ((response.headers.get('Content-Type') || '').startsWith('application/problem+json')) {
+        const problem = await response.json();
+        return [problem.detail || problem.title, ...(problem.errors || []).map(e => '- ' + e.reason)].join('\n');
+    }
+    return (await response.text()).trim() || fallback;
+}
+
 function renderCategories() {
     const container = document.getElementById('categoriesList');
     container.innerHTML = '';
@@ -132,7 +187,7 @@ function renderCategories() {
             <div class="category-header">
                 <div class="category-title">
                     <div class="category-color" style="background: ${category.color}"></div>
-                    <span>${escapeHtml(category.name)}</span>
+                    <span>${escapeHtml(category.name)}</span>${tagList(category.tags)}
                 </div>
                 <div class="category-actions">
                     <button class="btn btn-small btn-primary" onclick="openTaskModal('${category.id}')">+ Task</button>
@@ -153,15 +208,17 @@ function renderTasks(category) {
         return '<p style="color: #bdc3c7; font-size: 0.75rem; margin-top: 0.5rem;">No tasks</p>';
     }
     
-    return category.tasks.map(task => `
-        <div class="task-item" draggable="true" ondragstart="dragStart(event, '${category.id}', '${task.id}')" ondragend="dragEnd(event)">
+    return taskRows(category.tasks).map(({ task, depth }) => `
+        <div class="task-item" style="margin-left: ${depth * 16}px" draggable="true" ondragstart="dragStart(event, '${category.id}', '${task.id}')" ondragend="dragEnd(event)">
             <div class="task-header">
                 <div>
-                    <div class="task-title-text">${escapeHtml(task.title)}</div>
-                    <div class="task-timeline">Q${task.startQuarter} ${task.startYear} - Q${task.endQuarter} ${task.endYear}</div>
+                    <div class="task-title-text">${isSummary(task) ? `<strong>${escapeHtml(task.title)}</strong>` : escapeHtml(task.title)}</div>
+                    <div class="task-timeline">${task.startDate || escapeHtml(quarterLabel(task.startYear, task.startQuarter))} - ${task.endDate || escapeHtml(quarterLabel(task.endYear, task.endQuarter))}${task.progress ? ` · ${task.progress}%` : ''}${task.status ? ` · ${task.status}` : ''}${assigneeInitials(task)}${tagList(task.tags)}</div>
                     ${task.description ? `<div class="task-description">${escapeHtml(task.description)}</div>` : ''}
                 </div>
                 <div class="task-actions">
+                    ${isSummary(task) ? `<button class="btn btn-small btn-secondary" onclick="toggleCollapsed('${category.id}', '${task.id}')" title="Exports show only the summary bar of a collapsed task">${task.collapsed ? 'Expand' : 'Collapse'}</button>` : ''}
+                    <button class="btn btn-small btn-primary" onclick="openTaskModal('${category.id}', null, '${task.id}')">+ Sub</button>
                     <button class="btn btn-small btn-secondary" onclick="editTask('${category.id}', '${task.id}')">Edit</button>
                     <button class="btn btn-small btn-danger" onclick="deleteTask('${category.id}', '${task.id}')">Del</button>
                 </div>
@@ -178,7 +235,24 @@ function updateChartSettings() {
     currentChart.startQuarter = parseInt(document.getElementById('startQuarter').value);
     currentChart.endYear = parseInt(document.getElementById('endYear').value);
     currentChart.endQuarter = parseInt(document.getElementById('endQuarter').value);
+    setOptionalField(currentChart, 'owner', document.getElementById('chartOwner').value);
     
+    // A year starting in January with the default label is the calendar year
+    const fiscal = {
+        startMonth: parseInt(document.getElementById('fiscalStartMonth').value),
+        label: document.getElementById('fiscalLabel').value.trim(),
+        namedBy: document.getElementById('fiscalNamedBy').value
+    };
+    if (fiscal.startMonth === 1 && !fiscal.label) {
+        delete currentChart.fiscal;
+    } else {
+        setOptionalField(fiscal, 'label', fiscal.label);
+        setOptionalField(fiscal, 'namedBy', fiscal.namedBy);
+        currentChart.fiscal = fiscal;
+    }
+    
+    // Task quarters are read in the chart's calendar, so redraw the list too
+    renderCategories();
     updatePreview();
 }
 
@@ -217,10 +291,14 @@ function openCategoryModal(categoryId = null) {
         document.getElementById('categoryModalTitle').textContent = 'Edit Category';
         document.getElementById('categoryName').value = category.name;
         document.getElementById('categoryColor').value = category.color;
+        fillPersonOptions(document.getElementById('categoryOwner'), category.owner, 'No owner');
+        document.getElementById('categoryTags').value = (category.tags || []).join(', ');
     } else {
         document.getElementById('categoryModalTitle').textContent = 'Add Category';
         document.getElementById('categoryName').value = '';
         document.getElementById('categoryColor').value = '#6495ed';
+        fillPersonOptions(document.getElementById('categoryOwner'), '', 'No owner');
+        document.getElementById('categoryTags').value = '';
     }
     
     modal.classList.add('active');
@@ -234,6 +312,8 @@ function closeCategoryModal() {
 function saveCategory() {
     const name = document.getElementById('categoryName').value.trim();
     const color = document.getElementById('categoryColor').value;
+    const owner = document.getElementById('categoryOwner').value;
+    const tags = parseTags(document.getElementById('categoryTags').value);
     
     if (!name) {
         alert('Please enter a category name');
@@ -244,13 +324,18 @@ function saveCategory() {
         const category = currentChart.categories.find(c => c.id === editingCategory);
         category.name = name;
         category.color = color;
+        setOptionalField(category, 'owner', owner);
+        setOptionalField(category, 'tags', tags.length ? tags : null);
     } else {
-        currentChart.categories.push({
+        const category = {
             id: generateId(),
             name,
             color,
             tasks: []
-        });
+        };
+        setOptionalField(category, 'owner', owner);
+        setOptionalField(category, 'tags', tags.length ? tags : null);
+        currentChart.categories.push(category);
     }
     
     closeCategoryModal();
@@ -264,19 +349,406 @@ function editCategory(categoryId) {
 function deleteCategory(categoryId) {
     if (!confirm('Delete this category and all its tasks?')) return;
     
+    const category = currentChart.categories.find(c => c.id === categoryId);
+    taskRows(category.tasks).forEach(({ task }) => removeDependenciesOn(task.id));
+    // Keep the category's milestones, at chart level
+    (currentChart.milestones || []).forEach(m => {
+        if (m.categoryId === categoryId) delete m.categoryId;
+    });
     currentChart.categories = currentChart.categories.filter(c => c.id !== categoryId);
     updateUI();
 }
 
+// Milestone management
+function renderMilestones() {
+    const container = document.getElementById('milestonesList');
+    const milestones = currentChart.milestones || [];
+    
+    if (milestones.length === 0) {
+        container.innerHTML = '<p style="color: #95a5a6; font-size: 0.875rem;">No milestones yet</p>';
+        return;
+    }
+    
+    container.innerHTML = milestones.map(m => {
+        const category = currentChart.categories.find(c => c.id === m.categoryId);
+        return `
+        <div class="task-item">
+            <div class="task-header">
+                <div>
+                    <div class="task-title-text">◆ ${escapeHtml(m.title)}</div>
+                    <div class="task-timeline">${m.date}${category ? ` · ${escapeHtml(category.name)}` : ''}</div>
+                </div>
+                <div class="task-actions">
+                    <button class="btn btn-small btn-secondary" onclick="openMilestoneModal('${m.id}')">Edit</button>
+                    <button class="btn btn-small btn-danger" onclick="deleteMilestone('${m.id}')">Del</button>
+                </div>
+            </div>
+        </div>
+    `;
+    }).join('');
+}
+
+function openMilestoneModal(milestoneId = null) {
+    editingMilestone = milestoneId;
+    const milestone = milestoneId ? currentChart.milestones.find(m => m.id === milestoneId) : null;
+    
+    const select = document.getElementById('milestoneCategory');
+    select.innerHTML = '';
+    select.add(new Option('None (chart level)', ''));
+    currentChart.categories.forEach(c => select.add(new Option(c.name, c.id)));
+    
+    document.getElementById('milestoneModalTitle').textContent = milestone ? 'Edit Milestone' : 'Add Milestone';
+    document.getElementById('milestoneTitle').value = milestone ? milestone.title : '';
+    document.getElementById('milestoneDate').value = milestone ? milestone.date : '';
+    select.value = milestone && milestone.categoryId || '';
+    document.getElementById('milestoneColor').value = milestone && milestone.color || '#34495e';
+    
+    document.getElementById('milestoneModal').classList.add('active');
+}
+
+function closeMilestoneModal() {
+    document.getElementById('milestoneModal').classList.remove('active');
+    editingMilestone = null;
+}
+
+function saveMilestone() {
+    const title = document.getElementById('milestoneTitle').value.trim();
+    const date = document.getElementById('milestoneDate').value;
+    const categoryId = document.getElementById('milestoneCategory').value;
+    const color = document.getElementById('milestoneColor').value;
+    
+    if (!title) {
+        alert('Please enter a milestone title');
+        return;
+    }
+    if (!date) {
+        alert('Please pick a milestone date');
+        return;
+    }
+    
+    currentChart.milestones = currentChart.milestones || [];
+    let milestone = currentChart.milestones.find(m => m.id === editingMilestone);
+    if (!milestone) {
+        milestone = { id: generateId() };
+        currentChart.milestones.push(milestone);
+    }
+    milestone.title = title;
+    milestone.date = date;
+    setOptionalField(milestone, 'categoryId', categoryId);
+    milestone.color = color;
+    
+    closeMilestoneModal();
+    updateUI();
+}
+
+function deleteMilestone(milestoneId) {
+    if (!confirm('Delete this milestone?')) return;
+    
+    currentChart.milestones = currentChart.milestones.filter(m => m.id !== milestoneId);
+    updateUI();
+}
+
+// Custom fields
+function renderFields() {
+    const container = document.getElementById('fieldsList');
+    const fields = currentChart.fields || [];
+    
+    const groupBy = document.getElementById('exportGroupBy');
+    const selected = groupBy.value;
+    groupBy.innerHTML = '<option value="">Categories</option>';
+    fields.forEach(f => groupBy.add(new Option(f.name, `field.${f.key}`, false, `field.${f.key}` === selected)));
+    
+    if (fields.length === 0) {
+        container.innerHTML = '<p style="color: #95a5a6; font-size: 0.875rem;">No custom fields yet</p>';
+        return;
+    }
+    
+    container.innerHTML = fields.map(f => `
+        <div class="task-item">
+            <div class="task-header">
+                <div>
+                    <div class="task-title-text">${escapeHtml(f.name)}${f.required ? ' *' : ''}</div>
+                    <div class="task-timeline">${escapeHtml(f.key)} · ${f.type}${f.options ? `: ${escapeHtml(f.options.join(', '))}` : ''}</div>
+                </div>
+                <div class="task-actions">
+                    <button class="btn btn-small btn-secondary" onclick="openFieldModal('${f.key}')">Edit</button>
+                    <button class="btn btn-small btn-danger" onclick="deleteField('${f.key}')">Del</button>
+                </div>
+            </div>
+        </div>
+    `).join('');
+}
+
+function openFieldModal(key = null) {
+    editingField = key;
+    const field = key ? currentChart.fields.find(f => f.key === key) : null;
+    
+    document.getElementById('fieldModalTitle').textContent = field ? 'Edit Field' : 'Add Field';
+    document.getElementById('fieldName').value = field ? field.name : '';
+    document.getElementById('fieldKey').value = field ? field.key : '';
+    // Task values are stored under the key, so it is fixed once created
+    document.getElementById('fieldKey').disabled = !!field;
+    document.getElementById('fieldType').value = field ? field.type : 'text';
+    document.getElementById('fieldOptions').value = field && field.options ? field.options.join(', ') : '';
+    document.getElementById('fieldRequired').checked = !!(field && field.required);
+    updateFieldOptionsInput();
+    
+    document.getElementById('fieldModal').classList.add('active');
+}
+
+function closeFieldModal() {
+    document.getElementById('fieldModal').classList.remove('active');
+    editingField = null;
+}
+
+function updateFieldOptionsInput() {
+    document.getElementById('fieldOptions').disabled = document.getElementById('fieldType').value !== 'enum';
+}
+
+function saveField() {
+    const name = document.getElementById('fieldName').value.trim();
+    const key = document.getElementById('fieldKey').value.trim();
+    const type = document.getElementById('fieldType').value;
+    const options = document.getElementById('fieldOptions').value.split(',').map(o => o.trim()).filter(Boolean);
+    const required = document.getElementById('fieldRequired').checked;
+    
+    if (!name) {
+        alert('Please enter a field name');
+        return;
+    }
+    if (!/^[A-Za-z][A-Za-z0-9_-]*$/.test(key)) {
+        alert('The key must start with a letter and hold only letters, digits, - and _');
+        return;
+    }
+    if (type === 'enum' && options.length === 0) {
+        alert('Please list the choices');
+        return;
+    }
+    
+    currentChart.fields = currentChart.fields || [];
+    let field = currentChart.fields.find(f => f.key === editingField);
+    if (!field) {
+        if (currentChart.fields.some(f => f.key === key)) {
+            alert('A field with this key already exists');
+            return;
+        }
+        field = { key };
+        currentChart.fields.push(field);
+    }
+    field.name = name;
+    field.type = type;
+    setOptionalField(field, 'options', type === 'enum' ? options : null);
+    setOptionalField(field, 'required', required);
+    
+    closeFieldModal();
+    updateUI();
+}
+
+function deleteField(key) {
+    if (!confirm('Delete this field and its value on every task?')) return;
+    
+    currentChart.fields = currentChart.fields.filter(f => f.key !== key);
+    currentChart.categories.forEach(c => taskRows(c.tasks).forEach(({ task }) => {
+        if (!task.fields) return;
+        delete task.fields[key];
+        if (Object.keys(task.fields).length === 0) delete task.fields;
+    }));
+    updateUI();
+}
+
+// fillTaskFields adds an input for each of the chart's custom fields to
+// the task modal
+function fillTaskFields(values) {
+    const container = document.getElementById('taskFields');
+    container.innerHTML = '';
+    (currentChart.fields || []).forEach(f => {
+        const group = document.createElement('div');
+        group.className = 'form-group';
+        const label = document.createElement('label');
+        label.htmlFor = `taskField-${f.key}`;
+        label.textContent = f.required ? f.name : `${f.name} (optional)`;
+        
+        let input;
+        if (f.type === 'enum') {
+            input = document.createElement('select');
+            input.add(new Option('None', ''));
+            f.options.forEach(o => input.add(new Option(o, o)));
+        } else {
+            input = document.createElement('input');
+            input.type = { number: 'number', date: 'date', url: 'url' }[f.type] || 'text';
+        }
+        input.id = `taskField-${f.key}`;
+        input.value = values[f.key] !== undefined ? values[f.key] : '';
+        
+        group.append(label, input);
+        container.appendChild(group);
+    });
+}
+
+// taskFieldValues reads the custom field inputs, leaving out empty ones
+function taskFieldValues() {
+    const values = {};
+    for (const f of currentChart.fields || []) {
+        const value = document.getElementById(`taskField-${f.key}`).value.trim();
+        if (value === '') {
+            if (f.required) throw new Error(`Please fill in ${f.name}`);
+            continue;
+        }
+        if (f.type === 'number') {
+            if (isNaN(Number(value))) throw new Error(`${f.name} must be a number`);
+            values[f.key] = Number(value);
+        } else {
+            values[f.key] = value;
+        }
+    }
+    return values;
+}
+
+// People directory
+async function loadPeople() {
+    try {
+        const response = await fetch('/api/people');
+        if (!response.ok) throw new Error(await response.text());
+        people = await response.json();
+    } catch (error) {
+        console.error('Error loading people:', error);
+        people = [];
+    }
+    renderPeople();
+    fillPersonOptions(document.getElementById('chartOwner'), currentChart && currentChart.owner, 'No owner');
+}
+
+function renderPeople() {
+    const container = document.getElementById('peopleList');
+    
+    const assignee = document.getElementById('exportAssignee');
+    const selected = assignee.value;
+    fillPersonOptions(assignee, '', 'Anyone');
+    assignee.add(new Option('Unassigned', 'none'));
+    assignee.value = selected;
+    if (assignee.selectedIndex < 0) assignee.value = '';
+    
+    if (people.length === 0) {
+        container.innerHTML = '<p style="color: #95a5a6; font-size: 0.875rem;">No people yet</p>';
+        return;
+    }
+    
+    container.innerHTML = people.map(p => `
+        <div class="task-item">
+            <div class="task-header">
+                <div>
+                    <div class="task-title-text">${escapeHtml(p.initials)} · ${escapeHtml(p.name)}</div>
+                    <div class="task-timeline">${escapeHtml([p.team, p.email].filter(Boolean).join(' · '))}</div>
+                </div>
+                <div class="task-actions">
+                    <button class="btn btn-small btn-secondary" onclick="openPersonModal('${p.id}')">Edit</button>
+                    <button class="btn btn-small btn-danger" onclick="deletePerson('${p.id}')">Del</button>
+                </div>
+            </div>
+        </div>
+    `).join('');
+}
+
+function openPersonModal(personId = null) {
+    editingPerson = personId;
+    const person = personId ? people.find(p => p.id === personId) : null;
+    
+    document.getElementById('personModalTitle').textContent = person ? 'Edit Person' : 'Add Person';
+    document.getElementById('personName').value = person ? person.name : '';
+    document.getElementById('personEmail').value = person && person.email || '';
+    document.getElementById('personTeam').value = person && person.team || '';
+    document.getElementById('personInitials').value = person ? person.initials : '';
+    
+    document.getElementById('personModal').classList.add('active');
+}
+
+function closePersonModal() {
+    document.getElementById('personModal').classList.remove('active');
+    editingPerson = null;
+}
+
+// People are saved to the server straight away, unlike chart edits
+async function savePerson() {
+    const person = {
+        name: document.getElementById('personName').value.trim(),
+        email: document.getElementById('personEmail').value.trim(),
+        team: document.getElementById('personTeam').value.trim(),
+        initials: document.getElementById('personInitials').value.trim()
+    };
+    
+    if (!person.name) {
+        alert('Please enter a name');
+        return;
+    }
+    
+    try {
+        const response = await fetch(editingPerson ? `/api/people/${editingPerson}` : '/api/people', {
+            method: editingPerson ? 'PUT' : 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(person)
+        });
+        if (!response.ok) throw new Error(await errorMessage(response, 'Failed to save person'));
+    } catch (error) {
+        alert('Error saving person: ' + error.message);
+        return;
+    }
+    
+    closePersonModal();
+    await loadPeople();
+    updateUI();
+}
+
+async function deletePerson(personId) {
+    if (!confirm('Remove this person from the directory?')) return;
+    
+    try {
+        const response = await fetch(`/api/people/${personId}`, { method: 'DELETE' });
+        if (!response.ok) throw new Error(await response.text());
+    } catch (error) {
+        alert('Error deleting person: ' + error.message);
+        return;
+    }
+    
+    await loadPeople();
+    updateUI();
+}
+
+// fillPersonOptions lists the directory in a select, with an empty choice
+function fillPersonOptions(select, selected, noneLabel) {
+    select.innerHTML = '';
+    select.add(new Option(noneLabel, ''));
+    people.forEach(p => select.add(new Option(`${p.name} (${p.initials})`, p.id)));
+    select.value = selected || '';
+}
+
+function fillAssigneeOptions(assignees) {
+    const select = document.getElementById('taskAssignees');
+    select.innerHTML = '';
+    people.forEach(p => {
+        const option = new Option(`${p.name} (${p.initials})`, p.id);
+        option.selected = assignees.includes(p.id);
+        select.add(option);
+    });
+}
+
+function assigneeInitials(task) {
+    const initials = (task.assignees || [])
+        .map(id => people.find(p => p.id === id))
+        .filter(Boolean)
+        .map(p => p.initials);
+    return initials.length ? ` · ${escapeHtml(initials.join(' '))}` : '';
+}
+
 // Task management
-function openTaskModal(categoryId, taskId = null) {
+function openTaskModal(categoryId, taskId = null, parentTaskId = null) {
     currentCategoryId = categoryId;
     currentTaskId = taskId;
+    currentParentTaskId = parentTaskId;
     const modal = document.getElementById('taskModal');
     
     if (taskId) {
         const category = currentChart.categories.find(c => c.id === categoryId);
-        const task = category.tasks.find(t => t.id === taskId);
+        const task = findTask(category, taskId);
         
         document.getElementById('taskModalTitle').textContent = 'Edit Task';
         document.getElementById('taskTitle').value = task.title;
@@ -285,16 +757,32 @@ function openTaskModal(categoryId, taskId = null) {
         document.getElementById('taskStartQuarter').value = task.startQuarter;
         document.getElementById('taskEndYear').value = task.endYear;
         document.getElementById('taskEndQuarter').value = task.endQuarter;
+        document.getElementById('taskStartDate').value = task.startDate || '';
+        document.getElementById('taskEndDate').value = task.endDate || '';
         document.getElementById('taskColor').value = task.color || '';
+        document.getElementById('taskProgress').value = task.progress || 0;
+        document.getElementById('taskStatus').value = task.status || '';
+        fillAssigneeOptions(task.assignees || []);
+        document.getElementById('taskTags').value = (task.tags || []).join(', ');
+        fillDependencyOptions(task);
+        fillTaskFields(task.fields || {});
     } else {
-        document.getElementById('taskModalTitle').textContent = 'Add Task';
+        document.getElementById('taskModalTitle').textContent = parentTaskId ? 'Add Subtask' : 'Add Task';
         document.getElementById('taskTitle').value = '';
         document.getElementById('taskDescription').value = '';
         document.getElementById('taskStartYear').value = currentChart.startYear;
         document.getElementById('taskStartQuarter').value = currentChart.startQuarter;
         document.getElementById('taskEndYear').value = currentChart.endYear;
         document.getElementById('taskEndQuarter').value = currentChart.endQuarter;
+        document.getElementById('taskStartDate').value = '';
+        document.getElementById('taskEndDate').value = '';
         document.getElementById('taskColor').value = '';
+        document.getElementById('taskProgress').value = 0;
+        document.getElementById('taskStatus').value = '';
+        fillAssigneeOptions([]);
+        document.getElementById('taskTags').value = '';
+        fillDependencyOptions(null);
+        fillTaskFields({});
     }
     
     modal.classList.add('active');
@@ -304,35 +792,74 @@ function closeTaskModal() {
     document.getElementById('taskModal').classList.remove('active');
     currentCategoryId = null;
     currentTaskId = null;
+    currentParentTaskId = null;
 }
 
 function saveTask() {
     const title = document.getElementById('taskTitle').value.trim();
     const description = document.getElementById('taskDescription').value.trim();
-    const startYear = parseInt(document.getElementById('taskStartYear').value);
-    const startQuarter = parseInt(document.getElementById('taskStartQuarter').value);
-    const endYear = parseInt(document.getElementById('taskEndYear').value);
-    const endQuarter = parseInt(document.getElementById('taskEndQuarter').value);
+    let startYear = parseInt(document.getElementById('taskStartYear').value);
+    let startQuarter = parseInt(document.getElementById('taskStartQuarter').value);
+    let endYear = parseInt(document.getElementById('taskEndYear').value);
+    let endQuarter = parseInt(document.getElementById('taskEndQuarter').value);
+    const startDate = document.getElementById('taskStartDate').value;
+    const endDate = document.getElementById('taskEndDate').value;
     const color = document.getElementById('taskColor').value;
+    const progress = parseInt(document.getElementById('taskProgress').value) || 0;
+    const status = document.getElementById('taskStatus').value;
+    const assignees = Array.from(document.getElementById('taskAssignees').selectedOptions).map(o => o.value);
+    const tags = parseTags(document.getElementById('taskTags').value);
+    const dependsOn = Array.from(document.getElementById('taskDependencies').selectedOptions).map(o => o.value);
     
     if (!title) {
         alert('Please enter a task title');
         return;
     }
+    let fields;
+    try {
+        fields = taskFieldValues();
+    } catch (error) {
+        alert(error.message);
+        return;
+    }
+    if (progress < 0 || progress > 100) {
+        alert('Progress must be between 0 and 100');
+        return;
+    }
+    if (startDate && endDate && endDate < startDate) {
+        alert('End date must not be before start date');
+        return;
+    }
+    
+    // Dates take precedence over quarters, as on the server
+    if (startDate) {
+        [startYear, startQuarter] = quarterOfDate(startDate);
+    }
+    if (endDate) {
+        [endYear, endQuarter] = quarterOfDate(endDate);
+    }
     
     const category = currentChart.categories.find(c => c.id === currentCategoryId);
     
     if (currentTaskId) {
-        const task = category.tasks.find(t => t.id === currentTaskId);
+        const task = findTask(category, currentTaskId);
         task.title = title;
         task.description = description;
         task.startYear = startYear;
         task.startQuarter = startQuarter;
         task.endYear = endYear;
         task.endQuarter = endQuarter;
+        setOptionalField(task, 'startDate', startDate);
+        setOptionalField(task, 'endDate', endDate);
         task.color = color;
+        setOptionalField(task, 'progress', progress);
+        setOptionalField(task, 'status', status);
+        setOptionalField(task, 'assignees', assignees.length ? assignees : null);
+        setOptionalField(task, 'fields', Object.keys(fields).length ? fields : null);
+        setOptionalField(task, 'tags', tags.length ? tags : null);
+        task.dependencies = selectedDependencies(task.dependencies, dependsOn);
     } else {
-        category.tasks.push({
+        const task = {
             id: generateId(),
             title,
             description,
@@ -341,25 +868,202 @@ function saveTask() {
             endYear,
             endQuarter,
             color
-        });
+        };
+        setOptionalField(task, 'startDate', startDate);
+        setOptionalField(task, 'endDate', endDate);
+        setOptionalField(task, 'progress', progress);
+        setOptionalField(task, 'status', status);
+        setOptionalField(task, 'assignees', assignees.length ? assignees : null);
+        setOptionalField(task, 'fields', Object.keys(fields).length ? fields : null);
+        setOptionalField(task, 'tags', tags.length ? tags : null);
+        task.dependencies = selectedDependencies([], dependsOn);
+        if (currentParentTaskId) {
+            // A parent takes its dates from its subtasks, so it can no
+            // longer take part in dependencies
+            const parent = findTask(category, currentParentTaskId);
+            delete parent.dependencies;
+            removeDependenciesOn(parent.id);
+            parent.subtasks = parent.subtasks || [];
+            parent.subtasks.push(task);
+        } else {
+            category.tasks.push(task);
+        }
     }
     
     closeTaskModal();
     updateUI();
 }
 
+// fillDependencyOptions lists every other task of the chart as a possible predecessor
+function fillDependencyOptions(task) {
+    const select = document.getElementById('taskDependencies');
+    const selected = new Set((task && task.dependencies || []).map(d => d.taskId));
+    select.innerHTML = '';
+    currentChart.categories.forEach(c => taskRows(c.tasks).forEach(({ task: t }) => {
+        if (task && t.id === task.id) return;
+        if (isSummary(t)) return; // summary tasks cannot be depended on
+        const option = new Option(`${c.name}: ${t.title}`, t.id);
+        option.selected = selected.has(t.id);
+        select.add(option);
+    }));
+}
+
+// selectedDependencies keeps the type of existing dependencies and adds
+// new ones as finish-to-start
+function selectedDependencies(existing, taskIds) {
+    const byId = new Map((existing || []).map(d => [d.taskId, d]));
+    return taskIds.map(id => byId.get(id) || { taskId: id, type: 'FS' });
+}
+
+// parseTags splits a comma separated tag input, dropping blanks and repeats
+function parseTags(text) {
+    return [...new Set(text.split(',').map(t => t.trim()).filter(Boolean))];
+}
+
+function tagList(tags) {
+    return (tags || []).map(t => ` <span class="tag">${escapeHtml(t)}</span>`).join('');
+}
+
+function setOptionalField(obj, field, value) {
+    if (value) {
+        obj[field] = value;
+    } else {
+        delete obj[field];
+    }
+}
+
+// Fiscal calendar helpers mirror the server: a chart's years and quarters
+// are fiscal when it has a fiscal calendar
+function fiscalStartMonth() {
+    return currentChart.fiscal ? currentChart.fiscal.startMonth : 1;
+}
+
+// fiscalYearShift is 1 when a year starting mid-year is named after the
+// calendar year it ends in
+function fiscalYearShift() {
+    const fiscal = currentChart.fiscal;
+    return fiscal && fiscal.startMonth > 1 && fiscal.namedBy !== 'start' ? 1 : 0;
+}
+
+// quarterStart returns the first instant of a quarter; quarters past the
+// fourth roll into the next year
+function quarterStart(year, quarter) {
+    return Date.UTC(year - fiscalYearShift(), fiscalStartMonth() - 1 + (quarter - 1) * 3, 1);
+}
+
+// quarterOfDate returns [year, quarter] for a YYYY-MM-DD date
+function quarterOfDate(date) {
+    const [year, month] = date.split('-').map(Number);
+    const start = fiscalStartMonth();
+    return [year - (month < start ? 1 : 0) + fiscalYearShift(), Math.floor((month - start + 12) % 12 / 3) + 1];
+}
+
+function quarterLabel(year, quarter) {
+    const label = currentChart.fiscal ? currentChart.fiscal.label || 'FY{yy} Q{q}' : 'Q{q} {yyyy}';
+    return label
+        .replaceAll('{yyyy}', year)
+        .replaceAll('{yy}', String(year % 100).padStart(2, '0'))
+        .replaceAll('{q}', quarter);
+}
+
+// taskRows flattens a task tree into { task, depth } rows, parents first;
+// with visibleOnly, the subtasks of collapsed tasks are left out
+function taskRows(tasks, visibleOnly = false, depth = 0) {
+    const rows = [];
+    (tasks || []).forEach(task => {
+        rows.push({ task, depth });
+        if (!(visibleOnly && task.collapsed)) {
+            rows.push(...taskRows(task.subtasks, visibleOnly, depth + 1));
+        }
+    });
+    return rows;
+}
+
+function isSummary(task) {
+    return !!(task.subtasks && task.subtasks.length);
+}
+
+// findTaskList returns the list holding a task: its category's tasks or
+// its parent's subtasks
+function findTaskList(tasks, taskId) {
+    if (!tasks) return null;
+    if (tasks.some(t => t.id === taskId)) return tasks;
+    for (const t of tasks) {
+        const list = findTaskList(t.subtasks, taskId);
+        if (list) return list;
+    }
+    return null;
+}
+
+function findTask(category, taskId) {
+    const list = findTaskList(category.tasks, taskId);
+    return list && list.find(t => t.id === taskId);
+}
+
+// taskStart and taskEnd return a task's first and last day as YYYY-MM-DD
+function taskStart(task) {
+    return task.startDate || new Date(quarterStart(task.startYear, task.startQuarter)).toISOString().slice(0, 10);
+}
+
+function taskEnd(task) {
+    return task.endDate || new Date(quarterStart(task.endYear, task.endQuarter + 1) - 86400000).toISOString().slice(0, 10);
+}
+
+// rollupSubtasks mirrors the server: a parent spans its subtasks and takes
+// their duration-weighted progress
+function rollupSubtasks(tasks) {
+    (tasks || []).forEach(task => {
+        if (!isSummary(task)) return;
+        rollupSubtasks(task.subtasks);
+        let first = null, last = null, done = 0, total = 0;
+        task.subtasks.forEach(sub => {
+            if (!first || taskStart(sub) < taskStart(first)) first = sub;
+            if (!last || taskEnd(sub) > taskEnd(last)) last = sub;
+            const days = (Date.parse(taskEnd(sub)) - Date.parse(taskStart(sub))) / 86400000 + 1;
+            done += days * (sub.progress || 0);
+            total += days;
+        });
+        task.startYear = first.startYear;
+        task.startQuarter = first.startQuarter;
+        setOptionalField(task, 'startDate', first.startDate);
+        task.endYear = last.endYear;
+        task.endQuarter = last.endQuarter;
+        setOptionalField(task, 'endDate', last.endDate);
+        setOptionalField(task, 'progress', total ? Math.round(done / total) : 0);
+    });
+}
+
+function toggleCollapsed(categoryId, taskId) {
+    const category = currentChart.categories.find(c => c.id === categoryId);
+    const task = findTask(category, taskId);
+    setOptionalField(task, 'collapsed', !task.collapsed);
+    updateUI();
+}
+
 function editTask(categoryId, taskId) {
     openTaskModal(categoryId, taskId);
 }
 
 function deleteTask(categoryId, taskId) {
-    if (!confirm('Delete this task?')) return;
-    
     const category = currentChart.categories.find(c => c.id === categoryId);
-    category.tasks = category.tasks.filter(t => t.id !== taskId);
+    const list = findTaskList(category.tasks, taskId);
+    const task = list.find(t => t.id === taskId);
+    if (!confirm(isSummary(task) ? 'Delete this task and its subtasks?' : 'Delete this task?')) return;
+    
+    list.splice(list.indexOf(task), 1);
+    taskRows([task]).forEach(({ task: t }) => removeDependenciesOn(t.id));
     updateUI();
 }
 
+// Drop dependencies that point at a removed task, which the server would reject
+function removeDependenciesOn(taskId) {
+    currentChart.categories.forEach(c => taskRows(c.tasks).forEach(({ task: t }) => {
+        if (t.dependencies) {
+            t.dependencies = t.dependencies.filter(d => d.taskId !== taskId);
+        }
+    }));
+}
+
 // Drag and drop
 let draggedTask = null;
 let draggedCategory = null;
@@ -377,53 +1081,61 @@ function dragEnd(event) {
 // Save and export
 // Load Chart Modal
 async function openLoadChartModal() {
+    document.getElementById('chartSearch').value = '';
+    if (await searchCharts()) {
+        document.getElementById('loadChartModal').classList.add('active');
+    }
+}
+
+// searchCharts lists the first page of chart summaries matching the
+// search box, most recently updated first
+async function searchCharts() {
+    const params = new URLSearchParams({ fields: 'summary', sort: '-updatedAt', limit: chartPageSize });
+    const q = document.getElementById('chartSearch').value.trim();
+    if (q) params.set('q', q);
+    document.getElementById('chartList').innerHTML = '';
+    return loadChartPage('/api/charts?' + params);
+}
+
+// loadChartPage appends a page of the chart list and remembers the link
+// to the next one
+async function loadChartPage(url) {
     try {
-        const response = await fetch('/api/charts');
-        if (!response.ok) throw new Error('Failed to load charts');
+        const response = await fetch(url);
+        if (!response.ok) throw new Error(await errorMessage(response, 'Failed to load charts'));
         
         const charts = await response.json();
-        const chartList = document.getElementById('chartList');
-        chartList.innerHTML = '';
+        const next = /<([^>]*)>;\s*rel="next"/.exec(response.headers.get('Link') || '');
+        chartListNext = next ? next[1] : null;
+        document.getElementById('loadMoreCharts').style.display = chartListNext ? '' : 'none';
         
-        if (charts.length === 0) {
-            chartList.innerHTML = '<p style="text-align: center; color: #6c757d;">No saved charts found</p>';
-        } else {
-            charts.forEach(chart => {
-                const item = document.createElement('div');
-                item.className = 'chart-item';
-                item.innerHTML = `
-                    <div class="chart-item-info">
-                        <div class="chart-item-name">${escapeHtml(chart.title || 'Untitled Chart')}</div>
-                        <div class="chart-item-date">ID: ${chart.id}</div>
-                    </div>
-                    <div class="chart-item-actions">
-                        <button class="btn btn-sm btn-primary load-chart-btn" data-chart-id="${chart.id}">Load</button>
-                        <button class="btn btn-sm btn-danger delete-chart-btn" data-chart-id="${chart.id}">Delete</button>
-                    </div>
-                `;
-                chartList.appendChild(item);
-            });
-            
-            // Add event listeners to load and delete buttons
-            document.querySelectorAll('.load-chart-btn').forEach(btn => {
-                btn.addEventListener('click', (e) => {
-                    const chartId = e.target.getAttribute('data-chart-id');
-                    loadChart(chartId);
-                });
-            });
-            
-            document.querySelectorAll('.delete-chart-btn').forEach(btn => {
-                btn.addEventListener('click', (e) => {
-                    const chartId = e.target.getAttribute('data-chart-id');
-                    deleteChart(chartId);
-                });
-            });
+        const chartList = document.getElementById('chartList');
+        if (charts.length === 0 && !chartList.children.length) {
+            const message = document.getElementById('chartSearch').value.trim() ? 'No charts match your search' : 'No saved charts found';
+            chartList.innerHTML = `<p style="text-align: center; color: #6c757d;">${message}</p>`;
         }
-        
-        document.getElementById('loadChartModal').classList.add('active');
+        charts.forEach(chart => {
+            const item = document.createElement('div');
+            item.className = 'chart-item';
+            item.innerHTML = `
+                <div class="chart-item-info">
+                    <div class="chart-item-name">${escapeHtml(chart.title || 'Untitled Chart')}</div>
+                    <div class="chart-item-date">Updated ${new Date(chart.updatedAt).toLocaleString()} · ${chart.taskCount} task${chart.taskCount === 1 ? '' : 's'}</div>
+                </div>
+                <div class="chart-item-actions">
+                    <button class="btn btn-sm btn-primary load-chart-btn">Load</button>
+                    <button class="btn btn-sm btn-danger delete-chart-btn">Delete</button>
+                </div>
+            `;
+            item.querySelector('.load-chart-btn').addEventListener('click', () => loadChart(chart.id));
+            item.querySelector('.delete-chart-btn').addEventListener('click', () => deleteChart(chart.id));
+            chartList.appendChild(item);
+        });
+        return true;
     } catch (error) {
         console.error('Error loading charts:', error);
         alert('Error loading charts: ' + error.message);
+        return false;
     }
 }
 
@@ -437,6 +1149,7 @@ async function loadChart(id) {
         if (!response.ok) throw new Error('Failed to load chart');
         
         currentChart = await response.json();
+        loadedChart = { id: currentChart.id, etag: response.headers.get('ETag') };
         updateUIFromChart();
         closeLoadChartModal();
         alert('Chart loaded successfully!');
@@ -467,7 +1180,7 @@ async function deleteChart(id) {
 async function openSaveChartModal() {
     try {
         // Load existing charts for the dropdown
-        const response = await fetch('/api/charts');
+        const response = await fetch('/api/charts?fields=summary&sort=title');
         if (response.ok) {
             const charts = await response.json();
             const select = document.getElementById('existingChartSelect');
@@ -523,21 +1236,33 @@ async function confirmSaveChart() {
     closeSaveChartModal();
 }
 
-async function saveChart() {
+async function saveChart(force = false) {
     try {
         const method = currentChart.id ? 'PUT' : 'POST';
         const url = currentChart.id ? `/api/charts/${currentChart.id}` : '/api/charts';
+        const headers = { 'Content-Type': 'application/json' };
+        if (!force && loadedChart.etag && loadedChart.id === currentChart.id) {
+            headers['If-Match'] = loadedChart.etag;
+        }
         
         const response = await fetch(url, {
             method: method,
-            headers: { 'Content-Type': 'application/json' },
+            headers: headers,
             body: JSON.stringify(currentChart)
         });
         
-        if (!response.ok) throw new Error('Failed to save chart');
+        if (response.status === 412) {
+            if (confirm('This chart was changed by someone else since you loaded it. Overwrite their changes?')) {
+                await saveChart(true);
+            }
+            return;
+        }
+        if (!response.ok) throw new Error(await errorMessage(response, 'Failed to save chart'));
         
         const data = await response.json();
         currentChart.id = data.id;
+        currentChart.version = data.version;
+        loadedChart = { id: data.id, etag: response.headers.get('ETag') };
         alert('Chart saved successfully!');
     } catch (error) {
         console.error('Error saving chart:', error);
@@ -545,6 +1270,54 @@ async function saveChart() {
     }
 }
 
+// Baselines are frozen on the server from the saved chart
+function renderBaselines() {
+    const select = document.getElementById('exportBaseline');
+    const selected = select.value;
+    select.innerHTML = '<option value="">No baseline</option>';
+    (currentChart.baselines || []).forEach(b => {
+        const option = new Option(`${b.name} (${new Date(b.createdAt).toLocaleDateString()})`, b.id);
+        option.selected = b.id === selected;
+        select.add(option);
+    });
+}
+
+async function freezeBaseline() {
+    if (!currentChart.id || loadedChart.id !== currentChart.id) {
+        alert('Please save the chart first');
+        return;
+    }
+    const name = prompt('Baseline name (e.g. "Q3 commitment")');
+    if (!name || !name.trim()) return;
+    
+    try {
+        const headers = { 'Content-Type': 'application/json' };
+        if (loadedChart.etag) {
+            headers['If-Match'] = loadedChart.etag;
+        }
+        const response = await fetch(`/api/charts/${currentChart.id}/baselines`, {
+            method: 'POST',
+            headers: headers,
+            body: JSON.stringify({ name: name.trim() })
+        });
+        if (response.status === 412) {
+            alert('This chart was changed since you loaded it. Reload it before freezing a baseline.');
+            return;
+        }
+        if (!response.ok) throw new Error(await errorMessage(response, 'Failed to freeze baseline'));
+        
+        const baseline = await response.json();
+        currentChart.baselines = [...(currentChart.baselines || []), baseline];
+        currentChart.version = (currentChart.version || 0) + 1;
+        loadedChart.etag = response.headers.get('ETag');
+        renderBaselines();
+        document.getElementById('exportBaseline').value = baseline.id;
+    } catch (error) {
+        console.error('Error freezing baseline:', error);
+        alert('Error freezing baseline: ' + error.message);
+    }
+}
+
 async function exportChart(format) {
     if (!currentChart.id) {
         alert('Please save the chart first');
@@ -552,8 +1325,8 @@ async function exportChart(format) {
     }
     
     try {
-        const response = await fetch(`/api/charts/${currentChart.id}/export/${format}`);
-        if (!response.ok) throw new Error('Export failed');
+        const response = await fetch(`/api/charts/${currentChart.id}/export/${format}${exportQuery()}`);
+        if (!response.ok) throw new Error((await response.text()).trim() || 'Export failed');
         
         const blob = await response.blob();
         const url = window.URL.createObjectURL(blob);
@@ -570,6 +1343,49 @@ async function exportChart(format) {
     }
 }
 
+// exportQuery builds the query string for the export options in the sidebar
+function exportQuery() {
+    const params = new URLSearchParams();
+    if (document.getElementById('exportHighlightCritical').checked) {
+        params.set('highlight', 'critical');
+    }
+    if (document.getElementById('exportLabelAssignees').checked) {
+        params.set('labels', 'assignees');
+    }
+    const status = document.getElementById('exportStatus').value;
+    if (status) {
+        params.set('status', status);
+    }
+    const tagFilters = { tags: 'exportTags', excludeTags: 'exportExcludeTags' };
+    Object.entries(tagFilters).forEach(([param, id]) => {
+        const tags = parseTags(document.getElementById(id).value);
+        if (tags.length) {
+            params.set(param, tags.join(','));
+        }
+    });
+    const bounds = { from: 'exportFrom', to: 'exportTo' };
+    Object.entries(bounds).forEach(([param, id]) => {
+        const bound = document.getElementById(id).value.trim();
+        if (bound) {
+            params.set(param, bound);
+        }
+    });
+    const assignee = document.getElementById('exportAssignee').value;
+    if (assignee) {
+        params.set('assignee', assignee);
+    }
+    const groupBy = document.getElementById('exportGroupBy').value;
+    if (groupBy) {
+        params.set('groupBy', groupBy);
+    }
+    const baseline = document.getElementById('exportBaseline').value;
+    if (baseline) {
+        params.set('baseline', baseline);
+    }
+    const query = params.toString();
+    return query ? `?${query}` : '';
+}
+
 // Utilities
 function generateId() {
     return 'xxxxxxxx-xxxx-4xxx-yxxx-xxxxxxxxxxxx'.replace(/[xy]/g, c => {
@@ -585,6 +1401,76 @@ function escapeHtml(text) {
     return div.innerHTML;
 }
 
+// datePosition returns where a YYYY-MM-DD date starts (or, with
+// endOfDay, ends) in quarter columns, or -1 outside the chart
+function datePosition(quarters, date, endOfDay) {
+    const [year, month, day] = date.split('-').map(Number);
+    const [qYear, quarter] = quarterOfDate(date);
+    const idx = quarters.findIndex(q => q.year === qYear && q.quarter === quarter);
+    if (idx < 0) return -1;
+    const qStart = quarterStart(qYear, quarter);
+    const qEnd = quarterStart(qYear, quarter + 1);
+    const at = Date.UTC(year, month - 1, day + (endOfDay ? 1 : 0));
+    return idx + (at - qStart) / (qEnd - qStart);
+}
+
+// taskSpan mirrors the server: whole quarters unless the task has dates
+function taskSpan(task, quarters) {
+    const start = task.startDate
+        ? datePosition(quarters, task.startDate, false)
+        : quarters.findIndex(q => q.year === task.startYear && q.quarter === task.startQuarter);
+    const endIdx = task.endDate
+        ? datePosition(quarters, task.endDate, true)
+        : quarters.findIndex(q => q.year === task.endYear && q.quarter === task.endQuarter);
+    if (start < 0 || endIdx < 0) return null;
+    return { start, end: task.endDate ? endIdx : endIdx + 1 };
+}
+
+// categoryProgress approximates the server's duration-weighted rollup, or
+// statusDecoration draws the preview styling for a task status, matching
+// the server's exports
+function statusDecoration(status, barX, barY, barWidth, barHeight) {
+    switch (status) {
+    case 'planned':
+        return `<rect x="${barX + 2}" y="${barY}" width="${barWidth - 4}" height="${barHeight}" fill="url(#status-hatch)" rx="4"/>`;
+    case 'at-risk':
+        return `<rect x="${barX + 2}" y="${barY}" width="${barWidth - 4}" height="${barHeight}" fill="none" stroke="#e74c3c" stroke-width="3" rx="4"/>`;
+    case 'blocked':
+        return `<rect x="${barX + 2}" y="${barY}" width="${barWidth - 4}" height="${barHeight}" fill="none" stroke="#555555" stroke-width="2" stroke-dasharray="4 2" rx="4"/>`;
+    case 'cancelled':
+        return `<line x1="${barX}" y1="${barY + barHeight / 2}" x2="${barX + barWidth}" y2="${barY + barHeight / 2}" stroke="#333333" stroke-width="2"/>`;
+    default:
+        return '';
+    }
+}
+
+// returns null if no task in the category tracks progress
+function categoryProgress(category, quarters) {
+    if (!category.tasks.some(t => t.progress)) return null;
+    let done = 0, total = 0;
+    category.tasks.forEach(t => {
+        const span = taskSpan(t, quarters);
+        if (!span) return;
+        done += (span.end - span.start) * (t.progress || 0);
+        total += span.end - span.start;
+    });
+    return total ? Math.round(done / total) : null;
+}
+
+// summaryBar draws a parent task as a thin dark bar with a downward cap at
+// each end, as the server does
+function summaryBar(task, taskColor, barX, barY, barWidth) {
+    const x0 = barX + 2, x1 = barX + barWidth - 2, capY = barY + 8;
+    const dark = 'style="filter: brightness(0.55)"';
+    let bar = `<rect x="${x0}" y="${barY}" width="${x1 - x0}" height="8" fill="${taskColor}" ${dark}/>`;
+    if (task.progress) {
+        bar += `<rect x="${x0}" y="${barY + 2}" width="${Math.round((x1 - x0) * task.progress / 100)}" height="4" fill="${taskColor}"/>`;
+    }
+    bar += `<polygon points="${x0},${capY} ${x0 + 6},${capY} ${x0},${capY + 6}" fill="${taskColor}" ${dark}/>`;
+    bar += `<polygon points="${x1 - 6},${capY} ${x1},${capY} ${x1},${capY + 6}" fill="${taskColor}" ${dark}/>`;
+    return bar;
+}
+
 function generateClientSVG(chart) {
     // Simple client-side SVG generation for preview
     const quarters = [];
@@ -638,7 +1524,7 @@ function generateClientSVG(chart) {
         perCategoryHeights.set(cat.id, catH);
         totalCategoryHeadersHeight += catH;
         
-        cat.tasks.forEach(task => {
+        taskRows(cat.tasks, true).forEach(({ task }) => {
             const titleLines = wrapText(task.title || '', 28);
             const descLines = wrapText(task.description || '', 36);
             const h = Math.max(baseRowHeight, titleLines.length * titleLineHeight + descLines.length * descLineHeight + verticalPaddingPerTask);
@@ -647,11 +1533,34 @@ function generateClientSVG(chart) {
         });
     });
 
+    // Milestones get a row above the categories, or one at the end of their category
+    const milestones = chart.milestones || [];
+    const topMilestones = milestones.filter(m => !chart.categories.some(c => c.id === m.categoryId));
+    const milestoneRows = (topMilestones.length > 0 ? 1 : 0) +
+        chart.categories.filter(c => milestones.some(m => m.categoryId === c.id)).length;
+
     const width = labelWidth + quarters.length * quarterWidth + padding * 2;
-    const height = headerHeight + totalCategoryHeadersHeight + totalTaskHeight + padding * 2;
+    const height = headerHeight + totalCategoryHeadersHeight + totalTaskHeight + milestoneRows * baseRowHeight + padding * 2;
+
+    function milestoneRow(items, catColor, y) {
+        let row = `<rect x="${padding}" y="${y}" width="${labelWidth}" height="${baseRowHeight}" fill="#fff" stroke="#ddd" stroke-width="1"/>`;
+        row += `<text x="${padding + 10}" y="${y + baseRowHeight / 2 + 4}" class="label" font-style="italic">Milestones</text>`;
+        items.forEach(m => {
+            const start = datePosition(quarters, m.date, false);
+            if (start < 0) return;
+            const end = datePosition(quarters, m.date, true);
+            const pos = end >= 0 ? (start + end) / 2 : start; // centred on the day, as on the server
+            const cx = padding + labelWidth + Math.round(pos * quarterWidth);
+            const cy = y + baseRowHeight / 2;
+            const color = m.color || catColor || '#34495e';
+            row += `<polygon points="${cx},${cy - 8} ${cx + 8},${cy} ${cx},${cy + 8} ${cx - 8},${cy}" fill="${color}"/>`;
+            row += `<text x="${cx + 12}" y="${cy + 4}" class="label">${escapeHtml(m.title)}</text>`;
+        });
+        return row;
+    }
     
     let svg = `<svg width="${width}" height="${height}" xmlns="http://www.w3.org/2000/svg">`;
-    svg += `<defs><style>.title{font:bold 20px sans-serif;fill:#333}.header{font:bold 12px sans-serif;fill:#555}.label{font:12px sans-serif;fill:#333}.category{font:bold 14px sans-serif;fill:#222}.desc{font:10px sans-serif;fill:#666}</style></defs>`;
+    svg += `<defs><style>.title{font:bold 20px sans-serif;fill:#333}.header{font:bold 12px sans-serif;fill:#555}.label{font:12px sans-serif;fill:#333}.category{font:bold 14px sans-serif;fill:#222}.desc{font:10px sans-serif;fill:#666}</style><pattern id="status-hatch" patternUnits="userSpaceOnUse" width="6" height="6" patternTransform="rotate(45)"><line x1="0" y1="0" x2="0" y2="6" stroke="#fff" stroke-width="2" opacity="0.7"/></pattern></defs>`;
     svg += `<rect width="${width}" height="${height}" fill="#fafafa"/>`;
     svg += `<text x="${padding}" y="${padding + 20}" class="title">${escapeHtml(chart.title)}</text>`;
     
@@ -661,12 +1570,16 @@ function generateClientSVG(chart) {
         const y = headerHeight;
         const color = i % 2 === 0 ? '#e8e8e8' : '#f5f5f5';
         svg += `<rect x="${x}" y="${y - 30}" width="${quarterWidth}" height="30" fill="${color}" stroke="#ccc" stroke-width="1"/>`;
-        svg += `<text x="${x + quarterWidth / 2}" y="${y - 10}" class="header" text-anchor="middle">Q${q.quarter} ${q.year}</text>`;
+        svg += `<text x="${x + quarterWidth / 2}" y="${y - 10}" class="header" text-anchor="middle">${escapeHtml(quarterLabel(q.year, q.quarter))}</text>`;
         svg += `<line x1="${x}" y1="${y}" x2="${x}" y2="${height - padding}" stroke="#ddd" stroke-width="1"/>`;
     });
     
     // Categories and tasks - render with wrapping
     let currentY = headerHeight;
+    if (topMilestones.length > 0) {
+        svg += milestoneRow(topMilestones, '', currentY);
+        currentY += baseRowHeight;
+    }
     chart.categories.forEach(cat => {
         const catH = perCategoryHeights.get(cat.id) || categoryHeaderHeight;
         svg += `<rect x="${padding}" y="${currentY}" width="${labelWidth}" height="${catH}" fill="${cat.color}" opacity="0.3"/>`;
@@ -682,11 +1595,16 @@ function generateClientSVG(chart) {
             });
             svg += `</text>`;
         }
+        const catProgress = categoryProgress(cat, quarters);
+        if (catProgress !== null) {
+            svg += `<text x="${padding + labelWidth - 10}" y="${currentY + 22}" class="desc" text-anchor="end">${catProgress}%</text>`;
+        }
         svg += `<rect x="${padding + labelWidth}" y="${currentY}" width="${quarters.length * quarterWidth}" height="${catH}" fill="${cat.color}" opacity="0.05"/>`;
         currentY += catH;
 
-        cat.tasks.forEach(task => {
+        taskRows(cat.tasks, true).forEach(({ task, depth }) => {
             const taskH = perTaskHeights.get(task.id) || baseRowHeight;
+            const textX = padding + 10 + depth * 12;
             svg += `<rect x="${padding}" y="${currentY}" width="${labelWidth}" height="${taskH}" fill="#fff" stroke="#ddd" stroke-width="1"/>`;
 
             // Title lines
@@ -694,36 +1612,53 @@ function generateClientSVG(chart) {
             const descLines = wrapText(task.description || '', 36);
             let textY = currentY + 14;
             if (titleLines.length > 0) {
-                svg += `<text x="${padding + 10}" y="${textY}" class="label">`;
+                let decoration = task.status === 'cancelled' ? ' text-decoration="line-through"' : '';
+                if (isSummary(task)) decoration += ' font-weight="bold"';
+                svg += `<text x="${textX}" y="${textY}" class="label"${decoration}>`;
                 titleLines.forEach((ln, idx) => {
                     const dy = idx === 0 ? 0 : titleLineHeight;
-                    svg += `<tspan x="${padding + 10}" dy="${dy}">${escapeHtml(ln)}</tspan>`;
+                    svg += `<tspan x="${textX}" dy="${dy}">${escapeHtml(ln)}</tspan>`;
                 });
                 svg += `</text>`;
                 textY += titleLines.length * titleLineHeight;
             }
             if (descLines.length > 0) {
-                svg += `<text x="${padding + 10}" y="${textY + 4}" class="desc">`;
+                svg += `<text x="${textX}" y="${textY + 4}" class="desc">`;
                 descLines.forEach((ln, idx) => {
                     const dy = idx === 0 ? 0 : descLineHeight;
-                    svg += `<tspan x="${padding + 10}" dy="${dy}">${escapeHtml(ln)}</tspan>`;
+                    svg += `<tspan x="${textX}" dy="${dy}">${escapeHtml(ln)}</tspan>`;
                 });
                 svg += `</text>`;
             }
 
-            const startIdx = quarters.findIndex(q => q.year === task.startYear && q.quarter === task.startQuarter);
-            const endIdx = quarters.findIndex(q => q.year === task.endYear && q.quarter === task.endQuarter);
-            if (startIdx >= 0 && endIdx >= 0) {
-                const barX = padding + labelWidth + startIdx * quarterWidth;
-                const barWidth = (endIdx - startIdx + 1) * quarterWidth;
+            const span = taskSpan(task, quarters);
+            if (span) {
+                const barX = padding + labelWidth + Math.round(span.start * quarterWidth);
+                const barWidth = Math.max(Math.round((span.end - span.start) * quarterWidth), 7);
                 const barY = currentY + 8;
                 const barHeight = Math.max(12, taskH - 16);
                 const taskColor = task.color || cat.color;
+                if (isSummary(task)) {
+                    svg += summaryBar(task, taskColor, barX, barY + (barHeight - 8) / 2, barWidth);
+                    currentY += taskH;
+                    return;
+                }
                 svg += `<rect x="${barX + 2}" y="${barY}" width="${barWidth - 4}" height="${barHeight}" fill="${taskColor}" rx="4" opacity="0.8"/>`;
+                if (task.progress) {
+                    const doneWidth = Math.round((barWidth - 4) * task.progress / 100);
+                    svg += `<rect x="${barX + 2}" y="${barY}" width="${doneWidth}" height="${barHeight}" fill="${taskColor}" rx="4" style="filter: brightness(0.65)"/>`;
+                }
+                svg += statusDecoration(task.status, barX, barY, barWidth, barHeight);
             }
 
             currentY += taskH;
         });
+
+        const catMilestones = milestones.filter(m => m.categoryId === cat.id);
+        if (catMilestones.length > 0) {
+            svg += milestoneRow(catMilestones, cat.color, currentY);
+            currentY += baseRowHeight;
+        }
     });
     
     svg += '</svg>';
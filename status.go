@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"strings"
+)
+
+// Task statuses. A task without a status is drawn unstyled.
+const (
+	statusPlanned    = "planned"
+	statusInProgress = "in-progress"
+	statusAtRisk     = "at-risk"
+	statusBlocked    = "blocked"
+	statusDone       = "done"
+	statusCancelled  = "cancelled"
+)
+
+// Status styling colours
+const (
+	atRiskColor    = "#e74c3c"
+	blockedColor   = "#555555"
+	cancelledColor = "#333333"
+)
+
+// statusTransitions lists the statuses each status may move to. A task
+// without a status may take any status, and keeping a status is always
+// allowed.
+var statusTransitions = map[string][]string{
+	statusPlanned:    {statusInProgress, statusBlocked, statusCancelled},
+	statusInProgress: {statusPlanned, statusAtRisk, statusBlocked, statusDone, statusCancelled},
+	statusAtRisk:     {statusInProgress, statusBlocked, statusDone, statusCancelled},
+	statusBlocked:    {statusPlanned, statusInProgress, statusAtRisk, statusCancelled},
+	statusDone:       {statusInProgress},
+	statusCancelled:  {statusPlanned},
+}
+
+func isStatus(s string) bool {
+	_, ok := statusTransitions[s]
+	return ok
+}
+
+func statusNames() string {
+	return strings.Join([]string{statusPlanned, statusInProgress, statusAtRisk, statusBlocked, statusDone, statusCancelled}, ", ")
+}
+
+// canTransition reports whether a task may move from one status to another
+func canTransition(from, to string) bool {
+	if from == "" || from == to {
+		return true
+	}
+	for _, s := range statusTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// validateStatuses checks that every task's status is known
+func validateStatuses(chart *Chart) error {
+	var errs []error
+	walkChartTasks(chart, func(task *Task, path string) {
+		if task.Status != "" && !isStatus(task.Status) {
+			errs = append(errs, fieldErrorf(path+"/status", "task %q has unknown status %q (want one of %s)", task.Title, task.Status, statusNames()))
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// validateTransitions checks that every task present in both versions of
+// a chart changed status along an allowed transition. current is nil for
+// a new chart.
+func validateTransitions(current, updated *Chart) error {
+	if current == nil {
+		return nil
+	}
+	before := chartTasks(current)
+
+	var errs []error
+	walkChartTasks(updated, func(task *Task, path string) {
+		old, ok := before[task.ID]
+		if !ok || canTransition(old.Status, task.Status) {
+			return
+		}
+		if task.Status == "" {
+			errs = append(errs, fieldErrorf(path+"/status", "task %q cannot clear its status %q", task.Title, old.Status))
+		} else {
+			errs = append(errs, fieldErrorf(path+"/status", "task %q cannot move from %s to %s", task.Title, old.Status, task.Status))
+		}
+	})
+	return errors.Join(errs...)
+}
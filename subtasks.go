@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"math"
+)
+
+// taskRow is a task as laid out by the renderers, with its nesting depth
+type taskRow struct {
+	task  Task
+	depth int
+}
+
+// indentWidth is how far each level of subtasks is indented, in pixels
+const indentWidth = 12
+
+// Summary bar geometry, in pixels: the bar's height and the size of the
+// caps hanging from its ends
+const (
+	summaryBarHeight = 8
+	summaryCapSize   = 6
+)
+
+// PDF equivalents of indentWidth and summaryBarHeight, in millimetres
+const (
+	pdfIndentWidth      = 2.5
+	pdfSummaryBarHeight = 2.0
+)
+
+// summaryShade darkens a summary bar's colour; its progress is drawn in
+// the undarkened colour
+const summaryShade = 0.55
+
+// isSummary reports whether a task is a parent whose dates and progress
+// are derived from its subtasks
+func isSummary(task Task) bool {
+	return len(task.Subtasks) > 0
+}
+
+// walkTasks calls fn for every task and subtask in depth-first order,
+// parents before their children
+func walkTasks(tasks []Task, fn func(task *Task, depth int)) {
+	var walk func(tasks []Task, depth int)
+	walk = func(tasks []Task, depth int) {
+		for i := range tasks {
+			fn(&tasks[i], depth)
+			walk(tasks[i].Subtasks, depth+1)
+		}
+	}
+	walk(tasks, 0)
+}
+
+// allTasks flattens a task tree, parents before their children
+func allTasks(tasks []Task) []Task {
+	var flat []Task
+	walkTasks(tasks, func(task *Task, _ int) {
+		flat = append(flat, *task)
+	})
+	return flat
+}
+
+// leafTasks flattens a task tree to the tasks without subtasks
+func leafTasks(tasks []Task) []Task {
+	var leaves []Task
+	walkTasks(tasks, func(task *Task, _ int) {
+		if !isSummary(*task) {
+			leaves = append(leaves, *task)
+		}
+	})
+	return leaves
+}
+
+// visibleTasks lists the rows the renderers draw: every task in order,
+// except the descendants of collapsed tasks
+func visibleTasks(tasks []Task) []taskRow {
+	var rows []taskRow
+	var walk func(tasks []Task, depth int)
+	walk = func(tasks []Task, depth int) {
+		for _, task := range tasks {
+			rows = append(rows, taskRow{task, depth})
+			if !task.Collapsed {
+				walk(task.Subtasks, depth+1)
+			}
+		}
+	}
+	walk(tasks, 0)
+	return rows
+}
+
+// validateSubtasks checks that task IDs are unique across the whole tree,
+// since dependencies, assignments and the stores refer to tasks by ID
+func validateSubtasks(chart *Chart) error {
+	var errs []error
+	seen := make(map[string]bool)
+	walkChartTasks(chart, func(task *Task, path string) {
+		if task.ID == "" {
+			return
+		}
+		if seen[task.ID] {
+			errs = append(errs, fieldErrorf(path+"/id", "task ID %q is used more than once", task.ID))
+		}
+		seen[task.ID] = true
+	})
+	return errors.Join(errs...)
+}
+
+// rollupSubtasks makes every parent task span its subtasks, from the
+// earliest start to the latest end, and derives its progress from theirs
+// weighted by duration. Each end keeps the form of the subtask it comes
+// from: a calendar date or a whole quarter of cal.
+func rollupSubtasks(tasks []Task, cal calendar) {
+	for i := range tasks {
+		task := &tasks[i]
+		if !isSummary(*task) {
+			continue
+		}
+		rollupSubtasks(task.Subtasks, cal)
+
+		var first, last *Task
+		var firstStart, lastEnd int64
+		var done, total float64
+		for j := range task.Subtasks {
+			sub := &task.Subtasks[j]
+			start, end, ok := taskDates(*sub, cal)
+			if !ok {
+				continue
+			}
+			if first == nil || start.Unix() < firstStart {
+				first, firstStart = sub, start.Unix()
+			}
+			if last == nil || end.Unix() > lastEnd {
+				last, lastEnd = sub, end.Unix()
+			}
+			days := end.Sub(start).Hours() / 24
+			done += days * float64(sub.Progress)
+			total += days
+		}
+		if first == nil {
+			continue
+		}
+
+		task.StartDate, task.StartYear, task.StartQ = first.StartDate, first.StartYear, first.StartQ
+		task.EndDate, task.EndYear, task.EndQ = last.EndDate, last.EndYear, last.EndQ
+		if total > 0 {
+			task.Progress = int(math.Round(done / total))
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// validateTags checks that every category and task tag is non-empty,
+// free of commas, which separate tags in export filters, and not repeated
+func validateTags(chart *Chart) error {
+	var errs []error
+	for i, cat := range chart.Categories {
+		if err := checkTags(fmt.Sprintf("/categories/%d/tags", i), fmt.Sprintf("category %q", cat.Name), cat.Tags); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	walkChartTasks(chart, func(task *Task, path string) {
+		if err := checkTags(path+"/tags", fmt.Sprintf("task %q", task.Title), task.Tags); err != nil {
+			errs = append(errs, err)
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// checkTags checks the tag list at path, reporting the first bad tag
+func checkTags(path, subject string, tags []string) error {
+	seen := make(map[string]bool, len(tags))
+	for i, tag := range tags {
+		tagPath := fmt.Sprintf("%s/%d", path, i)
+		switch {
+		case tag == "":
+			return fieldErrorf(tagPath, "%s has an empty tag", subject)
+		case strings.TrimSpace(tag) != tag:
+			return fieldErrorf(tagPath, "%s has tag %q with leading or trailing space", subject, tag)
+		case strings.Contains(tag, ","):
+			return fieldErrorf(tagPath, "%s has tag %q containing a comma", subject, tag)
+		case seen[tag]:
+			return fieldErrorf(tagPath, "%s lists tag %q twice", subject, tag)
+		}
+		seen[tag] = true
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const taskNotFoundMsg = "Task not found"
+
+// taskMove is the body of a move request. An empty categoryId keeps the
+// task in its category, an empty parentId makes it a top-level task, and
+// a nil position appends it.
+type taskMove struct {
+	CategoryID string `json:"categoryId"`
+	ParentID   string `json:"parentId"`
+	Position   *int   `json:"position"`
+}
+
+// findTask returns the list holding the task with the given ID, searching
+// subtasks too, and the task's index in it
+func findTask(tasks *[]Task, id string) (*[]Task, int) {
+	for i := range *tasks {
+		if (*tasks)[i].ID == id {
+			return tasks, i
+		}
+		if list, j := findTask(&(*tasks)[i].Subtasks, id); list != nil {
+			return list, j
+		}
+	}
+	return nil, -1
+}
+
+// removeDependencies drops every dependency on the given tasks
+func removeDependencies(chart *Chart, removed []Task) {
+	ids := make(map[string]bool, len(removed))
+	for _, task := range removed {
+		ids[task.ID] = true
+	}
+	for i := range chart.Categories {
+		walkTasks(chart.Categories[i].Tasks, func(task *Task, _ int) {
+			deps := task.Dependencies[:0]
+			for _, dep := range task.Dependencies {
+				if !ids[dep.TaskID] {
+					deps = append(deps, dep)
+				}
+			}
+			task.Dependencies = deps
+		})
+	}
+}
+
+// chartTask looks up the chart, category and task named in the request,
+// writing a 404 if any is missing
+func chartTask(w http.ResponseWriter, r *http.Request) (*Chart, *Task) {
+	chart, cat := chartCategory(w, r)
+	if cat == nil {
+		return nil, nil
+	}
+	list, i := findTask(&cat.Tasks, mux.Vars(r)["taskId"])
+	if list == nil {
+		http.Error(w, taskNotFoundMsg, http.StatusNotFound)
+		return nil, nil
+	}
+	return chart, &(*list)[i]
+}
+
+func listTasksHandler(w http.ResponseWriter, r *http.Request) {
+	chart, cat := chartCategory(w, r)
+	if cat == nil {
+		return
+	}
+
+	tasks := cat.Tasks
+	if tasks == nil {
+		tasks = []Task{}
+	}
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	w.Header().Set(etagHeader, chartETag(chart))
+	json.NewEncoder(w).Encode(tasks)
+}
+
+func getTaskHandler(w http.ResponseWriter, r *http.Request) {
+	chart, task := chartTask(w, r)
+	if task == nil {
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	w.Header().Set(etagHeader, chartETag(chart))
+	json.NewEncoder(w).Encode(task)
+}
+
+// createTaskHandler appends a task to a category, or with ?parent=<taskId>
+// to the subtasks of one of its tasks
+func createTaskHandler(w http.ResponseWriter, r *http.Request) {
+	var task Task
+	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	catID := mux.Vars(r)["catId"]
+	parentID := r.URL.Query().Get("parent")
+
+	chart, ok := modifyChart(w, r, fmt.Sprintf("Added task %q", task.Title), func(chart *Chart) error {
+		i := categoryIndex(chart, catID)
+		if i < 0 {
+			return &httpError{http.StatusNotFound, categoryNotFoundMsg}
+		}
+		if task.ID == "" {
+			task.ID = uuid.New().String()
+		} else if chartTasks(chart)[task.ID] != nil {
+			return &httpError{http.StatusConflict, fmt.Sprintf("task %q already exists", task.ID)}
+		}
+
+		list := &chart.Categories[i].Tasks
+		if parentID != "" {
+			parents, j := findTask(list, parentID)
+			if parents == nil {
+				return fmt.Errorf("parent task %q is not in category %q", parentID, catID)
+			}
+			list = &(*parents)[j].Subtasks
+		}
+		*list = append(*list, task)
+		return nil
+	})
+	if !ok {
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(chartTasks(chart)[task.ID])
+}
+
+// updateTaskHandler patches a task like a chart: members of a merge
+// patch replace the task's, including its whole subtask list if given
+func updateTaskHandler(w http.ResponseWriter, r *http.Request) {
+	apply, ok := readPatch(w, r)
+	if !ok {
+		return
+	}
+	vars := mux.Vars(r)
+	id := vars["taskId"]
+
+	chart, ok := modifyChart(w, r, "Updated task "+id, func(chart *Chart) error {
+		i := categoryIndex(chart, vars["catId"])
+		if i < 0 {
+			return &httpError{http.StatusNotFound, categoryNotFoundMsg}
+		}
+		list, j := findTask(&chart.Categories[i].Tasks, id)
+		if list == nil {
+			return &httpError{http.StatusNotFound, taskNotFoundMsg}
+		}
+		var task Task
+		if err := patchItem((*list)[j], apply, &task); err != nil {
+			return err
+		}
+		task.ID = id
+		(*list)[j] = task
+		return nil
+	})
+	if !ok {
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	json.NewEncoder(w).Encode(chartTasks(chart)[id])
+}
+
+// deleteTaskHandler removes a task and its subtasks, and drops
+// dependencies on any of them
+func deleteTaskHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["taskId"]
+
+	_, ok := modifyChart(w, r, "Deleted task "+id, func(chart *Chart) error {
+		i := categoryIndex(chart, vars["catId"])
+		if i < 0 {
+			return &httpError{http.StatusNotFound, categoryNotFoundMsg}
+		}
+		list, j := findTask(&chart.Categories[i].Tasks, id)
+		if list == nil {
+			return &httpError{http.StatusNotFound, taskNotFoundMsg}
+		}
+		removed := allTasks((*list)[j : j+1])
+		*list = append((*list)[:j], (*list)[j+1:]...)
+		removeDependencies(chart, removed)
+		return nil
+	})
+	if !ok {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// moveTaskHandler moves a task, with its subtasks, to another position in
+// its list, under another parent or into another category
+func moveTaskHandler(w http.ResponseWriter, r *http.Request) {
+	var move taskMove
+	if err := json.NewDecoder(r.Body).Decode(&move); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	vars := mux.Vars(r)
+	id := vars["taskId"]
+	if move.CategoryID == "" {
+		move.CategoryID = vars["catId"]
+	}
+
+	chart, ok := modifyChart(w, r, "Moved task "+id, func(chart *Chart) error {
+		i := categoryIndex(chart, vars["catId"])
+		if i < 0 {
+			return &httpError{http.StatusNotFound, categoryNotFoundMsg}
+		}
+		list, j := findTask(&chart.Categories[i].Tasks, id)
+		if list == nil {
+			return &httpError{http.StatusNotFound, taskNotFoundMsg}
+		}
+		task := (*list)[j]
+		if move.ParentID == id {
+			return errors.New("a task cannot be moved under itself")
+		}
+		if sub, _ := findTask(&task.Subtasks, move.ParentID); sub != nil {
+			return fmt.Errorf("task %q cannot be moved under its own subtask %q", id, move.ParentID)
+		}
+		target := categoryIndex(chart, move.CategoryID)
+		if target < 0 {
+			return fmt.Errorf("unknown category %q", move.CategoryID)
+		}
+
+		// Take the task out before finding its destination, which may be
+		// in the same list and shift
+		*list = append((*list)[:j], (*list)[j+1:]...)
+		dest := &chart.Categories[target].Tasks
+		if move.ParentID != "" {
+			parents, k := findTask(dest, move.ParentID)
+			if parents == nil {
+				return fmt.Errorf("parent task %q is not in category %q", move.ParentID, move.CategoryID)
+			}
+			dest = &(*parents)[k].Subtasks
+		}
+
+		pos := len(*dest)
+		if move.Position != nil {
+			pos = *move.Position
+			if pos < 0 || pos > len(*dest) {
+				return fmt.Errorf("position %d is out of range (want 0-%d)", pos, len(*dest))
+			}
+		}
+		*dest = append(*dest, Task{})
+		copy((*dest)[pos+1:], (*dest)[pos:])
+		(*dest)[pos] = task
+		return nil
+	})
+	if !ok {
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	json.NewEncoder(w).Encode(chartTasks(chart)[id])
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Timeline granularities. An empty Chart.Granularity means quarters.
+const (
+	granularityQuarter = "quarter"
+	granularityMonth   = "month"
+	granularityWeek    = "week"
+	granularityDay     = "day"
+)
+
+// dateLayout is the format of Task.StartDate and Task.EndDate
+const dateLayout = "2006-01-02"
+
+// maxTimelineColumns bounds the work done for nonsensical chart ranges;
+// export limits reject anything this large long before it is reached
+const maxTimelineColumns = 10000
+
+// timelineColumn is one unit of the chart's horizontal axis
+type timelineColumn struct {
+	start time.Time // inclusive
+	end   time.Time // exclusive
+	label string
+	group string // label of the enclosing header group, empty if none
+}
+
+// timelineGroup is a run of adjacent columns sharing a group label
+type timelineGroup struct {
+	label string
+	first int
+	count int
+}
+
+type timeline struct {
+	granularity string
+	cal         calendar
+	columns     []timelineColumn
+}
+
+// chartGranularity returns the chart's granularity, treating empty and
+// unknown values as quarters
+func chartGranularity(chart *Chart) string {
+	switch chart.Granularity {
+	case granularityMonth, granularityWeek, granularityDay:
+		return chart.Granularity
+	}
+	return granularityQuarter
+}
+
+func parseDate(s string) (time.Time, bool) {
+	if s == "" {
+		return time.Time{}, false
+	}
+	d, err := time.Parse(dateLayout, s)
+	return d, err == nil
+}
+
+// buildTimeline lays out the columns covering the chart's quarter range
+// at the chart's granularity, in the chart's calendar
+func buildTimeline(chart *Chart) *timeline {
+	t := &timeline{granularity: chartGranularity(chart), cal: chartCalendar(chart)}
+	if chart.StartQ < 1 || chart.StartQ > 4 || chart.EndQ < 1 || chart.EndQ > 4 {
+		return t
+	}
+
+	start := t.cal.quarterStart(chart.StartYear, chart.StartQ)
+	end := t.cal.quarterStart(chart.EndYear, chart.EndQ).AddDate(0, 3, 0)
+
+	switch t.granularity {
+	case granularityMonth:
+		for d := start; d.Before(end) && len(t.columns) < maxTimelineColumns; d = d.AddDate(0, 1, 0) {
+			t.columns = append(t.columns, timelineColumn{
+				start: d,
+				end:   d.AddDate(0, 1, 0),
+				label: d.Format("Jan"),
+				group: t.cal.quarterLabel(t.cal.quarterOf(d)),
+			})
+		}
+	case granularityWeek:
+		// ISO weeks start on Monday
+		d := start.AddDate(0, 0, -((int(start.Weekday()) + 6) % 7))
+		for ; d.Before(end) && len(t.columns) < maxTimelineColumns; d = d.AddDate(0, 0, 7) {
+			_, week := d.ISOWeek()
+			t.columns = append(t.columns, timelineColumn{
+				start: d,
+				end:   d.AddDate(0, 0, 7),
+				label: fmt.Sprintf("W%02d", week),
+				// Like ISO week numbering, a week belongs to the month of its Thursday
+				group: d.AddDate(0, 0, 3).Format("Jan 2006"),
+			})
+		}
+	case granularityDay:
+		for d := start; d.Before(end) && len(t.columns) < maxTimelineColumns; d = d.AddDate(0, 0, 1) {
+			t.columns = append(t.columns, timelineColumn{
+				start: d,
+				end:   d.AddDate(0, 0, 1),
+				label: d.Format("2"),
+				group: d.Format("Jan 2006"),
+			})
+		}
+	default:
+		for _, q := range calculateQuarters(chart.StartYear, chart.StartQ, chart.EndYear, chart.EndQ) {
+			if len(t.columns) >= maxTimelineColumns {
+				break
+			}
+			qs := t.cal.quarterStart(q.year, q.quarter)
+			t.columns = append(t.columns, timelineColumn{
+				start: qs,
+				end:   qs.AddDate(0, 3, 0),
+				label: t.cal.quarterLabel(q.year, q.quarter),
+			})
+		}
+	}
+
+	return t
+}
+
+// groups returns the header groups above the columns, or nil if the
+// granularity has none
+func (t *timeline) groups() []timelineGroup {
+	var groups []timelineGroup
+	for i, col := range t.columns {
+		if col.group == "" {
+			return nil
+		}
+		if len(groups) > 0 && groups[len(groups)-1].label == col.group {
+			groups[len(groups)-1].count++
+			continue
+		}
+		groups = append(groups, timelineGroup{label: col.group, first: i, count: 1})
+	}
+	return groups
+}
+
+// columnAt returns the index of the column containing d, or -1
+func (t *timeline) columnAt(d time.Time) int {
+	for i, col := range t.columns {
+		if !d.Before(col.start) && d.Before(col.end) {
+			return i
+		}
+	}
+	return -1
+}
+
+// taskDates returns the half-open interval [start, end) a task covers,
+// reading its quarters in the given calendar. Calendar dates take
+// precedence over quarters; EndDate is inclusive.
+func taskDates(task Task, cal calendar) (time.Time, time.Time, bool) {
+	var start, end time.Time
+	if d, ok := parseDate(task.StartDate); ok {
+		start = d
+	} else if task.StartQ >= 1 && task.StartQ <= 4 {
+		start = cal.quarterStart(task.StartYear, task.StartQ)
+	} else {
+		return start, end, false
+	}
+
+	if d, ok := parseDate(task.EndDate); ok {
+		end = d.AddDate(0, 0, 1)
+	} else if task.EndQ >= 1 && task.EndQ <= 4 {
+		end = cal.quarterStart(task.EndYear, task.EndQ).AddDate(0, 3, 0)
+	} else {
+		return start, end, false
+	}
+
+	return start, end, end.After(start)
+}
+
+// position converts an instant to a horizontal position in column units:
+// the index of the column containing it plus the fraction of that column
+// already elapsed. It returns -1 if d lies outside the timeline.
+func (t *timeline) position(d time.Time) float64 {
+	idx := t.columnAt(d)
+	if idx < 0 {
+		return -1
+	}
+	col := t.columns[idx]
+	return float64(idx) + float64(d.Sub(col.start))/float64(col.end.Sub(col.start))
+}
+
+// taskSpan returns where a task's bar starts and ends, in column units.
+// Quarter-only tasks cover whole columns; tasks with calendar dates are
+// placed proportionally within them. Tasks starting or ending outside the
+// timeline are not drawn.
+func (t *timeline) taskSpan(task Task) (float64, float64, bool) {
+	start, end, ok := taskDates(task, t.cal)
+	if !ok {
+		return -1, -1, false
+	}
+	startPos := t.position(start)
+	lastPos := t.position(end.Add(-time.Nanosecond))
+	if startPos < 0 || lastPos < 0 {
+		return -1, -1, false
+	}
+
+	endPos := float64(len(t.columns))
+	if idx := t.columnAt(end); idx >= 0 {
+		endPos = t.position(end)
+	}
+	return startPos, endPos, true
+}
+
+// syncTaskQuarters derives a task's quarter fields from its calendar
+// dates, so quarter-based consumers agree with the dates
+func syncTaskQuarters(task *Task, cal calendar) {
+	if d, ok := parseDate(task.StartDate); ok {
+		task.StartYear, task.StartQ = cal.quarterOf(d)
+	}
+	if d, ok := parseDate(task.EndDate); ok {
+		task.EndYear, task.EndQ = cal.quarterOf(d)
+	}
+}
+
+type quarterInfo struct {
+	year    int
+	quarter int
+}
+
+func calculateQuarters(startYear, startQ, endYear, endQ int) []quarterInfo {
+	var quarters []quarterInfo
+
+	for year := startYear; year <= endYear; year++ {
+		startQuarter := 1
+		endQuarter := 4
+
+		if year == startYear {
+			startQuarter = startQ
+		}
+		if year == endYear {
+			endQuarter = endQ
+		}
+
+		for q := startQuarter; q <= endQuarter; q++ {
+			quarters = append(quarters, quarterInfo{year: year, quarter: q})
+		}
+	}
+
+	return quarters
+}
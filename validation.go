@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// problemContentType is the media type of RFC 7807 problem details
+const problemContentType = "application/problem+json"
+
+// Limits on the years a chart or task may use
+const (
+	minYear = 1900
+	maxYear = 2999
+)
+
+// hexColorPattern matches the #rrggbb colours the renderers understand
+var hexColorPattern = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// fieldError is a validation failure at one field of a chart. Path is a
+// JSON Pointer into the chart, e.g. /categories/0/tasks/2/endQuarter, and
+// is empty when the failure concerns the chart as a whole.
+type fieldError struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+func (e *fieldError) Error() string {
+	return e.Reason
+}
+
+func fieldErrorf(path, format string, args ...interface{}) error {
+	return &fieldError{Path: path, Reason: fmt.Sprintf(format, args...)}
+}
+
+// problem is an RFC 7807 problem details body, extended with the failing
+// fields
+type problem struct {
+	Type   string       `json:"type"`
+	Title  string       `json:"title"`
+	Status int          `json:"status"`
+	Detail string       `json:"detail,omitempty"`
+	Errors []fieldError `json:"errors,omitempty"`
+}
+
+// fieldErrors flattens a validation error, which may join many, into its
+// field errors. Errors without a field apply to the whole chart.
+func fieldErrors(err error) []fieldError {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var list []fieldError
+		for _, e := range joined.Unwrap() {
+			list = append(list, fieldErrors(e)...)
+		}
+		return list
+	}
+	var fe *fieldError
+	if errors.As(err, &fe) {
+		return []fieldError{*fe}
+	}
+	return []fieldError{{Reason: err.Error()}}
+}
+
+// writeValidationProblem writes a 422 problem+json body listing every
+// field that failed validation
+func writeValidationProblem(w http.ResponseWriter, err error) {
+	list := fieldErrors(err)
+	detail := "1 field is invalid"
+	if len(list) != 1 {
+		detail = fmt.Sprintf("%d fields are invalid", len(list))
+	}
+	w.Header().Set(contentTypeHeader, problemContentType)
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(http.StatusUnprocessableEntity),
+		Status: http.StatusUnprocessableEntity,
+		Detail: detail,
+		Errors: list,
+	})
+}
+
+// pointerToken escapes a JSON Pointer reference token
+func pointerToken(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
+
+// walkChartTasks calls fn for every task and subtask of the chart with
+// its JSON Pointer, parents before their children
+func walkChartTasks(chart *Chart, fn func(task *Task, path string)) {
+	var walk func(tasks []Task, path string)
+	walk = func(tasks []Task, path string) {
+		for i := range tasks {
+			taskPath := fmt.Sprintf("%s/%d", path, i)
+			fn(&tasks[i], taskPath)
+			walk(tasks[i].Subtasks, taskPath+"/subtasks")
+		}
+	}
+	for i := range chart.Categories {
+		walk(chart.Categories[i].Tasks, fmt.Sprintf("/categories/%d/tasks", i))
+	}
+}
+
+// taskPaths indexes the JSON Pointer of every task in the chart by ID
+func taskPaths(chart *Chart) map[string]string {
+	paths := make(map[string]string)
+	walkChartTasks(chart, func(task *Task, path string) {
+		paths[task.ID] = path
+	})
+	return paths
+}
+
+// validateRange checks the chart's quarter range
+func validateRange(chart *Chart) error {
+	var errs []error
+	errs = append(errs, checkQuarter("", "chart", "start", chart.StartYear, chart.StartQ)...)
+	errs = append(errs, checkQuarter("", "chart", "end", chart.EndYear, chart.EndQ)...)
+	if len(errs) == 0 && quarterIndex(chart.EndYear, chart.EndQ) < quarterIndex(chart.StartYear, chart.StartQ) {
+		errs = append(errs, fieldErrorf("/endYear", "chart ends in %d Q%d, before it starts in %d Q%d", chart.EndYear, chart.EndQ, chart.StartYear, chart.StartQ))
+	}
+	return errors.Join(errs...)
+}
+
+// checkQuarter checks the year and quarter fields of one end, "start" or
+// "end", of the chart or task at path
+func checkQuarter(path, subject, end string, year, quarter int) []error {
+	var errs []error
+	if year < minYear || year > maxYear {
+		errs = append(errs, fieldErrorf(path+"/"+end+"Year", "%s %s year %d is out of range (want %d-%d)", subject, end, year, minYear, maxYear))
+	}
+	if quarter < 1 || quarter > 4 {
+		errs = append(errs, fieldErrorf(path+"/"+end+"Quarter", "%s %s quarter %d is out of range (want 1-4)", subject, end, quarter))
+	}
+	return errs
+}
+
+// chartSpan returns the half-open interval the chart's range covers. It
+// reports false if the range is invalid.
+func chartSpan(chart *Chart) (time.Time, time.Time, bool) {
+	if validateRange(chart) != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	cal := chartCalendar(chart)
+	return cal.quarterStart(chart.StartYear, chart.StartQ), cal.quarterStart(chart.EndYear, chart.EndQ).AddDate(0, 3, 0), true
+}
+
+// validateTaskDates checks that every task has well-formed start and end
+// quarters or dates, ends after it starts and lies within the chart's
+// range, which the renderers otherwise silently leave it out of. Summary
+// tasks take their dates from their subtasks and are skipped.
+func validateTaskDates(chart *Chart) error {
+	cal := chartCalendar(chart)
+	first, last, rangeOK := chartSpan(chart)
+
+	var errs []error
+	walkChartTasks(chart, func(task *Task, path string) {
+		if isSummary(*task) {
+			return
+		}
+		var bad []error
+		for _, end := range []struct {
+			name, date    string
+			year, quarter int
+		}{
+			{"start", task.StartDate, task.StartYear, task.StartQ},
+			{"end", task.EndDate, task.EndYear, task.EndQ},
+		} {
+			if end.date == "" {
+				bad = append(bad, checkQuarter(path, fmt.Sprintf("task %q", task.Title), end.name, end.year, end.quarter)...)
+			} else if d, ok := parseDate(end.date); !ok || d.Year() < minYear || d.Year() > maxYear {
+				bad = append(bad, fieldErrorf(path+"/"+end.name+"Date", "task %q has invalid %s date %q (want YYYY-MM-DD)", task.Title, end.name, end.date))
+			}
+		}
+		if len(bad) > 0 {
+			errs = append(errs, bad...)
+			return
+		}
+
+		start, stop, ok := taskDates(*task, cal)
+		switch {
+		case !ok:
+			errs = append(errs, fieldErrorf(path, "task %q ends before it starts", task.Title))
+		case rangeOK && (start.Before(first) || stop.After(last)):
+			errs = append(errs, fieldErrorf(path, "task %q runs from %s to %s, outside the chart's range %s to %s",
+				task.Title, start.Format(dateLayout), stop.AddDate(0, 0, -1).Format(dateLayout), first.Format(dateLayout), last.AddDate(0, 0, -1).Format(dateLayout)))
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// validateColors checks that every colour is a #rrggbb hex colour
+func validateColors(chart *Chart) error {
+	var errs []error
+	check := func(path, color string) {
+		if color != "" && !hexColorPattern.MatchString(color) {
+			errs = append(errs, fieldErrorf(path, "invalid colour %q (want #rrggbb)", color))
+		}
+	}
+	for i, cat := range chart.Categories {
+		check(fmt.Sprintf("/categories/%d/color", i), cat.Color)
+	}
+	walkChartTasks(chart, func(task *Task, path string) {
+		check(path+"/color", task.Color)
+	})
+	for i, m := range chart.Milestones {
+		check(fmt.Sprintf("/milestones/%d/color", i), m.Color)
+	}
+	return errors.Join(errs...)
+}
+
+// validateIDs checks that category, milestone and baseline IDs are unique.
+// Task IDs are checked by validateSubtasks. Empty IDs are assigned later.
+func validateIDs(chart *Chart) error {
+	var errs []error
+	check := func(list, kind string, ids []string) {
+		seen := make(map[string]bool, len(ids))
+		for i, id := range ids {
+			if id != "" && seen[id] {
+				errs = append(errs, fieldErrorf(fmt.Sprintf("/%s/%d/id", list, i), "%s ID %q is used more than once", kind, id))
+			}
+			seen[id] = true
+		}
+	}
+
+	ids := make([]string, len(chart.Categories))
+	for i, cat := range chart.Categories {
+		ids[i] = cat.ID
+	}
+	check("categories", "category", ids)
+	ids = make([]string, len(chart.Milestones))
+	for i, m := range chart.Milestones {
+		ids[i] = m.ID
+	}
+	check("milestones", "milestone", ids)
+	ids = make([]string, len(chart.Baselines))
+	for i, b := range chart.Baselines {
+		ids[i] = b.ID
+	}
+	check("baselines", "baseline", ids)
+	return errors.Join(errs...)
+}
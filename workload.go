@@ -0,0 +1,490 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// overloadColor outlines heatmap cells above capacity
+const overloadColor = "#c0392b"
+
+// noTeam labels people without a team in the team rows
+const noTeam = "(no team)"
+
+// WorkloadReport counts how many tasks each person and team is carrying
+// in each quarter across every chart
+type WorkloadReport struct {
+	// Quarters labels the report's columns, e.g. "Q3 2025"
+	Quarters []string `json:"quarters"`
+	// Capacity is how many parallel tasks one person can carry per quarter
+	Capacity int           `json:"capacity"`
+	People   []WorkloadRow `json:"people"`
+	Teams    []WorkloadRow `json:"teams"`
+
+	startYear, startQ, endYear, endQ int
+}
+
+// WorkloadRow is the load of one person or team, one cell per quarter
+type WorkloadRow struct {
+	ID   string `json:"id,omitempty"` // person ID; empty for teams
+	Name string `json:"name"`
+	Team string `json:"team,omitempty"`
+	// Capacity is the report capacity, times the member count for teams
+	Capacity int            `json:"capacity"`
+	Cells    []WorkloadCell `json:"cells"`
+	// Overallocated lists the quarters whose load exceeds Capacity
+	Overallocated []string `json:"overallocated,omitempty"`
+}
+
+// WorkloadCell is the load of a person or team in one quarter
+type WorkloadCell struct {
+	Quarter string        `json:"quarter"`
+	Load    int           `json:"load"`
+	Tasks   []WorkloadRef `json:"tasks,omitempty"`
+}
+
+// WorkloadRef identifies a task counted in a cell
+type WorkloadRef struct {
+	ChartID string `json:"chartId"`
+	TaskID  string `json:"taskId"`
+	Title   string `json:"title"`
+}
+
+// quarterIndex numbers quarters consecutively so spans can be compared
+func quarterIndex(year, quarter int) int {
+	return year*4 + quarter - 1
+}
+
+// parseQuarter reads a quarter written as 2025-Q3
+func parseQuarter(s string) (int, int, error) {
+	year, q, ok := strings.Cut(s, "-Q")
+	y, yerr := strconv.Atoi(year)
+	n, qerr := strconv.Atoi(q)
+	if !ok || yerr != nil || qerr != nil || n < 1 || n > 4 {
+		return 0, 0, fmt.Errorf("invalid quarter %q (want e.g. 2025-Q3)", s)
+	}
+	return y, n, nil
+}
+
+// taskOwners returns who carries a task: its assignees, or else its
+// category's owner, or else its chart's owner
+func taskOwners(chart *Chart, cat Category, task Task) []string {
+	switch {
+	case len(task.Assignees) > 0:
+		return task.Assignees
+	case cat.Owner != "":
+		return []string{cat.Owner}
+	case chart.Owner != "":
+		return []string{chart.Owner}
+	}
+	return nil
+}
+
+// computeWorkload counts, for every quarter, the tasks each person and
+// team is carrying. A task loads every quarter it spans by one, for each
+// of its owners and once for each of their teams; cancelled tasks are
+// ignored. Without an explicit window the report covers every counted
+// task.
+func computeWorkload(charts []*Chart, people []*Person, capacity int, from, to *quarterInfo) *WorkloadReport {
+	type span struct {
+		ref        WorkloadRef
+		first, end int // quarter indexes, end inclusive
+		owners     []string
+	}
+
+	byID := make(map[string]*Person, len(people))
+	for _, p := range people {
+		byID[p.ID] = p
+	}
+
+	sort.Slice(charts, func(i, j int) bool {
+		if charts[i].Title != charts[j].Title {
+			return charts[i].Title < charts[j].Title
+		}
+		return charts[i].ID < charts[j].ID
+	})
+
+	var spans []span
+	first, last := math.MaxInt, math.MinInt
+	for _, chart := range charts {
+		cal := chartCalendar(chart)
+		for _, cat := range chart.Categories {
+			// Summary tasks only restate the load of their subtasks
+			for _, task := range leafTasks(cat.Tasks) {
+				if task.Status == statusCancelled {
+					continue
+				}
+				// The report is in calendar quarters whatever the chart's
+				// fiscal calendar
+				start, end, ok := taskDates(task, cal)
+				if !ok {
+					continue
+				}
+				startYear, startQ := calendarYear.quarterOf(start)
+				endYear, endQ := calendarYear.quarterOf(end.Add(-time.Nanosecond))
+				var owners []string
+				for _, id := range taskOwners(chart, cat, task) {
+					if byID[id] != nil {
+						owners = append(owners, id)
+					}
+				}
+				s := span{
+					ref:    WorkloadRef{chart.ID, task.ID, task.Title},
+					first:  quarterIndex(startYear, startQ),
+					end:    quarterIndex(endYear, endQ),
+					owners: owners,
+				}
+				if len(owners) == 0 || s.end < s.first {
+					continue
+				}
+				spans = append(spans, s)
+				first, last = min(first, s.first), max(last, s.end)
+			}
+		}
+	}
+	if from != nil {
+		first = quarterIndex(from.year, from.quarter)
+	}
+	if to != nil {
+		last = quarterIndex(to.year, to.quarter)
+	}
+
+	report := &WorkloadReport{Quarters: []string{}, Capacity: capacity, People: []WorkloadRow{}, Teams: []WorkloadRow{}}
+	if first > last || last-first >= maxTimelineColumns {
+		return report
+	}
+	report.startYear, report.startQ = first/4, first%4+1
+	report.endYear, report.endQ = last/4, last%4+1
+	for i := first; i <= last; i++ {
+		report.Quarters = append(report.Quarters, calendarYear.quarterLabel(i/4, i%4+1))
+	}
+
+	newRow := func(id, name, team string, capacity int) *WorkloadRow {
+		row := &WorkloadRow{ID: id, Name: name, Team: team, Capacity: capacity, Cells: make([]WorkloadCell, len(report.Quarters))}
+		for i := range row.Cells {
+			row.Cells[i].Quarter = report.Quarters[i]
+		}
+		return row
+	}
+
+	teamSize := make(map[string]int)
+	for _, p := range people {
+		teamSize[teamName(p)]++
+	}
+
+	personRows := make(map[string]*WorkloadRow)
+	teamRows := make(map[string]*WorkloadRow)
+	for _, s := range spans {
+		teams := make(map[string]bool)
+		for _, id := range s.owners {
+			p := byID[id]
+			if personRows[id] == nil {
+				personRows[id] = newRow(p.ID, p.Name, p.Team, capacity)
+			}
+			addLoad(personRows[id], s.ref, s.first-first, s.end-first)
+			teams[teamName(p)] = true
+		}
+		for team := range teams {
+			if teamRows[team] == nil {
+				teamRows[team] = newRow("", team, "", capacity*teamSize[team])
+			}
+			addLoad(teamRows[team], s.ref, s.first-first, s.end-first)
+		}
+	}
+
+	for _, row := range personRows {
+		report.People = append(report.People, *flagOverallocation(row))
+	}
+	for _, row := range teamRows {
+		report.Teams = append(report.Teams, *flagOverallocation(row))
+	}
+	sort.Slice(report.People, func(i, j int) bool {
+		if report.People[i].Name != report.People[j].Name {
+			return report.People[i].Name < report.People[j].Name
+		}
+		return report.People[i].ID < report.People[j].ID
+	})
+	sort.Slice(report.Teams, func(i, j int) bool { return report.Teams[i].Name < report.Teams[j].Name })
+	return report
+}
+
+func teamName(p *Person) string {
+	if p.Team == "" {
+		return noTeam
+	}
+	return p.Team
+}
+
+// addLoad counts a task in the row's cells from first to end inclusive,
+// clipped to the report window
+func addLoad(row *WorkloadRow, ref WorkloadRef, first, end int) {
+	for i := max(first, 0); i <= end && i < len(row.Cells); i++ {
+		row.Cells[i].Load++
+		row.Cells[i].Tasks = append(row.Cells[i].Tasks, ref)
+	}
+}
+
+func flagOverallocation(row *WorkloadRow) *WorkloadRow {
+	for _, cell := range row.Cells {
+		if cell.Load > row.Capacity {
+			row.Overallocated = append(row.Overallocated, cell.Quarter)
+		}
+	}
+	return row
+}
+
+// heatColor shades a cell from pale green at light load to amber at capacity,
+// and red above it; empty cells stay white
+func heatColor(load, capacity int) color.RGBA {
+	if load == 0 {
+		return color.RGBA{255, 255, 255, 255}
+	}
+	if load > capacity {
+		return color.RGBA{241, 148, 138, 255}
+	}
+	t := float64(load) / float64(max(capacity, 1))
+	mix := func(a, b uint8) uint8 {
+		return uint8(math.Round(float64(a) + (float64(b)-float64(a))*t))
+	}
+	return color.RGBA{mix(213, 248), mix(245, 196), mix(227, 113), 255}
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// timeline returns the quarter columns of a report, laid out like
+// a chart with the same range
+func (report *WorkloadReport) timeline() *timeline {
+	if len(report.Quarters) == 0 {
+		return &timeline{granularity: granularityQuarter}
+	}
+	return buildTimeline(&Chart{StartYear: report.startYear, StartQ: report.startQ, EndYear: report.endYear, EndQ: report.endQ})
+}
+
+// GenerateWorkloadSVG renders a report as a heatmap below the same
+// quarter header as a chart: one row per person, then one per team
+func GenerateWorkloadSVG(report *WorkloadReport) string {
+	tl := report.timeline()
+	config := svgLayoutConfig{
+		headerHeight:         80,
+		baseRowHeight:        30,
+		columnWidth:          svgColumnWidths[granularityQuarter],
+		labelWidth:           200,
+		padding:              20,
+		categoryHeaderHeight: 35,
+	}
+
+	rows := len(report.People) + len(report.Teams)
+	width := config.labelWidth + len(tl.columns)*config.columnWidth + config.padding*2
+	height := config.headerHeight + 2*config.categoryHeaderHeight + rows*config.baseRowHeight + config.padding*2
+
+	var buf bytes.Buffer
+	ctx := &svgRenderContext{buf: &buf, timeline: tl, totalColumns: len(tl.columns), config: config}
+	ctx.writeSVGHeader(width, height)
+	ctx.writeBackground(width, height)
+	ctx.writeTitle(fmt.Sprintf("Workload (capacity %d per person)", report.Capacity))
+	ctx.writeTimelineHeaders(height)
+
+	y := ctx.writeWorkloadSection("People", report.People, config.headerHeight)
+	ctx.writeWorkloadSection("Teams", report.Teams, y)
+	buf.WriteString(`</svg>`)
+	return buf.String()
+}
+
+func (ctx *svgRenderContext) writeWorkloadSection(title string, rows []WorkloadRow, y int) int {
+	cfg := ctx.config
+	ctx.buf.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" fill="#95a5a6" opacity="0.3"/>`,
+		cfg.padding, y, cfg.labelWidth, cfg.categoryHeaderHeight))
+	ctx.buf.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="category">%s</text>`, cfg.padding+10, y+22, title))
+	y += cfg.categoryHeaderHeight
+
+	for _, row := range rows {
+		ctx.buf.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" fill="#fff" stroke="#ddd" stroke-width="1"/>`,
+			cfg.padding, y, cfg.labelWidth, cfg.baseRowHeight))
+		label := row.Name
+		if row.Team != "" {
+			label += " · " + row.Team
+		}
+		ctx.buf.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="label">%s</text>`,
+			cfg.padding+10, y+cfg.baseRowHeight/2+4, escapeXML(truncate(label, 28))))
+
+		for i, cell := range row.Cells {
+			x := cfg.padding + cfg.labelWidth + i*cfg.columnWidth
+			ctx.buf.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" fill="%s" stroke="#ddd" stroke-width="1"/>`,
+				x, y, cfg.columnWidth, cfg.baseRowHeight, hexColor(heatColor(cell.Load, row.Capacity))))
+			if cell.Load > row.Capacity {
+				ctx.buf.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" fill="none" stroke="%s" stroke-width="2"/>`,
+					x+1, y+1, cfg.columnWidth-2, cfg.baseRowHeight-2, overloadColor))
+			}
+			if cell.Load > 0 {
+				ctx.buf.WriteString(fmt.Sprintf(`<text x="%d" y="%d" class="label" text-anchor="middle">%d / %d</text>`,
+					x+cfg.columnWidth/2, y+cfg.baseRowHeight/2+4, cell.Load, row.Capacity))
+			}
+		}
+		y += cfg.baseRowHeight
+	}
+	return y
+}
+
+// GenerateWorkloadPNG renders a report as a PNG heatmap laid out like
+// GenerateWorkloadSVG
+func GenerateWorkloadPNG(report *WorkloadReport) ([]byte, error) {
+	tl := report.timeline()
+	config := pngLayoutConfig{
+		headerHeight:         80,
+		rowHeight:            30,
+		columnWidth:          svgColumnWidths[granularityQuarter],
+		labelWidth:           200,
+		padding:              20,
+		categoryHeaderHeight: 35,
+	}
+
+	rows := len(report.People) + len(report.Teams)
+	width := config.labelWidth + len(tl.columns)*config.columnWidth + config.padding*2
+	height := config.headerHeight + 2*config.categoryHeaderHeight + rows*config.rowHeight + config.padding*2
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.RGBA{250, 250, 250, 255}}, image.Point{}, draw.Src)
+
+	ctx := &pngRenderContext{img: img, timeline: tl, totalColumns: len(tl.columns), config: config}
+	ctx.drawTimelineHeaders(height)
+	y := ctx.drawWorkloadSection("People", report.People, config.headerHeight)
+	ctx.drawWorkloadSection("Teams", report.Teams, y)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (ctx *pngRenderContext) drawWorkloadSection(title string, rows []WorkloadRow, y int) int {
+	cfg := ctx.config
+	text := color.RGBA{51, 51, 51, 255}
+	border := color.RGBA{221, 221, 221, 255}
+
+	drawRect(ctx.img, cfg.padding, y, cfg.labelWidth, cfg.categoryHeaderHeight, color.RGBA{213, 219, 219, 255})
+	drawText(ctx.img, cfg.padding+10, y+22, title, text)
+	y += cfg.categoryHeaderHeight
+
+	for _, row := range rows {
+		drawRect(ctx.img, cfg.padding, y, cfg.labelWidth, cfg.rowHeight, color.RGBA{255, 255, 255, 255})
+		drawRectBorder(ctx.img, cfg.padding, y, cfg.labelWidth, cfg.rowHeight, border)
+		label := row.Name
+		if row.Team != "" {
+			label += " - " + row.Team
+		}
+		drawText(ctx.img, cfg.padding+10, y+cfg.rowHeight/2+4, truncate(label, 26), text)
+
+		for i, cell := range row.Cells {
+			x := cfg.padding + cfg.labelWidth + i*cfg.columnWidth
+			drawRect(ctx.img, x, y, cfg.columnWidth, cfg.rowHeight, heatColor(cell.Load, row.Capacity))
+			drawRectBorder(ctx.img, x, y, cfg.columnWidth, cfg.rowHeight, border)
+			if cell.Load > row.Capacity {
+				over := parseColor(overloadColor)
+				drawRectBorder(ctx.img, x+1, y+1, cfg.columnWidth-2, cfg.rowHeight-2, over)
+				drawRectBorder(ctx.img, x+2, y+2, cfg.columnWidth-4, cfg.rowHeight-4, over)
+			}
+			if cell.Load > 0 {
+				s := fmt.Sprintf("%d / %d", cell.Load, row.Capacity)
+				drawText(ctx.img, x+(cfg.columnWidth-textWidth(s))/2, y+cfg.rowHeight/2+4, s, text)
+			}
+		}
+		y += cfg.rowHeight
+	}
+	return y
+}
+
+// workloadRequest builds the report for a workload request from its
+// capacity, from and to query parameters. It writes the error response
+// itself and reports whether the report was built.
+func workloadRequest(w http.ResponseWriter, r *http.Request) (*WorkloadReport, bool) {
+	query := r.URL.Query()
+	capacity := cfg.WorkloadCapacity
+	if v := query.Get("capacity"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, fmt.Sprintf("invalid capacity %q (want a positive number)", v), http.StatusBadRequest)
+			return nil, false
+		}
+		capacity = n
+	}
+
+	var from, to *quarterInfo
+	for _, bound := range []struct {
+		name string
+		dst  **quarterInfo
+	}{{"from", &from}, {"to", &to}} {
+		if v := query.Get(bound.name); v != "" {
+			year, quarter, err := parseQuarter(v)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return nil, false
+			}
+			*bound.dst = &quarterInfo{year, quarter}
+		}
+	}
+	if from != nil && to != nil && quarterIndex(to.year, to.quarter) < quarterIndex(from.year, from.quarter) {
+		http.Error(w, "to must not be before from", http.StatusBadRequest)
+		return nil, false
+	}
+
+	storeMux.RLock()
+	report := computeWorkload(store.GetAll(), store.People(), capacity, from, to)
+	storeMux.RUnlock()
+	return report, true
+}
+
+func workloadHandler(w http.ResponseWriter, r *http.Request) {
+	report, ok := workloadRequest(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set(contentTypeHeader, jsonContentType)
+	json.NewEncoder(w).Encode(report)
+}
+
+func workloadSVGHandler(w http.ResponseWriter, r *http.Request) {
+	report, ok := workloadRequest(w, r)
+	if !ok {
+		return
+	}
+	if len(report.Quarters) > cfg.ExportMaxColumns {
+		http.Error(w, fmt.Sprintf("report spans %d quarters, export limit is %d", len(report.Quarters), cfg.ExportMaxColumns), http.StatusUnprocessableEntity)
+		return
+	}
+	w.Header().Set(contentTypeHeader, "image/svg+xml")
+	w.Header().Set(contentDisposition, `attachment; filename="workload.svg"`)
+	w.Write([]byte(GenerateWorkloadSVG(report)))
+}
+
+func workloadPNGHandler(w http.ResponseWriter, r *http.Request) {
+	report, ok := workloadRequest(w, r)
+	if !ok {
+		return
+	}
+	if len(report.Quarters) > cfg.ExportMaxColumns {
+		http.Error(w, fmt.Sprintf("report spans %d quarters, export limit is %d", len(report.Quarters), cfg.ExportMaxColumns), http.StatusUnprocessableEntity)
+		return
+	}
+	pngData, err := GenerateWorkloadPNG(report)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error generating PNG: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set(contentTypeHeader, "image/png")
+	w.Header().Set(contentDisposition, `attachment; filename="workload.png"`)
+	w.Write(pngData)
+}